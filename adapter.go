@@ -0,0 +1,71 @@
+package checkpoint
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/dimfeld/httptreemux/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/go-chi/chi/v5"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gorilla/mux"
+	"github.com/julienschmidt/httprouter"
+	"github.com/labstack/echo/v4"
+)
+
+// AdapterFunc adapts v into a Router. It reports false when it does not
+// know how to adapt v, letting the next adapter try.
+type AdapterFunc func(v any) (Router, bool)
+
+var adapters = struct {
+	sync.RWMutex
+	registered []AdapterFunc
+}{}
+
+// builtinAdapters cover the routers supported out of the box. They are
+// consulted after the adapters added with RegisterAdapter.
+var builtinAdapters = []AdapterFunc{
+	func(v any) (Router, bool) {
+		m, ok := v.(*http.ServeMux)
+		return m, ok
+	},
+	func(v any) (Router, bool) {
+		m, ok := v.(chi.Router)
+		return m, ok
+	},
+	func(v any) (Router, bool) {
+		switch v.(type) {
+		case *mux.Router, *gin.Engine, *echo.Echo, *httprouter.Router, *fiber.App, *httptreemux.ContextMux:
+			return &RouterAdapter{Mux: v}, true
+		}
+		return nil, false
+	},
+}
+
+// RegisterAdapter adds an adapter consulted by Init, so in-house or niche
+// routers can be used without changing this package. Adapters are tried in
+// registration order, before the built-in ones.
+func RegisterAdapter(f AdapterFunc) {
+	adapters.Lock()
+	defer adapters.Unlock()
+	adapters.registered = append(adapters.registered, f)
+}
+
+// adapt turns v into a Router using the registered adapters, falling back to
+// v itself when it already implements Router.
+func adapt(v any) (Router, error) {
+	adapters.RLock()
+	registered := append(adapters.registered[:len(adapters.registered):len(adapters.registered)], builtinAdapters...)
+	adapters.RUnlock()
+
+	for _, f := range registered {
+		if r, ok := f(v); ok {
+			return r, nil
+		}
+	}
+	if r, ok := v.(Router); ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("%w: %T", ErrUnsupportedRouter, v)
+}
@@ -0,0 +1,130 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// BenchOption tunes RunBench.
+type BenchOption func(*benchConfig)
+
+type benchConfig struct {
+	parallel bool
+}
+
+// BenchParallel makes RunBench send the requests from several goroutines
+// with b.RunParallel.
+func BenchParallel() BenchOption {
+	return func(c *benchConfig) {
+		c.parallel = true
+	}
+}
+
+// RunBench benchmarks the handler of tc, configured as for Run. The routes
+// are registered and the handler chain and the body are built once, so every
+// iteration only creates the request and the recorder. Allocations are
+// reported, and so is the size of the request body when there is one. The
+// responses are not checked: run tc once with Run to check it serves what it
+// should.
+func (tc *TestConfig) RunBench(b *testing.B, opts ...BenchOption) {
+	b.Helper()
+	var bc benchConfig
+	for _, opt := range opts {
+		opt(&bc)
+	}
+	serve, newRequest, size, err := tc.prepareRequests()
+	if err != nil {
+		b.Fatalf("preparing the benchmark: %v", err)
+	}
+	if size > 0 {
+		b.SetBytes(int64(size))
+	}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	if bc.parallel {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				req, err := newRequest(ctx)
+				if err != nil {
+					b.Errorf("building the request: %v", err)
+					return
+				}
+				serve.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		})
+		return
+	}
+	for b.Loop() {
+		req, err := newRequest(ctx)
+		if err != nil {
+			b.Fatalf("building the request: %v", err)
+		}
+		serve.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+// prepareRequests registers the routes of tc and returns the handler serving
+// its requests, a function building a new request and the size of the body.
+// The body is read once and every request gets its own reader of it; Body is
+// left as it was.
+func (tc *TestConfig) prepareRequests() (http.Handler, func(context.Context) (*http.Request, error), int, error) {
+	if err := tc.Validate(); err != nil {
+		return nil, nil, 0, err
+	}
+	if tc.BaseURL != "" {
+		return nil, nil, 0, errors.New("the handler must be served in process, not at BaseURL")
+	}
+	path, err := tc.requestPath()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	routeMethod, urlPattern := tc.routePattern(path)
+	method := tc.requestMethod(routeMethod)
+	attached := useRouterMiddlewares(tc.Router)
+	serve, _, err := tc.handler(routeMethod, urlPattern, attached)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	var raw []byte
+	if tc.body == nil && tc.Body != nil {
+		if raw, err = io.ReadAll(tc.Body); err != nil {
+			return nil, nil, 0, fmt.Errorf("reading the request body: %w", err)
+		}
+		tc.Body = io.NopCloser(bytes.NewReader(raw))
+		defer func() { tc.Body = io.NopCloser(bytes.NewReader(raw)) }()
+	}
+	r, contentType, err := tc.openBody()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	var body []byte
+	if r != nil {
+		if body, err = io.ReadAll(r); err != nil {
+			return nil, nil, 0, fmt.Errorf("reading the request body: %w", err)
+		}
+		if c, ok := r.(io.Closer); ok && tc.body != nil {
+			c.Close()
+		}
+	}
+
+	newRequest := func(ctx context.Context) (*http.Request, error) {
+		req, err := tc.newRequest(ctx, method, path, bytes.NewReader(body), contentType)
+		if err != nil {
+			return nil, err
+		}
+		if attached {
+			req = withRouterMiddlewares(req, tc.RouterMiddlewares)
+		}
+		return req, nil
+	}
+	return serve, newRequest, len(body), nil
+}
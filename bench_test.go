@@ -0,0 +1,78 @@
+package checkpoint
+
+import (
+	"flag"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func echoBody(w http.ResponseWriter, r *http.Request) {
+	_, _ = io.Copy(w, r.Body)
+}
+
+func BenchmarkRunBench(b *testing.B) {
+	conf := Post(http.NewServeMux(), "/bench/echo", `{"name":"widget"}`)
+	conf.RouteFunc = echoBody
+	conf.RunBench(b)
+}
+
+func BenchmarkRunBenchParallel(b *testing.B) {
+	conf := Post(http.NewServeMux(), "/bench/echo-parallel", `{"name":"widget"}`)
+	conf.RouteFunc = echoBody
+	conf.RunBench(b, BenchParallel())
+}
+
+func Test_RunBench(t *testing.T) {
+	// Keep the benchmarks run by the test short, restoring the global flag
+	// for the tests and benchmarks that follow.
+	benchtime := flag.Lookup("test.benchtime").Value.String()
+	if err := flag.Set("test.benchtime", "100x"); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := flag.Set("test.benchtime", benchtime); err != nil {
+			t.Errorf("restoring -test.benchtime: %v", err)
+		}
+	})
+
+	var calls atomic.Int64
+	var bodies atomic.Int64
+	var registered atomic.Int64
+	conf := Init(http.NewServeMux()).WithPath("/bench/count").WithBodyBytes([]byte("payload"))
+	conf.Method = http.MethodPut
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if b, _ := io.ReadAll(r.Body); string(b) == "payload" && r.Method == http.MethodPut {
+			bodies.Add(1)
+		}
+	}
+	conf.WithMiddlewares(func(next http.Handler) http.Handler {
+		registered.Add(1)
+		return next
+	})
+
+	for _, opts := range [][]BenchOption{nil, {BenchParallel()}} {
+		calls.Store(0)
+		bodies.Store(0)
+		registered.Store(0)
+		result := testing.Benchmark(func(b *testing.B) {
+			conf.RunBench(b, opts...)
+		})
+		assert.Positive(t, result.N)
+		assert.Equal(t, calls.Load(), bodies.Load())
+		assert.GreaterOrEqual(t, calls.Load(), int64(result.N))
+		// The handler chain is built once per run of the benchmark function.
+		assert.Less(t, registered.Load(), calls.Load())
+		assert.Equal(t, int64(len("payload")), result.Bytes)
+	}
+
+	conf = Post(http.NewServeMux(), "/bench/body", "kept")
+	conf.RouteFunc = echoBody
+	testing.Benchmark(func(b *testing.B) { conf.RunBench(b) })
+	b, _ := io.ReadAll(conf.Body)
+	assert.Equal(t, "kept", string(b))
+}
@@ -0,0 +1,256 @@
+package checkpoint
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// requestBody is a request body set by one of the body helpers. It is built
+// when Run creates the request, so every Run sends a fresh copy.
+type requestBody struct {
+	source      string                                  // the helper that set the body, for error messages
+	contentType string                                  // sent unless the Content-Type header is set
+	open        func(tc *TestConfig) (io.Reader, error) // tc is the config being run, maybe a clone
+	buffer      func() error                            // reads the contents sent only once into memory, if any
+}
+
+// setBody sets the request body. Setting it from a different helper than
+// before makes Run fail, as it is unclear which body should be sent.
+func (tc *TestConfig) setBody(body *requestBody) {
+	if tc.body != nil && tc.body.source != body.source && tc.bodyErr == nil {
+		tc.bodyErr = fmt.Errorf("%s and %s both set the request body", tc.body.source, body.source)
+	}
+	tc.body = body
+}
+
+// openBody returns the request body and its default content type.
+func (tc *TestConfig) openBody() (io.Reader, string, error) {
+	var r io.Reader
+	var contentType string
+	if tc.body != nil {
+		var err error
+		if r, err = tc.body.open(tc); err != nil {
+			return nil, "", err
+		}
+		contentType = tc.body.contentType
+	} else if tc.Body != nil {
+		r = tc.Body
+	}
+	if tc.encoding == "" {
+		return r, contentType, nil
+	}
+	compressed, err := compress(r, tc.encoding)
+	if err != nil {
+		return nil, "", err
+	}
+	return compressed, contentType, nil
+}
+
+// WithCompressedBody compresses the request body, whichever way it is set,
+// with encoding, "gzip" or "deflate", and sets the Content-Encoding header.
+func (tc *TestConfig) WithCompressedBody(encoding string) *TestConfig {
+	tc.encoding = encoding
+	return tc.WithHeaders(Header("Content-Encoding", encoding))
+}
+
+// compress returns the content of r compressed with encoding. The result is
+// buffered so the request carries its length.
+func compress(r io.Reader, encoding string) (io.Reader, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	if r != nil {
+		if _, err := io.Copy(w, r); err != nil {
+			return nil, err
+		}
+		if c, ok := r.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// WithJSONBody sends v marshaled as JSON, with the Content-Type header set to
+// application/json unless it is set already. v is marshaled by Run, which
+// reports marshaling errors; a nil v is sent as null.
+func (tc *TestConfig) WithJSONBody(v any) *TestConfig {
+	tc.setBody(&requestBody{
+		source:      "WithJSONBody",
+		contentType: "application/json",
+		open: func(*TestConfig) (io.Reader, error) {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("marshaling the JSON body of type %T: %w", v, err)
+			}
+			return bytes.NewReader(b), nil
+		},
+	})
+	return tc
+}
+
+// WithBodyBytes sends b as the request body
+func (tc *TestConfig) WithBodyBytes(b []byte) *TestConfig {
+	tc.setBody(&requestBody{
+		source: "WithBodyBytes",
+		open: func(*TestConfig) (io.Reader, error) {
+			return bytes.NewReader(b), nil
+		},
+	})
+	return tc
+}
+
+// WithBodyReader sends the content of r as the request body, without reading
+// it in memory first. r is read by Run, hence it is only sent once, and it is
+// closed after Run if it is an io.Closer. As with http.NewRequest, the length
+// and GetBody are known for *bytes.Buffer, *bytes.Reader and *strings.Reader.
+func (tc *TestConfig) WithBodyReader(r io.Reader) *TestConfig {
+	tc.setBody(&requestBody{
+		source: "WithBodyReader",
+		open: func(*TestConfig) (io.Reader, error) {
+			return r, nil
+		},
+	})
+	return tc
+}
+
+// WithBodyFromFile sends the content of the file at path as the request body,
+// streamed from disk. The file is opened by Run, so every Run sends it again,
+// and the request carries its length and a GetBody reopening it. The
+// Content-Type header is guessed from the extension unless it is set already.
+func (tc *TestConfig) WithBodyFromFile(path string) *TestConfig {
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	tc.setBody(&requestBody{
+		source:      "WithBodyFromFile",
+		contentType: contentType,
+		open: func(*TestConfig) (io.Reader, error) {
+			return openFileBody(path)
+		},
+	})
+	return tc
+}
+
+// fileBody is a request body read from a file, see WithBodyFromFile.
+type fileBody struct {
+	*os.File
+	path string
+	size int64
+}
+
+func openFileBody(path string) (*fileBody, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening the request body: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("opening the request body: %w", err)
+	}
+	return &fileBody{File: f, path: path, size: info.Size()}, nil
+}
+
+// attach sets the length and GetBody of req, whose body is b. As with
+// http.NewRequest, an empty file is sent as http.NoBody.
+func (b *fileBody) attach(req *http.Request) {
+	if b.size == 0 {
+		req.Body = http.NoBody
+	}
+	req.ContentLength = b.size
+	req.GetBody = func() (io.ReadCloser, error) {
+		return openFileBody(b.path)
+	}
+}
+
+// WithForm sends values URL-encoded, as an HTML form does, with the
+// Content-Type header set to application/x-www-form-urlencoded unless it is
+// set already. It can be combined with WithFormField.
+func (tc *TestConfig) WithForm(values url.Values) *TestConfig {
+	for key, vs := range values {
+		for _, v := range vs {
+			tc.WithFormField(key, v)
+		}
+	}
+	return tc
+}
+
+// WithFormField adds a field to the form sent by WithForm
+func (tc *TestConfig) WithFormField(key, value string) *TestConfig {
+	if tc.form == nil {
+		tc.form = make(url.Values)
+	}
+	tc.form.Add(key, value)
+	tc.setBody(&requestBody{
+		source:      "WithForm",
+		contentType: "application/x-www-form-urlencoded",
+		open: func(tc *TestConfig) (io.Reader, error) {
+			return strings.NewReader(tc.form.Encode()), nil
+		},
+	})
+	return tc
+}
+
+// WithRequestTrailer declares a request trailer sent after the body, as with a
+// chunked request. As on a server, r.Trailer lists key from the start and
+// holds value once the handler has read the body to the end.
+func (tc *TestConfig) WithRequestTrailer(key, value string) *TestConfig {
+	if tc.trailer == nil {
+		tc.trailer = make(http.Header)
+	}
+	tc.trailer.Add(key, value)
+	return tc
+}
+
+// trailerBody fills the request trailers once the body is read to EOF.
+type trailerBody struct {
+	io.ReadCloser
+	req     *http.Request
+	trailer http.Header
+}
+
+func (b *trailerBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		for key, values := range b.trailer {
+			b.req.Trailer[key] = values
+		}
+	}
+	return n, err
+}
+
+// useTrailer sends the body of req chunked, followed by trailer.
+func useTrailer(req *http.Request, trailer http.Header) {
+	req.Trailer = make(http.Header)
+	for key := range trailer {
+		req.Trailer[key] = nil
+	}
+	body := req.Body
+	if body == nil {
+		body = http.NoBody
+	}
+	req.Body = &trailerBody{ReadCloser: body, req: req, trailer: trailer.Clone()}
+	req.ContentLength = -1
+	req.TransferEncoding = []string{"chunked"}
+}
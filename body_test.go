@@ -0,0 +1,440 @@
+package checkpoint
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RunWithJSONBody(t *testing.T) {
+	ctx := context.Background()
+	echoBody := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+		_, _ = w.Write(body)
+	}
+
+	tc := []struct {
+		value       any
+		header      string
+		contentType string
+		body        string
+		wantErr     string
+	}{
+		{value: map[string]int{"id": 7}, contentType: "application/json", body: `{"id":7}`},
+		{value: nil, contentType: "application/json", body: "null"},
+		{value: []string{"a"}, header: "application/vnd.api+json", contentType: "application/vnd.api+json", body: `["a"]`},
+		{value: make(chan int), wantErr: "marshaling the JSON body of type chan int: json: unsupported type: chan int"},
+	}
+
+	for i, c := range tc {
+		conf := Init(http.NewServeMux())
+		conf.URLPattern = "POST /items"
+		conf.Path = "/items"
+		conf.RouteFunc = echoBody
+		if c.header != "" {
+			conf.WithHeaders(Header("Content-Type", c.header))
+		}
+		conf.WithJSONBody(c.value)
+
+		result, err := conf.Run(ctx)
+		if c.wantErr != "" {
+			assert.EqualError(t, err, c.wantErr, "failure in the test case: %d", i)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, c.contentType, result.Headers["X-Content-Type"], "failure in the test case: %d", i)
+		assert.Equal(t, c.body, result.Body.String(), "failure in the test case: %d", i)
+	}
+
+	// The body is marshaled on every Run.
+	item := struct {
+		Name string `json:"name"`
+	}{Name: "first"}
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "POST /items"
+	conf.Path = "/items"
+	conf.RouteFunc = echoBody
+	conf.WithJSONBody(&item)
+	for _, name := range []string{"first", "second"} {
+		item.Name = name
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		var got map[string]string
+		assert.NoError(t, json.Unmarshal(result.Body, &got))
+		assert.Equal(t, name, got["name"])
+	}
+
+	// An explicit Body alongside a JSON body is ambiguous.
+	conf.SetBodyString("raw")
+	_, err := conf.Run(ctx)
+	assert.EqualError(t, err, "the Body field and WithJSONBody both set the request body")
+}
+
+func Test_RunWithForm(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(chi.NewRouter())
+	conf.URLPattern = "POST /signup"
+	conf.Path = "/signup"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%s|%s|%s|%d", r.PostFormValue("name"), strings.Join(r.PostForm["tag"], ","),
+			r.Header.Get("Content-Type"), r.ContentLength)
+	}
+	conf.WithForm(url.Values{"name": {"Jane & John"}}).
+		WithFormField("tag", "a").
+		WithFormField("tag", "b")
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	// name=Jane+%26+John&tag=a&tag=b
+	assert.Equal(t, "Jane & John|a,b|application/x-www-form-urlencoded|30", result.Body.String())
+
+	// Mixing body helpers is ambiguous.
+	conf.WithJSONBody(map[string]string{"name": "Jane"})
+	_, err = conf.Run(ctx)
+	assert.EqualError(t, err, "WithForm and WithJSONBody both set the request body")
+}
+
+func Test_RunWithMultipart(t *testing.T) {
+	ctx := context.Background()
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 1<<16) // 1MB
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "POST /upload"
+	conf.Path = "/upload"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(32 << 10); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var names []string
+		var contents [][]byte
+		for _, fh := range r.MultipartForm.File["docs"] {
+			f, err := fh.Open()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			b, _ := io.ReadAll(f)
+			_ = f.Close()
+			names = append(names, fh.Filename)
+			contents = append(contents, b)
+		}
+		if len(contents) != 2 || !bytes.Equal(contents[0], payload) || string(contents[1]) != "small" {
+			http.Error(w, "unexpected files", http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "%s %s", r.FormValue("title"), strings.Join(names, ","))
+	}
+	conf.WithMultipart().
+		AddField("title", "report").
+		AddFile("docs", "big.bin", bytes.NewReader(payload)).
+		AddFile("docs", "small.txt", strings.NewReader("small"))
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode, result.Body.String())
+	assert.Equal(t, "report big.bin,small.txt", result.Body.String())
+
+	// A handler that ignores the body does not block Run.
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+	conf.WithMultipart().AddFile("docs", "big.bin", bytes.NewReader(payload))
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+}
+
+func Test_RunWithBodyReaderAndBytes(t *testing.T) {
+	ctx := context.Background()
+	digest := func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "%d %d %x", r.ContentLength, len(body), sha256.Sum256(body))
+	}
+
+	large := make([]byte, 10<<20)
+	_, _ = rand.New(rand.NewSource(1)).Read(large)
+	raw := []byte{'a', 0, 'b', 0xff}
+
+	tc := []struct {
+		configure func(conf *TestConfig)
+		want      []byte
+		length    int
+	}{
+		// The length of an opaque reader is unknown.
+		{configure: func(conf *TestConfig) { conf.WithBodyReader(struct{ io.Reader }{bytes.NewReader(large)}) }, want: large, length: -1},
+		{configure: func(conf *TestConfig) { conf.WithBodyReader(bytes.NewReader(large)) }, want: large, length: len(large)},
+		{configure: func(conf *TestConfig) { conf.WithBodyBytes(raw) }, want: raw, length: len(raw)},
+	}
+
+	for i, c := range tc {
+		conf := Init(http.NewServeMux())
+		conf.URLPattern = "PUT /blobs/{id}"
+		conf.Path = "/blobs/1"
+		conf.RouteFunc = digest
+		c.configure(conf)
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, fmt.Sprintf("%d %d %x", c.length, len(c.want), sha256.Sum256(c.want)), result.Body.String(),
+			"failure in the test case: %d", i)
+	}
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "PUT /blobs/{id}"
+	conf.Path = "/blobs/1"
+	conf.RouteFunc = digest
+	conf.WithBodyBytes(raw).WithBodyReader(bytes.NewReader(large))
+	_, err := conf.Run(ctx)
+	assert.EqualError(t, err, "WithBodyBytes and WithBodyReader both set the request body")
+}
+
+func Test_RunWithBodyFromFile(t *testing.T) {
+	ctx := context.Background()
+
+	fixture := make([]byte, 3<<20)
+	_, _ = rand.New(rand.NewSource(2)).Read(fixture)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "upload.png")
+	if err := os.WriteFile(path, fixture, 0o600); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	want := fmt.Sprintf("image/png %d %x", len(fixture), sha256.Sum256(fixture))
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "PUT /uploads/{id}"
+	conf.Path = "/uploads/1"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "%s %d %x", r.Header.Get("Content-Type"), r.ContentLength, sha256.Sum256(body))
+	}
+	conf.WithBodyFromFile(path)
+
+	// Every run sends the file again, parallel ones included.
+	for range 2 {
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, want, result.Body.String())
+		assert.Contains(t, result.DumpRequest(), "[truncated, 3145216 more bytes]")
+	}
+	results, err := conf.RunParallel(ctx, 4)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	for _, result := range results {
+		assert.Equal(t, want, result.Body.String())
+	}
+
+	// GetBody sends the file again when a redirect keeps the body.
+	redirected := Init(http.NewServeMux())
+	redirected.URLPattern = "PUT /uploads/old"
+	redirected.Path = "/uploads/old"
+	redirected.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/uploads/1", http.StatusTemporaryRedirect)
+	}
+	redirected.WithExtraRoute("PUT /uploads/1", conf.RouteFunc)
+	redirected.FollowRedirects = 1
+	result, err := redirected.WithBodyFromFile(path).Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, want, result.Body.String())
+
+	// The Content-Type header overrides the guessed one.
+	result, err = conf.Clone().WithHeaders(Header("Content-Type", "application/x-fixture")).Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.True(t, strings.HasPrefix(result.Body.String(), "application/x-fixture "))
+
+	missing := filepath.Join(dir, "missing.bin")
+	_, err = conf.Clone().WithBodyFromFile(missing).Run(ctx)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.ErrorContains(t, err, "opening the request body: open "+missing)
+}
+
+// openFiles returns the number of files the process has open, skipping the
+// test where it cannot be told.
+func openFiles(t *testing.T) int {
+	t.Helper()
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("no /proc/self/fd to count the open files with")
+	}
+	return len(entries)
+}
+
+func Test_WithBodyFromFileClosed(t *testing.T) {
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "user.json")
+	if err := os.WriteFile(path, []byte(`{"name":"ann"}`), 0o600); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	conf := Init(http.NewServeMux()).WithMethod(http.MethodPost).WithPath("/users").WithBodyFromFile(path)
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	}
+
+	// Curl commands and scenario steps read the file and close it, as runs
+	// do.
+	before := openFiles(t)
+	for range 20 {
+		command, err := conf.CurlCommand()
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Contains(t, command, `{"name":"ann"}`)
+		results, _, err := NewScenario().Step("create", conf, nil).Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, `{"name":"ann"}`, results[0].Body.String())
+	}
+	assert.Equal(t, before, openFiles(t))
+}
+
+func Test_RunWithCompressedBody(t *testing.T) {
+	ctx := context.Background()
+
+	decompress := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body io.ReadCloser
+			var err error
+			switch r.Header.Get("Content-Encoding") {
+			case "gzip":
+				body, err = gzip.NewReader(r.Body)
+			case "deflate":
+				body, err = zlib.NewReader(r.Body)
+			default:
+				http.Error(w, "not compressed", http.StatusUnsupportedMediaType)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("X-Content-Length", fmt.Sprint(r.ContentLength))
+			r.Body = body
+			r.Header.Del("Content-Encoding")
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	tc := []struct {
+		encoding  string
+		configure func(conf *TestConfig)
+		want      string
+	}{
+		{encoding: "gzip", configure: func(conf *TestConfig) { conf.SetBodyString("plain text") }, want: "plain text"},
+		{encoding: "deflate", configure: func(conf *TestConfig) { conf.WithBodyBytes([]byte{1, 0, 2}) }, want: "\x01\x00\x02"},
+		{encoding: "gzip", configure: func(conf *TestConfig) { conf.WithJSONBody(map[string]int{"n": 1}) }, want: `{"n":1}`},
+	}
+
+	for i, c := range tc {
+		conf := Init(http.NewServeMux())
+		conf.URLPattern = "POST /ingest"
+		conf.Path = "/ingest"
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			_, _ = w.Write(body)
+		}
+		conf.WithMiddlewares(decompress)
+		c.configure(conf)
+		conf.WithCompressedBody(c.encoding)
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, http.StatusOK, result.StatusCode, "failure in the test case: %d", i)
+		assert.Equal(t, c.want, result.Body.String(), "failure in the test case: %d", i)
+		assert.NotEqual(t, "-1", result.Headers["X-Content-Length"], "failure in the test case: %d", i)
+	}
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "POST /ingest"
+	conf.Path = "/ingest"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+	conf.SetBodyString("plain text")
+	conf.WithCompressedBody("br")
+	_, err := conf.Run(ctx)
+	assert.EqualError(t, err, `unsupported content encoding "br"`)
+}
+
+func Test_RunWithRequestTrailer(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "PUT /objects/{id}"
+	conf.Path = "/objects/1"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, declared := r.Trailer["X-Checksum"]
+		before := r.Trailer.Get("X-Checksum")
+		body, _ := io.ReadAll(r.Body)
+		_, _ = fmt.Fprintf(w, "%t %q %s %s %d", declared, before, body, r.Trailer.Get("X-Checksum"), r.ContentLength)
+	}
+	conf.SetBodyString("object data")
+	conf.WithRequestTrailer("X-Checksum", "abc123")
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, `true "" object data abc123 -1`, result.Body.String())
+
+	// Expect: 100-continue does not hold up the request, the body is sent
+	// right away as it is already in memory.
+	conf = Init(http.NewServeMux())
+	conf.URLPattern = "PUT /objects/{id}"
+	conf.Path = "/objects/1"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = fmt.Fprintf(w, "%s %s", r.Header.Get("Expect"), body)
+	}
+	conf.SetBodyString("object data")
+	conf.WithHeaders(Header("Expect", "100-continue"))
+
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "100-continue object data", result.Body.String())
+}
@@ -0,0 +1,114 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// Case is one request of a table run by RunAll. Empty fields keep the value
+// of the base config.
+type Case struct {
+	Name       string
+	Method     string
+	Path       string
+	URLPattern string
+	Headers    map[string]string // set on top of the base headers
+	Body       string            // replaces the base body when not empty
+	// WantStatus is the expected status code, unchecked when zero.
+	WantStatus int
+	// WantBody is the expected body, compared as JSON when the response is
+	// JSON and as is otherwise. An empty WantBody is not checked.
+	WantBody string
+	// BodyAssert runs further checks on the result.
+	BodyAssert func(t TB, r *Result)
+	// Parallel runs the case in parallel with the other parallel cases.
+	Parallel bool
+	// Setup is called with the config of the case before it runs, and
+	// Teardown after it ran, even when it failed.
+	Setup    func(tc *TestConfig)
+	Teardown func()
+}
+
+// RunAll runs every case on a clone of tc. With a *testing.T each case is a
+// subtest named after it, otherwise the cases run one after the other and
+// each failure is prefixed by the case name. Failures come with the request
+// and response dumps, as reported by Expect.
+func (tc *TestConfig) RunAll(t TB, cases []Case) {
+	t.Helper()
+	for _, c := range cases {
+		if tt, ok := t.(*testing.T); ok {
+			tt.Run(c.Name, func(st *testing.T) {
+				if c.Parallel {
+					st.Parallel()
+				}
+				tc.runCase(st, c)
+			})
+			continue
+		}
+		tc.runCase(&caseT{TB: t, name: c.Name}, c)
+	}
+}
+
+// runCase runs c on a clone of tc and checks its expectations.
+func (tc *TestConfig) runCase(t TB, c Case) {
+	t.Helper()
+	conf := tc.Clone()
+	if c.Method != "" {
+		conf.Method = c.Method
+	}
+	if c.Path != "" {
+		conf.Path = c.Path
+	}
+	if c.URLPattern != "" {
+		conf.URLPattern = c.URLPattern
+	}
+	for key, value := range c.Headers {
+		conf.setHeader(key, value)
+	}
+	if c.Body != "" {
+		conf.body, conf.bodyErr = nil, nil
+		conf.SetBodyString(c.Body)
+	}
+	if c.Setup != nil {
+		c.Setup(conf)
+	}
+	if c.Teardown != nil {
+		defer c.Teardown()
+	}
+
+	result, err := conf.Run(context.Background())
+	if result == nil {
+		t.Errorf("running the request: %v", err)
+		return
+	}
+	e := result.Expect(t)
+	if err != nil {
+		e.fail("running the request: %v", err)
+	}
+	if c.WantStatus != 0 {
+		e.Status(c.WantStatus)
+	}
+	if c.WantBody != "" {
+		if isJSON(result.RawHeaders.Get("Content-Type")) {
+			e.BodyJSONEq(c.WantBody)
+		} else if got := result.Body.String(); got != c.WantBody {
+			e.fail("expected body %q, got %q", c.WantBody, got)
+		}
+	}
+	e.Done()
+	if c.BodyAssert != nil {
+		c.BodyAssert(t, result)
+	}
+}
+
+// caseT prefixes the failures reported to TB with the name of a case.
+type caseT struct {
+	TB
+	name string
+}
+
+func (c *caseT) Errorf(format string, args ...any) {
+	c.TB.Helper()
+	c.TB.Errorf("case %s: %s", c.name, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,113 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func casesConfig() *TestConfig {
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/items/{id}"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "0" {
+			http.Error(w, "no such item", http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"id":     r.PathValue("id"),
+			"method": r.Method,
+			"body":   string(body),
+			"tenant": r.Header.Get("X-Tenant"),
+		})
+	}
+	return conf.WithHeaders(Header("X-Tenant", "acme"))
+}
+
+func Test_RunAll(t *testing.T) {
+	var torndown atomic.Int32
+	casesConfig().RunAll(t, []Case{
+		{
+			Name:       "get",
+			Path:       "/items/1",
+			WantStatus: http.StatusOK,
+			WantBody:   `{"id":"1","method":"GET","body":"","tenant":"acme"}`,
+		},
+		{
+			Name:       "post",
+			Method:     http.MethodPost,
+			Path:       "/items/2",
+			Body:       "payload",
+			Headers:    map[string]string{"x-tenant": "other"},
+			WantStatus: http.StatusOK,
+			WantBody:   `{"id":"2","method":"POST","body":"payload","tenant":"other"}`,
+			Parallel:   true,
+			Teardown:   func() { torndown.Add(1) },
+		},
+		{
+			Name:       "missing",
+			Path:       "/items/0",
+			WantStatus: http.StatusNotFound,
+			WantBody:   "no such item\n",
+			Parallel:   true,
+			BodyAssert: func(t TB, r *Result) {
+				r.Expect(t).ContentTypeIs("text/plain")
+			},
+		},
+	})
+	t.Cleanup(func() {
+		assert.Equal(t, int32(1), torndown.Load())
+	})
+}
+
+func Test_RunAllReportsFailures(t *testing.T) {
+	var setup []string
+	fake := &fakeT{}
+	casesConfig().RunAll(fake, []Case{
+		{
+			Name:       "passing",
+			Path:       "/items/1",
+			WantStatus: http.StatusOK,
+			Setup:      func(tc *TestConfig) { setup = append(setup, tc.Path) },
+		},
+		{
+			Name:       "wrong status",
+			Path:       "/items/0",
+			WantStatus: http.StatusOK,
+		},
+		{
+			Name:     "wrong body",
+			Path:     "/items/3",
+			WantBody: `{"id":"4"}`,
+			Setup:    func(tc *TestConfig) { setup = append(setup, tc.Path) },
+		},
+		{
+			Name:       "assertion",
+			Path:       "/items/5",
+			BodyAssert: func(t TB, r *Result) { t.Errorf("custom failure") },
+		},
+		{
+			Name:       "invalid",
+			Path:       "/elsewhere",
+			WantStatus: http.StatusOK,
+		},
+	})
+
+	assert.Equal(t, []string{"/items/1", "/items/3"}, setup)
+	if assert.Len(t, fake.errors, 4) {
+		assert.True(t, strings.HasPrefix(fake.errors[0], "case wrong status: 1 expectation failed for GET /items/0:\n"+
+			"1. expected status 200, got 404\n"), fake.errors[0])
+		assert.Contains(t, fake.errors[0], "\nResponse:\nHTTP/1.1 404 Not Found\r\n")
+		assert.True(t, strings.HasPrefix(fake.errors[1], "case wrong body: 1 expectation failed for GET /items/3:\n"+
+			`1. expected a JSON body equivalent to {"id":"4"}: JSON bodies differ:`), fake.errors[1])
+		assert.Equal(t, "case assertion: custom failure", fake.errors[2])
+		assert.Equal(t, "case invalid: running the request: path /elsewhere does not match pattern /items/{id} (segment 1: elsewhere != items)", fake.errors[3])
+	}
+}
@@ -1,19 +1,101 @@
 package checkpoint
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
+	"maps"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"slices"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
 )
 
 type Body []byte
 type Result struct {
-	Headers    map[string]string
+	Headers    map[string]string // values of repeated headers are joined with ", ", use RawHeaders or Cookies for Set-Cookie
+	RawHeaders http.Header       // the response headers as written, repeated headers kept apart
 	StatusCode int
-	Body       Body
+	Body       Body          // decoded according to Content-Encoding, unless DisableDecompression is set
+	RawBody    Body          // the body as written by the handler, up to MaxBodyBytes
+	Location   *url.URL      // the Location header resolved against the request URL, if any
+	Redirects  []Redirect    // redirects followed when FollowRedirects is set
+	Pattern    string        // the route pattern that matched the request, when the router can report it
+	Trailers   http.Header   // trailers the handler declared in the Trailer header or sent with http.TrailerPrefix
+	Duration   time.Duration // wall-clock time Run took, see Timing
+	Timing     Timing
+	// BytesWritten is the size of the body the handler wrote, which is more
+	// than len(RawBody) when Truncated is set because of MaxBodyBytes.
+	BytesWritten int64
+	Truncated    bool
+	// Proto is the protocol the response was served over, "HTTP/2.0" for
+	// RunServerTLS and "HTTP/1.1" otherwise, and TLS the state of the
+	// connection when it used TLS, as seen by the client.
+	Proto string
+	TLS   *tls.ConnectionState
+	// WrittenBy is the layer of the chain that first wrote the response, nil
+	// when nothing was written, and HandlerReached tells whether the request
+	// went through every middleware to the handler. Only Run sets them.
+	WrittenBy      *Layer
+	HandlerReached bool
+	// MiddlewareTimings holds the time spent in each middleware on each side
+	// of the next layer, outermost first, when ProfileMiddlewares is set.
+	MiddlewareTimings []MiddlewareTiming
+	// Chunks splits RawBody where the handler flushed it, the part written
+	// after the last flush being the last chunk, and ChunkTimes tells when
+	// each chunk ended since the request was served. Both are nil unless the
+	// handler flushed, which Flushed tells. Only Run sets them, for the last
+	// response when redirects were followed.
+	Chunks     [][]byte
+	ChunkTimes []time.Duration
+	Flushed    bool
+	// HijackedConn is the test end of the connection the handler hijacked,
+	// with AllowHijack set. Close it once done.
+	HijackedConn net.Conn
+	// ExecutionTrace holds, when TraceMiddlewares is set, the layers of the
+	// chain in the order the request went through them: "name:enter" and
+	// "name:exit" around each middleware, "handler" when the handler is
+	// called, and "name:panic" for a layer a panic unwound.
+	ExecutionTrace []string
+	// ControllerCalls lists the deadlines the handler set and the full-duplex
+	// requests it made through http.ResponseController, in order. Only Run
+	// sets them, for the last response when redirects were followed.
+	ControllerCalls []ControllerCall
+
+	recorder    *httptest.ResponseRecorder
+	response    *http.Response
+	requestDump string
+	request     *http.Request // the last request sent, for the after-run hooks
+	reporter    *Reporter     // the Reporter the run was added to, with its entry
+	entry       *ReportEntry
+}
+
+// Timing splits Result.Duration into the phases of Run.
+type Timing struct {
+	Setup    time.Duration // building the request and registering the routes
+	Handler  time.Duration // serving the request, redirects included
+	BodyRead time.Duration // collecting the response
+	// Layers is the time spent in each layer of the chain the request went
+	// through, inner layers included, outermost first. It is set by Run, and
+	// zero for a layer that did not return as it panicked or timed out.
+	Layers []LayerTiming
+}
+
+// Cookies parses the Set-Cookie headers of the response. Each header is parsed
+// on its own, so cookies are not affected by the joined Headers value.
+func (r *Result) Cookies() []*http.Cookie {
+	return (&http.Response{Header: r.RawHeaders}).Cookies()
 }
 
 // TestConfig holds the configuration for the Test function
@@ -23,9 +105,133 @@ type TestConfig struct {
 	Path        string                                   // Required
 	Headers     map[string]string                        // Optional
 	Middlewares []func(http.Handler) http.Handler        // Optional
-	URLPattern  string                                   // Optional
+	URLPattern  string                                   // Optional, may start with a method as in "GET /items/{id}"
 	Method      string                                   // Optional
 	Body        io.ReadCloser
+	// Name names the config in the reports of a Reporter, instead of the
+	// method and route pattern of its request.
+	Name string
+	// UseExistingRoutes serves the request through the routes already registered
+	// on Router instead of registering RouteFunc. RouteFunc is not required in
+	// this mode and Middlewares wrap the whole Router.
+	UseExistingRoutes bool
+	// MountAt registers the route on the chi sub-router mounted at this prefix
+	// (mounting a new one if there is none), so sub-router middleware runs and
+	// route patterns include the prefix. URLPattern is relative to the mount,
+	// while Path is the full request path. It requires a chi Router.
+	MountAt string
+	// Host is the host the request is sent to. HostPattern is the host the route
+	// is registered for, such as "{tenant}.example.com", and defaults to Host.
+	// Host-scoped registration needs a HostRouter, such as the gorilla adapter.
+	Host        string
+	HostPattern string
+	// PathPrefix reproduces a handler mounted behind http.StripPrefix: the route
+	// is registered under the prefix, Path must start with it, and the handler
+	// sees the path with the prefix removed. See WithPathPrefix.
+	PathPrefix string
+	// RouterMiddlewares are attached to the router itself, as with chi's Use, so
+	// they run before routing the way they do in production. Routers that do
+	// not support it get them wrapped around the handler instead.
+	RouterMiddlewares []func(http.Handler) http.Handler
+	// FollowRedirects is the number of redirects Run follows by replaying the
+	// request against the router. Zero returns redirect responses as they are.
+	FollowRedirects int
+	// MaxBodyBytes caps the bytes of the response body kept in Result.Body, so
+	// large responses do not fill the memory; Result.Truncated reports a cut.
+	// Zero keeps the whole body. It is the one response cap, there being no
+	// MaxResponseBytes: bytes past it are dropped as the handler writes them,
+	// so even a handler writing without end is bounded. See
+	// WithResponseWriterSink.
+	MaxBodyBytes int64
+	// DisableDecompression keeps Result.Body as written when the response has
+	// a Content-Encoding. By default gzip, deflate and br bodies are decoded.
+	DisableDecompression bool
+	// Timeout bounds the time Run waits for the handler, redirects included.
+	// It is applied to the request context, and a handler that ignores it is
+	// abandoned: Run returns ErrTimeout with the response recorded so far,
+	// while the handler goroutine keeps running until it returns, possibly
+	// leaking if it never does. Zero disables it.
+	Timeout time.Duration
+	// PropagatePanics lets a panic of the handler or a middleware crash the
+	// test as it would without Run, which otherwise recovers it and returns a
+	// *PanicError.
+	PropagatePanics bool
+	// AllowHijack lets the handler hijack the connection, which is then an
+	// in-memory one: Run serves the handler in its own goroutine and returns
+	// as soon as it hijacks, with the other end of the connection as
+	// Result.HijackedConn. Without it, Run fails with ErrHijackNotAllowed when
+	// the handler hijacks.
+	AllowHijack bool
+	// BaseURL sends the request to the service running at this URL, as
+	// "http://localhost:8080", instead of serving it with Router; see
+	// InitRemote. Router, RouteFunc and URLPattern are then not used, except
+	// for URLPattern filling in WithPathParams.
+	BaseURL string
+	// Strict makes Run reject methods other than the http.Method* ones, and
+	// GET, HEAD and TRACE requests with a body.
+	Strict bool
+	// ProfileMiddlewares makes Run fill Result.MiddlewareTimings with the time
+	// each middleware spends before and after calling the next layer.
+	ProfileMiddlewares bool
+	// TraceMiddlewares makes Run fill Result.ExecutionTrace with the order
+	// the request entered and left the middlewares.
+	TraceMiddlewares bool
+
+	routes      []methodRoute         // added by WithRoute
+	added       http.Header           // added by WithAddedHeaders
+	extraRoutes []patternRoute        // added by WithExtraRoute
+	query       url.Values            // added by WithQuery and WithQueryValues
+	graphQL     *graphQLRequest       // set by WithGraphQL when sent over GET
+	cookies     []*http.Cookie        // added by WithCookies
+	body        *requestBody          // set by the body helpers such as WithJSONBody
+	bodyErr     error                 // set when several body helpers were used, or one failed
+	form        url.Values            // added by WithForm and WithFormField
+	encoding    string                // set by WithCompressedBody
+	trailer     http.Header           // added by WithRequestTrailer
+	pathParams  map[string]string     // set by WithPathParams
+	modifiers   []func(*http.Request) // added by WithRequestModifier
+	values      []contextValue        // added by WithContextValue
+	secure      bool                  // set by WithTLS
+	clientCerts []*x509.Certificate   // added by WithClientCertificate
+	remoteAddr  string                // set by WithRemoteAddr
+	sink        io.Writer             // set by WithResponseWriterSink
+	schema      *jsonschema.Schema    // set by WithResponseSchema
+	schemaErr   error                 // set when the response schema does not compile
+	schemaSkip  []int                 // added by WithSchemaSkippedStatus
+	contract    Contract              // set by WithContract
+	session     *Session              // set by WithSession
+	remoteTLS   *tls.Config           // set by WithRemoteTLS
+	beforeHooks []BeforeRunHook       // added by OnBeforeRun
+	afterHooks  []AfterRunHook        // added by OnAfterRun
+	logger      *runLogger            // set by WithLogger
+	tracer      Tracer                // set by WithTracer
+	mwNames     []string              // names of Middlewares, added by WithMiddlewares and WithNamedMiddleware
+	skipMWs     []string              // names of the middlewares left out, set by SkipMiddleware
+	onlyMWs     []string              // names of the only middlewares applied when not nil, set by OnlyMiddlewares
+	stopEvents  int                   // number of events ending the request, set by StopAfterEvents
+	routerErr   error                 // set by Init when the router could not be adapted
+
+	// defaults is the start of Middlewares holding the defaults, removed by
+	// WithoutDefaultMiddlewares. It shares the array of Middlewares, so a
+	// slice assigned to the field is told apart as holding none.
+	defaults []func(http.Handler) http.Handler
+}
+
+// methodRoute is a handler registered for one method on the config's pattern.
+type methodRoute struct {
+	method  string
+	handler func(http.ResponseWriter, *http.Request)
+}
+
+// patternRoute is a handler registered on a pattern of its own.
+type patternRoute struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+// contextValue is a value added to the request context.
+type contextValue struct {
+	key, value any
 }
 
 // SetBodyString is a convenience method to set the Body field as a string
@@ -33,20 +239,107 @@ func (tc *TestConfig) SetBodyString(body string) {
 	tc.Body = io.NopCloser(strings.NewReader(body))
 }
 
+// The With setters below set the field of the same name and return tc, so a
+// config can be built in one expression. They are interchangeable with
+// assigning the fields: whichever is done last wins.
+
+// WithPath sets Path.
+func (tc *TestConfig) WithPath(path string) *TestConfig {
+	tc.Path = path
+	return tc
+}
+
+// WithPattern sets URLPattern.
+func (tc *TestConfig) WithPattern(pattern string) *TestConfig {
+	tc.URLPattern = pattern
+	return tc
+}
+
+// WithMethod sets Method.
+func (tc *TestConfig) WithMethod(method string) *TestConfig {
+	tc.Method = method
+	return tc
+}
+
+// WithRouteFunc sets RouteFunc.
+func (tc *TestConfig) WithRouteFunc(f func(http.ResponseWriter, *http.Request)) *TestConfig {
+	tc.RouteFunc = f
+	return tc
+}
+
+// WithBody sets Body, wrapping body with io.NopCloser unless it is an
+// io.ReadCloser. A nil body clears it.
+func (tc *TestConfig) WithBody(body io.Reader) *TestConfig {
+	switch b := body.(type) {
+	case nil:
+		tc.Body = nil
+	case io.ReadCloser:
+		tc.Body = b
+	default:
+		tc.Body = io.NopCloser(b)
+	}
+	return tc
+}
+
 type HeaderFunc func() (string, string)
 
-// WithHeaders adds headers to the TestConfig
+// WithHeaders adds headers to the TestConfig, replacing earlier values of the
+// same headers
 func (tc *TestConfig) WithHeaders(headers ...HeaderFunc) *TestConfig {
+	for _, h := range headers {
+		k, v := h()
+		tc.setHeader(k, v)
+	}
+	return tc
+}
+
+// setHeader sets a request header, replacing it whatever the case it was set
+// with, so the last value set for a header wins.
+func (tc *TestConfig) setHeader(key, value string) {
 	if tc.Headers == nil {
 		tc.Headers = make(map[string]string)
 	}
+	for k := range tc.Headers {
+		if strings.EqualFold(k, key) {
+			delete(tc.Headers, k)
+		}
+	}
+	tc.added.Del(key)
+	tc.Headers[key] = value
+}
+
+// WithAddedHeaders adds header values without replacing the ones already set,
+// as http.Header.Add does, so a header can be sent several times.
+func (tc *TestConfig) WithAddedHeaders(headers ...HeaderFunc) *TestConfig {
+	if tc.added == nil {
+		tc.added = make(http.Header)
+	}
 	for _, h := range headers {
 		k, v := h()
-		tc.Headers[k] = v
+		tc.added.Add(k, v)
 	}
 	return tc
 }
 
+// WithBasicAuth sets the Authorization header to use basic authentication, as
+// http.Request.SetBasicAuth does. It replaces an Authorization header set
+// before it and is replaced by one set after it.
+func (tc *TestConfig) WithBasicAuth(username, password string) *TestConfig {
+	return tc.WithAuthorization("Basic", base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+}
+
+// WithBearerToken sets the Authorization header to "Bearer <token>"
+func (tc *TestConfig) WithBearerToken(token string) *TestConfig {
+	return tc.WithAuthorization("Bearer", token)
+}
+
+// WithAuthorization sets the Authorization header to "<scheme> <credentials>".
+// Like WithBasicAuth, the last Authorization header set wins.
+func (tc *TestConfig) WithAuthorization(scheme, credentials string) *TestConfig {
+	tc.setHeader("Authorization", scheme+" "+credentials)
+	return tc
+}
+
 // Header creates a HeaderFunc
 func Header(key string, value string) HeaderFunc {
 	return func() (string, string) {
@@ -54,85 +347,809 @@ func Header(key string, value string) HeaderFunc {
 	}
 }
 
-// WithMiddlewares adds middlewares to the TestConfig
+// WithQuery adds a query parameter to the request URL. Parameters are added
+// to any query already in Path, and repeated keys are kept.
+func (tc *TestConfig) WithQuery(key, value string) *TestConfig {
+	if tc.query == nil {
+		tc.query = make(url.Values)
+	}
+	tc.query.Add(key, value)
+	return tc
+}
+
+// WithQueryValues adds every value of values to the request URL, as WithQuery
+func (tc *TestConfig) WithQueryValues(values url.Values) *TestConfig {
+	for key, vs := range values {
+		for _, v := range vs {
+			tc.WithQuery(key, v)
+		}
+	}
+	return tc
+}
+
+// WithCookies adds cookies to the request
+func (tc *TestConfig) WithCookies(cookies ...*http.Cookie) *TestConfig {
+	tc.cookies = append(tc.cookies, cookies...)
+	return tc
+}
+
+// WithPathParams builds Path from URLPattern by substituting its {name}
+// placeholders with params, escaped. Run fails when a placeholder has no
+// value or a value no placeholder.
+func (tc *TestConfig) WithPathParams(params map[string]string) *TestConfig {
+	tc.pathParams = params
+	return tc
+}
+
+// WithRequestModifier adds a function changing the request once it is built,
+// with its headers and body, and before any middleware sees it. Modifiers run
+// in the order they were added, also on requests following redirects.
+func (tc *TestConfig) WithRequestModifier(modify func(*http.Request)) *TestConfig {
+	tc.modifiers = append(tc.modifiers, modify)
+	return tc
+}
+
+// WithContextValue adds a value to the context of the request, on top of the
+// context given to Run, as a middleware setting it up would
+func (tc *TestConfig) WithContextValue(key, value any) *TestConfig {
+	tc.values = append(tc.values, contextValue{key: key, value: value})
+	return tc
+}
+
+// WithTLS sends the request as if it came over TLS: r.TLS is set and the
+// URL scheme is https. Redirects to https locations are followed the same way.
+func (tc *TestConfig) WithTLS() *TestConfig {
+	tc.secure = true
+	return tc
+}
+
+// WithClientCertificate sends the request over TLS, as WithTLS, with certs as
+// the client certificates, the leaf first.
+func (tc *TestConfig) WithClientCertificate(certs ...*x509.Certificate) *TestConfig {
+	tc.clientCerts = append(tc.clientCerts, certs...)
+	return tc.WithTLS()
+}
+
+// useTLS makes req look like a request received over TLS.
+func (tc *TestConfig) useTLS(req *http.Request) {
+	req.URL.Scheme = "https"
+	if req.URL.Host == "" {
+		req.URL.Host = req.Host
+	}
+	req.TLS = &tls.ConnectionState{
+		Version:           tls.VersionTLS13,
+		HandshakeComplete: true,
+		ServerName:        req.URL.Hostname(),
+		PeerCertificates:  tc.clientCerts,
+	}
+}
+
+// WithRemoteAddr sets the address the request comes from, as "ip:port"
+func (tc *TestConfig) WithRemoteAddr(addr string) *TestConfig {
+	tc.remoteAddr = addr
+	return tc
+}
+
+// WithForwardedFor sets the X-Forwarded-For header to ips, the client first
+// followed by the proxies, and X-Real-IP to the client.
+func (tc *TestConfig) WithForwardedFor(ips ...string) *TestConfig {
+	if len(ips) == 0 {
+		return tc
+	}
+	tc.setHeader("X-Forwarded-For", strings.Join(ips, ", "))
+	tc.setHeader("X-Real-IP", ips[0])
+	return tc
+}
+
+// WithPathPrefix serves the handler behind http.StripPrefix(prefix, ...)
+func (tc *TestConfig) WithPathPrefix(prefix string) *TestConfig {
+	tc.PathPrefix = prefix
+	return tc
+}
+
+// WithMiddlewares adds middlewares to the TestConfig. They are named after
+// their position, as "middleware[2]", in the diagnostics of a run.
 func (tc *TestConfig) WithMiddlewares(middlewares ...func(http.Handler) http.Handler) *TestConfig {
+	for i := range middlewares {
+		tc.mwNames = append(tc.mwNames, fmt.Sprintf("middleware[%d]", len(tc.Middlewares)+i))
+	}
+	n := tc.defaultCount()
 	tc.Middlewares = append(tc.Middlewares, middlewares...)
+	tc.defaults = tc.Middlewares[:n]
 	return tc
 }
 
-// Run executes the test with the current configuration
+// defaultMiddlewares are the middlewares every config starts with, set by
+// SetDefaultMiddlewares.
+var defaultMiddlewares = struct {
+	sync.RWMutex
+	list []func(http.Handler) http.Handler
+}{}
+
+// SetDefaultMiddlewares sets the middlewares every config created by Init
+// afterwards starts with, replacing the previous ones. The chain of a config
+// is, from the outermost: these middlewares, the ones of the
+// WithDefaultMiddlewares option, then the ones added to the config in the
+// order they were added. WithoutDefaultMiddlewares opts a config out.
+func SetDefaultMiddlewares(middlewares ...func(http.Handler) http.Handler) {
+	defaultMiddlewares.Lock()
+	defer defaultMiddlewares.Unlock()
+	defaultMiddlewares.list = slices.Clone(middlewares)
+}
+
+// WithoutDefaultMiddlewares removes the middlewares the config started with,
+// set by SetDefaultMiddlewares and the WithDefaultMiddlewares option, keeping
+// the ones added to it. Unnamed middlewares are renamed after their position
+// in the shorter chain. Middlewares assigned to the field directly, rather
+// than added, are kept as they are.
+func (tc *TestConfig) WithoutDefaultMiddlewares() *TestConfig {
+	n := tc.defaultCount()
+	if len(tc.mwNames) == len(tc.Middlewares) {
+		names := slices.Clone(tc.mwNames[n:])
+		for i, name := range names {
+			if name == fmt.Sprintf("middleware[%d]", i+n) {
+				names[i] = fmt.Sprintf("middleware[%d]", i)
+			}
+		}
+		tc.mwNames = names
+	}
+	tc.Middlewares = slices.Clone(tc.Middlewares[n:])
+	tc.defaults = nil
+	return tc
+}
+
+// defaultCount returns the number of defaults Middlewares starts with, none
+// once the field no longer shares its array with defaults.
+func (tc *TestConfig) defaultCount() int {
+	n := len(tc.defaults)
+	if n == 0 || len(tc.Middlewares) < n || &tc.Middlewares[0] != &tc.defaults[0] {
+		return 0
+	}
+	return n
+}
+
+// WithNamedMiddleware adds middleware to the TestConfig under name, which
+// stands for it in Result.WrittenBy, Timing.Layers and PanicError.
+func (tc *TestConfig) WithNamedMiddleware(name string, middleware func(http.Handler) http.Handler) *TestConfig {
+	tc.mwNames = append(tc.mwNames, name)
+	n := tc.defaultCount()
+	tc.Middlewares = append(tc.Middlewares, middleware)
+	tc.defaults = tc.Middlewares[:n]
+	return tc
+}
+
+// SkipMiddleware leaves the middlewares named name out of the chain, keeping
+// the others in place, as to test the unauthenticated path of a config with an
+// auth middleware. Unnamed middlewares are named after their position, as
+// "middleware[2]". Run fails when no middleware has the name. Skip on a Clone
+// to keep the base config whole:
+//
+//	result, err := base.Clone().SkipMiddleware("auth").Run(ctx)
+func (tc *TestConfig) SkipMiddleware(name string) *TestConfig {
+	tc.skipMWs = append(tc.skipMWs, name)
+	return tc
+}
+
+// OnlyMiddlewares applies only the middlewares named names, in their order in
+// the chain, replacing the previous call. Middlewares left out by
+// SkipMiddleware stay out. Run fails when a name matches no middleware.
+func (tc *TestConfig) OnlyMiddlewares(names ...string) *TestConfig {
+	tc.onlyMWs = append([]string{}, names...)
+	return tc
+}
+
+// validateMiddlewareFilter checks the names given to SkipMiddleware and
+// OnlyMiddlewares against the names of the middlewares.
+func (tc *TestConfig) validateMiddlewareFilter() error {
+	known := make([]string, len(tc.Middlewares))
+	for i := range tc.Middlewares {
+		known[i] = tc.middlewareName(i)
+	}
+	for _, name := range slices.Concat(tc.skipMWs, tc.onlyMWs) {
+		if !slices.Contains(known, name) {
+			return fmt.Errorf("unknown middleware %q, the middlewares are: %s", name, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+// middlewareApplied reports whether Middlewares[i] is in the chain, given
+// SkipMiddleware and OnlyMiddlewares.
+func (tc *TestConfig) middlewareApplied(i int) bool {
+	name := tc.middlewareName(i)
+	if slices.Contains(tc.skipMWs, name) {
+		return false
+	}
+	return tc.onlyMWs == nil || slices.Contains(tc.onlyMWs, name)
+}
+
+// middlewareName returns the name of Middlewares[i], positional for the
+// middlewares set on the field directly.
+func (tc *TestConfig) middlewareName(i int) string {
+	if i < len(tc.mwNames) && len(tc.mwNames) == len(tc.Middlewares) {
+		return tc.mwNames[i]
+	}
+	return fmt.Sprintf("middleware[%d]", i)
+}
+
+// Run executes the test with the current configuration. When the response
+// body does not match the schema set with WithResponseSchema, or the request
+// or the response break the Contract, the Result is returned along with the
+// error. The hooks added with OnBeforeRun and OnAfterRun, and the default
+// ones, run around it, and it is traced by the Tracer set with WithTracer.
 func (tc *TestConfig) Run(ctx context.Context) (*Result, error) {
-	// Validate required fields
-	if tc.RouteFunc == nil {
-		return nil, errors.New("handler cannot be nil")
+	ctx, end := tc.startTrace(ctx)
+	result, err := tc.afterRun(tc.run(ctx))
+	end(result, err)
+	return result, err
+}
+
+func (tc *TestConfig) run(ctx context.Context) (*Result, error) {
+	start := time.Now()
+	if err := tc.Validate(); err != nil {
+		return nil, err
 	}
-	if tc.Path == "" {
-		return nil, errors.New("path cannot be empty")
+	path, err := tc.requestPath()
+	if err != nil {
+		return nil, err
 	}
 
-	// Set defaults for optional fields
-	method := "GET"
-	if tc.Method != "" {
-		method = tc.Method
+	// Set defaults for optional fields. A method prefix in URLPattern, as in
+	// "POST /items", is used when Method is not set.
+	routeMethod, urlPattern := tc.routePattern(path)
+	method := tc.requestMethod(routeMethod)
+
+	if tc.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, tc.Timeout)
+		defer cancel()
+	}
+
+	if tc.BaseURL != "" {
+		return tc.runRemote(ctx, start, method, path, urlPattern)
 	}
 
 	// Create request
-	req, err := http.NewRequestWithContext(ctx, method, tc.Path, tc.Body)
+	body, contentType, err := tc.openBody()
+	if err != nil {
+		return nil, err
+	}
+	// Streamed bodies stop being produced once the request is served.
+	if c, ok := body.(io.Closer); ok && tc.body != nil {
+		defer c.Close()
+	}
+	// StopAfterEvents cancels the request alone, so it does not look like a
+	// timeout.
+	reqCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	req, err := tc.newRequest(reqCtx, method, path, body, contentType)
 	if err != nil {
 		return nil, err
 	}
+	if err := tc.beforeRun(req); err != nil {
+		return nil, err
+	}
 
-	// Add headers to request
-	if len(tc.Headers) > 0 {
-		for key, value := range tc.Headers {
-			req.Header.Set(key, value)
-		}
+	// Router middlewares run inside the router when it can take them.
+	attached := useRouterMiddlewares(tc.Router)
+	if attached {
+		req = withRouterMiddlewares(req, tc.RouterMiddlewares)
 	}
 
-	// Apply middlewares to handler in reverse order because they were
-	handler := http.Handler(http.HandlerFunc(tc.RouteFunc))
-	if len(tc.Middlewares) > 0 {
-		for i := len(tc.Middlewares) - 1; i >= 0; i-- {
-			handler = tc.Middlewares[i](handler)
-		}
+	serve, urlPattern, err := tc.handler(routeMethod, urlPattern, attached)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create response recorder
 	rr := httptest.NewRecorder()
+	w := tc.recorder(rr)
+	chunks := newChunkRecorder(w, rr)
+	calls := newControllerRecorder(tc.limitEvents(chunks, stop))
+	hijacker := newHijackWriter(calls, tc.AllowHijack)
+	requestDump := dumpRequest(req)
+	matched := recordCoverage(tc.Router, req)
+	contractPattern := strings.TrimSuffix(tc.MountAt, "/") + urlPattern
+	if tc.UseExistingRoutes && matched != "" {
+		contractPattern = matched
+	}
+	var contractErr error
+	if tc.contract != nil {
+		contractErr = tc.contract.CheckRequest(req, contractPattern)
+	}
+	serveStart := time.Now()
+	tracker := &layerTracker{}
+	if partial, err := tc.serveHTTP(ctx, serve, hijacker, rr, tracker.attach(req)); err != nil {
+		partial.Duration = time.Since(start)
+		partial.requestDump = requestDump
+		partial.request = req
+		tracker.record(partial, tc.ProfileMiddlewares, tc.TraceMiddlewares)
+		return partial, err
+	}
+	if _, err := hijacker.conn(); err != nil {
+		return nil, err
+	}
 
-	urlPattern := tc.Path
-	if tc.URLPattern != "" {
-		urlPattern = tc.URLPattern
+	// Follow redirects by replaying the request against the router
+	var redirects []Redirect
+	for tc.FollowRedirects > 0 && isRedirect(rr.Code) {
+		if len(redirects) == tc.FollowRedirects {
+			return nil, fmt.Errorf("stopped after %d redirects", len(redirects))
+		}
+		location, err := req.URL.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid redirect location: %w", err)
+		}
+		redirects = append(redirects, Redirect{StatusCode: rr.Code, Location: location})
+		if tc.session != nil {
+			tc.session.store(req, rr.Result().Header)
+		}
+		if req, err = redirectRequest(req, rr.Code, location); err != nil {
+			return nil, err
+		}
+		if tc.secure || req.URL.Scheme == "https" {
+			tc.useTLS(req)
+		}
+		req.RemoteAddr = tc.remoteAddr
+		if tc.session != nil {
+			// The jar decides which cookies go to the new location.
+			req.Header.Del("Cookie")
+			for _, c := range tc.cookies {
+				req.AddCookie(c)
+			}
+			tc.session.attach(req)
+		}
+		for _, modify := range tc.modifiers {
+			modify(req)
+		}
+		rr = httptest.NewRecorder()
+		w = tc.recorder(rr)
+		chunks = newChunkRecorder(w, rr)
+		calls = newControllerRecorder(tc.limitEvents(chunks, stop))
+		hijacker = newHijackWriter(calls, tc.AllowHijack)
+		requestDump = dumpRequest(req)
+		matched = recordCoverage(tc.Router, req)
+		tracker = &layerTracker{}
+		if partial, err := tc.serveHTTP(ctx, serve, hijacker, rr, tracker.attach(req)); err != nil {
+			partial.Duration = time.Since(start)
+			partial.Redirects = redirects
+			partial.requestDump = requestDump
+			partial.request = req
+			tracker.record(partial, tc.ProfileMiddlewares, tc.TraceMiddlewares)
+			return partial, err
+		}
+		if _, err := hijacker.conn(); err != nil {
+			return nil, err
+		}
 	}
-	tc.Router.Handle(urlPattern, handler)
-	tc.Router.ServeHTTP(rr, req)
 
-	// Extract response headers
+	readStart := time.Now()
+
+	// Extract response headers as they were sent, that is without trailers and
+	// changes made after the header was written
+	response := rr.Result()
 	responseHeaders := make(map[string]string)
-	for key, values := range rr.Header() {
+	for key, values := range response.Header {
 		if len(values) > 0 {
 			responseHeaders[key] = strings.Join(values, ", ")
 		}
 	}
 
-	// Read response body
-	bodyBytes, err := io.ReadAll(rr.Body)
+	if tc.session != nil {
+		tc.session.store(req, response.Header)
+	}
+
+	// Read response body, leaving the recorder's body for Recorder
+	rawBody := bytes.Clone(rr.Body.Bytes())
+	bodyBytes := rawBody
+	written, truncated := int64(len(rawBody)), false
+	if l, ok := w.(*limitedRecorder); ok {
+		if l.err != nil {
+			return nil, fmt.Errorf("writing the response body to the sink: %w", l.err)
+		}
+		written, truncated = l.written, l.truncated
+	}
+	// A truncated body cannot be decompressed.
+	if !tc.DisableDecompression && !truncated {
+		if bodyBytes, err = decodeBody(response.Header.Values("Content-Encoding"), rawBody); err != nil {
+			return nil, err
+		}
+	}
+
+	var location *url.URL
+	if loc := response.Header.Get("Location"); loc != "" {
+		location, _ = req.URL.Parse(loc)
+	}
+	end := time.Now()
+
+	result := &Result{
+		Headers:      responseHeaders,
+		RawHeaders:   response.Header,
+		Trailers:     response.Trailer,
+		StatusCode:   rr.Code,
+		Body:         bodyBytes,
+		RawBody:      rawBody,
+		BytesWritten: written,
+		Truncated:    truncated,
+		Location:     location,
+		Redirects:    redirects,
+		Pattern:      matched,
+		Proto:        response.Proto,
+		Duration:     end.Sub(start),
+		Timing: Timing{
+			Setup:    serveStart.Sub(start),
+			Handler:  readStart.Sub(serveStart),
+			BodyRead: end.Sub(readStart),
+		},
+		recorder:    rr,
+		response:    response,
+		requestDump: requestDump,
+		request:     req,
+	}
+	tracker.record(result, tc.ProfileMiddlewares, tc.TraceMiddlewares)
+	chunks.record(result, rawBody)
+	calls.record(result)
+	result.HijackedConn, _ = hijacker.conn()
+	if tc.contract != nil {
+		if len(redirects) > 0 {
+			contractPattern = matched
+		}
+		contractErr = errors.Join(contractErr, tc.contract.CheckResponse(req, contractPattern, result))
+	}
+	if err := errors.Join(contractErr, tc.validateSchema(result)); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// WithRoute registers handler for method on the same pattern as RouteFunc, so
+// the router knows every verb the route supports. A request whose method none
+// of them accepts gets the router's own 405 response and Allow header.
+func (tc *TestConfig) WithRoute(method string, handler func(http.ResponseWriter, *http.Request)) *TestConfig {
+	tc.routes = append(tc.routes, methodRoute{method: method, handler: handler})
+	return tc
+}
+
+// WithExtraRoute registers handler on another pattern, which may start with a
+// method as in "GET /users/new", next to the route under test. Every route is
+// registered before the request is served, so Result.Pattern tells which one
+// the router picked.
+func (tc *TestConfig) WithExtraRoute(pattern string, handler http.HandlerFunc) *TestConfig {
+	tc.extraRoutes = append(tc.extraRoutes, patternRoute{pattern: pattern, handler: handler})
+	return tc
+}
+
+// methodRoutes lists the handlers to register: RouteFunc for routeMethod,
+// if set, followed by the ones added with WithRoute.
+func (tc *TestConfig) methodRoutes(routeMethod string) []methodRoute {
+	var routes []methodRoute
+	if tc.RouteFunc != nil {
+		routes = append(routes, methodRoute{method: routeMethod, handler: tc.RouteFunc})
+	}
+	return append(routes, tc.routes...)
+}
+
+// newRequest builds the request of tc with body, as sent by the client.
+func (tc *TestConfig) newRequest(ctx context.Context, method, path string, body io.Reader, contentType string) (*http.Request, error) {
+	for _, cv := range tc.values {
+		ctx = context.WithValue(ctx, cv.key, cv.value)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, path, body)
 	if err != nil {
 		return nil, err
 	}
+	if f, ok := body.(*fileBody); ok {
+		f.attach(req)
+	}
+	// As on a server, the body is never nil and a body of unknown length is
+	// announced as such.
+	if req.Body == nil {
+		req.Body = http.NoBody
+	} else if req.Body != http.NoBody && req.ContentLength == 0 {
+		req.ContentLength = -1
+	}
+	query := tc.query
+	if tc.graphQL != nil {
+		if query, err = tc.graphQL.values(query); err != nil {
+			return nil, err
+		}
+	}
+	if len(query) > 0 {
+		if req.URL.RawQuery != "" {
+			req.URL.RawQuery += "&"
+		}
+		req.URL.RawQuery += query.Encode()
+	}
+	// Keep the path as sent, so routers reading RequestURI or RawPath see
+	// escaped segments such as %2F the way a server would deliver them.
+	req.RequestURI = req.URL.RequestURI()
+
+	if tc.Host != "" {
+		req.Host = tc.Host
+		req.URL.Host = tc.Host
+	}
+
+	// Add headers to request
+	if len(tc.Headers) > 0 {
+		for key, value := range tc.Headers {
+			req.Header.Set(key, value)
+		}
+	}
+	for key, values := range tc.added {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	if contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, c := range tc.cookies {
+		req.AddCookie(c)
+	}
+	if tc.secure {
+		tc.useTLS(req)
+	}
+	if tc.remoteAddr != "" {
+		req.RemoteAddr = tc.remoteAddr
+	}
+	if tc.session != nil {
+		tc.session.attach(req)
+	}
+	if len(tc.trailer) > 0 {
+		useTrailer(req, tc.trailer)
+	}
+	for _, modify := range tc.modifiers {
+		modify(req)
+	}
+	return req, nil
+}
+
+// routePattern returns the method and pattern of the route serving path.
+func (tc *TestConfig) routePattern(path string) (method, pattern string) {
+	pattern = path
+	if tc.URLPattern != "" {
+		pattern = tc.URLPattern
+	}
+	method, pattern = splitPattern(pattern)
+	if method == "" {
+		method = tc.Method
+	}
+	return method, pattern
+}
+
+// requestMethod returns the method of the request, GET unless Method or the
+// method of the route say otherwise.
+func (tc *TestConfig) requestMethod(routeMethod string) string {
+	if tc.Method != "" {
+		return tc.Method
+	}
+	if routeMethod != "" {
+		return routeMethod
+	}
+	return "GET"
+}
+
+// handler registers the routes of tc on its router, unless UseExistingRoutes
+// is set, and returns the handler serving its requests along with the pattern
+// the route was registered under.
+func (tc *TestConfig) handler(routeMethod, urlPattern string, attached bool) (http.Handler, string, error) {
+	prefix := strings.TrimSuffix(tc.PathPrefix, "/")
+	if tc.UseExistingRoutes {
+		var handler http.Handler = tc.Router
+		if !attached {
+			handler = chain(handler, tc.RouterMiddlewares)
+		}
+		handler = tc.applyMiddlewares(handler)
+		if prefix != "" {
+			handler = http.StripPrefix(prefix, handler)
+		}
+		return handler, urlPattern, nil
+	}
+
+	target := tc.Router
+	if tc.MountAt != "" {
+		var err error
+		if target, err = mount(tc.Router, tc.MountAt); err != nil {
+			return nil, "", err
+		}
+	}
+	hostPattern := tc.Host
+	if tc.HostPattern != "" {
+		hostPattern = tc.HostPattern
+	}
+	if prefix != "" {
+		urlPattern = prefix + urlPattern
+	}
 
-	return &Result{
-		Headers:    responseHeaders,
-		StatusCode: rr.Code,
-		Body:       bodyBytes,
-	}, nil
+	// Build the handler chain of every route this config registers.
+	handlers := make(map[route]http.Handler)
+	add := func(rt route, h http.HandlerFunc) {
+		handler := tc.applyMiddlewares(h)
+		if prefix != "" {
+			handler = http.StripPrefix(prefix, handler)
+		}
+		if !attached {
+			handler = chain(handler, tc.RouterMiddlewares)
+		}
+		register(target, rt, handler)
+		handlers[rt] = handler
+	}
+	for _, mr := range tc.methodRoutes(routeMethod) {
+		add(route{method: mr.method, host: hostPattern, pattern: urlPattern}, mr.handler)
+	}
+	for _, er := range tc.extraRoutes {
+		method, pattern := splitPattern(er.pattern)
+		add(route{method: method, host: hostPattern, pattern: prefix + pattern}, er.handler)
+	}
+	serve := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tc.Router.ServeHTTP(w, withRouteHandlers(r, handlers))
+	})
+	return serve, urlPattern, nil
+}
+
+// WithRouterMiddlewares adds middlewares attached to the router itself
+func (tc *TestConfig) WithRouterMiddlewares(middlewares ...func(http.Handler) http.Handler) *TestConfig {
+	tc.RouterMiddlewares = append(tc.RouterMiddlewares, middlewares...)
+	return tc
+}
+
+// applyMiddlewares wraps handler with the configured middlewares, tracking
+// which layer writes the response.
+func (tc *TestConfig) applyMiddlewares(handler http.Handler) http.Handler {
+	handler = trackLayer(handler, Layer{Index: len(tc.Middlewares), Name: "handler", Handler: true})
+	for i := len(tc.Middlewares) - 1; i >= 0; i-- {
+		if tc.middlewareApplied(i) {
+			handler = trackLayer(tc.Middlewares[i](handler), Layer{Index: i, Name: tc.middlewareName(i)})
+		}
+	}
+	return handler
 }
 
-// Init creates a new TestConfig with a given Router
-func Init(r Router) *TestConfig {
-	return &TestConfig{
-		Router: r,
+// chain wraps handler with middlewares. They are applied in reverse order so
+// the first middleware is the outermost one.
+func chain(handler http.Handler, middlewares []func(http.Handler) http.Handler) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
 	}
+	return handler
+}
+
+// Init creates a new TestConfig with a given router. The router is adapted
+// with the adapters added through RegisterAdapter and the built-in ones for
+// ServeMux, chi, gorilla, gin, echo, httprouter, fiber and httptreemux; anything else must
+// implement Router. Run reports a router that could not be adapted. Options
+// set defaults for the config.
+func Init(r any, opts ...Option) *TestConfig {
+	router, err := adapt(r)
+	tc := &TestConfig{
+		Router:    router,
+		routerErr: err,
+	}
+	defaultMiddlewares.RLock()
+	tc.WithMiddlewares(defaultMiddlewares.list...)
+	defaultMiddlewares.RUnlock()
+	for _, opt := range opts {
+		opt(tc)
+	}
+	tc.defaults = tc.Middlewares
+	return tc
+}
+
+// Clone returns a copy of tc that can be changed and run without affecting
+// tc. Headers, middlewares, query, cookies, form fields and the other
+// settings are copied; Body and the file contents of a multipart body are
+// readers, which the copy shares with tc.
+func (tc *TestConfig) Clone() *TestConfig {
+	c := *tc
+	c.Headers = maps.Clone(tc.Headers)
+	c.Middlewares = slices.Clone(tc.Middlewares)
+	c.defaults = c.Middlewares[:tc.defaultCount()]
+	c.mwNames = slices.Clone(tc.mwNames)
+	c.skipMWs = slices.Clone(tc.skipMWs)
+	c.onlyMWs = slices.Clone(tc.onlyMWs)
+	c.RouterMiddlewares = slices.Clone(tc.RouterMiddlewares)
+	c.routes = slices.Clone(tc.routes)
+	c.added = tc.added.Clone()
+	c.extraRoutes = slices.Clone(tc.extraRoutes)
+	c.query = cloneValues(tc.query)
+	c.cookies = make([]*http.Cookie, len(tc.cookies))
+	for i, cookie := range tc.cookies {
+		copied := *cookie
+		c.cookies[i] = &copied
+	}
+	c.form = cloneValues(tc.form)
+	c.trailer = tc.trailer.Clone()
+	c.pathParams = maps.Clone(tc.pathParams)
+	c.modifiers = slices.Clone(tc.modifiers)
+	c.values = slices.Clone(tc.values)
+	c.clientCerts = slices.Clone(tc.clientCerts)
+	c.schemaSkip = slices.Clone(tc.schemaSkip)
+	c.beforeHooks = slices.Clone(tc.beforeHooks)
+	c.afterHooks = slices.Clone(tc.afterHooks)
+	return &c
+}
+
+func cloneValues(v url.Values) url.Values {
+	if v == nil {
+		return nil
+	}
+	return url.Values(http.Header(v).Clone())
+}
+
+// Option sets defaults on the TestConfig created by Init. Options can be
+// shared by many configs, and settings made on a config override them.
+type Option func(*TestConfig)
+
+// WithDefaultHeaders sets request headers.
+func WithDefaultHeaders(headers ...HeaderFunc) Option {
+	return func(tc *TestConfig) {
+		tc.WithHeaders(headers...)
+	}
+}
+
+// WithDefaultMethod sets the request method.
+func WithDefaultMethod(method string) Option {
+	return func(tc *TestConfig) {
+		tc.Method = method
+	}
+}
+
+// WithDefaultMiddlewares adds middlewares, which wrap the ones added later.
+// They come after the ones set by SetDefaultMiddlewares, and
+// WithoutDefaultMiddlewares removes them as well.
+func WithDefaultMiddlewares(middlewares ...func(http.Handler) http.Handler) Option {
+	return func(tc *TestConfig) {
+		tc.WithMiddlewares(middlewares...)
+	}
+}
+
+// Get creates a TestConfig for a GET request to path.
+func Get(r any, path string) *TestConfig {
+	return newRequestConfig(r, http.MethodGet, path)
+}
+
+// Head creates a TestConfig for a HEAD request to path.
+func Head(r any, path string) *TestConfig {
+	return newRequestConfig(r, http.MethodHead, path)
+}
+
+// Options creates a TestConfig for an OPTIONS request to path.
+func Options(r any, path string) *TestConfig {
+	return newRequestConfig(r, http.MethodOptions, path)
+}
+
+// Delete creates a TestConfig for a DELETE request to path.
+func Delete(r any, path string) *TestConfig {
+	return newRequestConfig(r, http.MethodDelete, path)
+}
+
+// Post creates a TestConfig for a POST request to path sending body.
+func Post(r any, path, body string) *TestConfig {
+	tc := newRequestConfig(r, http.MethodPost, path)
+	tc.SetBodyString(body)
+	return tc
+}
+
+// Put creates a TestConfig for a PUT request to path sending body.
+func Put(r any, path, body string) *TestConfig {
+	tc := newRequestConfig(r, http.MethodPut, path)
+	tc.SetBodyString(body)
+	return tc
+}
+
+// Patch creates a TestConfig for a PATCH request to path sending body.
+func Patch(r any, path, body string) *TestConfig {
+	tc := newRequestConfig(r, http.MethodPatch, path)
+	tc.SetBodyString(body)
+	return tc
+}
+
+func newRequestConfig(r any, method, path string) *TestConfig {
+	tc := Init(r)
+	tc.Method = method
+	tc.Path = path
+	return tc
 }
 
 func (b Body) String() string {
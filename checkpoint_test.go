@@ -2,17 +2,33 @@ package checkpoint
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/dimfeld/httptreemux/v5"
+	"github.com/gin-gonic/gin"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/gofiber/fiber/v2"
 	"github.com/gorilla/mux"
+	"github.com/julienschmidt/httprouter"
+	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
 func Test_RunBodyPassthrough(t *testing.T) {
 	ctx := context.Background()
 	urlPath := "/test"
@@ -37,7 +53,7 @@ func Test_RunBodyPassthrough(t *testing.T) {
 	conf.RouteFunc = handler
 	conf.Path = urlPath
 	conf.Method = method
-	conf.Body = body
+	conf.SetBodyString(body)
 
 	result, err := conf.Run(ctx)
 	if err != nil {
@@ -74,7 +90,7 @@ func Test_RunWithHeadersPassthrough(t *testing.T) {
 	conf.URLPattern = urlPattern
 	conf.WithHeaders(
 		Header("X-Test-Header", "TestValue"))
-	conf.Body = body
+	conf.SetBodyString(body)
 	// Check the test
 	result, err := conf.Run(ctx)
 	if err != nil {
@@ -117,7 +133,7 @@ func Test_RunWithMiddlewares(t *testing.T) {
 	conf.Path = urlPath
 	conf.URLPattern = urlPattern
 	conf.WithMiddlewares(middleware)
-	conf.Body = body
+	conf.SetBodyString(body)
 	result, err := conf.Run(ctx)
 	if err != nil {
 		t.Fatalf("Check failed: %v", err)
@@ -170,7 +186,7 @@ func Test_RunWithMiddlewaresStacked(t *testing.T) {
 		middleware1,
 		middleware2,
 	)
-	conf.Body = body
+	conf.SetBodyString(body)
 	result, err := conf.Run(ctx)
 	if err != nil {
 		t.Fatalf("Check failed: %v", err)
@@ -210,7 +226,7 @@ func Test_RunWithMiddlewaresError(t *testing.T) {
 	conf.Path = urlPath
 	conf.URLPattern = urlPattern
 	conf.WithMiddlewares(middleware)
-	conf.Body = body
+	conf.SetBodyString(body)
 	result, err := conf.Run(ctx)
 	if err != nil {
 		t.Fatalf("Check failed: %v", err)
@@ -250,6 +266,30 @@ func Test_RunWithPathParameters(t *testing.T) {
 				return ""
 			},
 		},
+		{
+			router: &RouterAdapter{gin.New()},
+			parseFunc: func(r *http.Request) string {
+				return GinContext(r).Param("id")
+			},
+		},
+		{
+			router: &RouterAdapter{echo.New()},
+			parseFunc: func(r *http.Request) string {
+				return EchoContext(r).Param("id")
+			},
+		},
+		{
+			router: &RouterAdapter{httprouter.New()},
+			parseFunc: func(r *http.Request) string {
+				return httprouter.ParamsFromContext(r.Context()).ByName("id")
+			},
+		},
+		{
+			router: &RouterAdapter{fiber.New()},
+			parseFunc: func(r *http.Request) string {
+				return FiberContext(r).Params("id")
+			},
+		},
 	}
 
 	for i, test := range tc {
@@ -277,3 +317,1480 @@ func Test_RunWithPathParameters(t *testing.T) {
 			"failure in the test case: %d", i)
 	}
 }
+
+func Test_RunWithGinHandler(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(&RouterAdapter{gin.New()})
+	conf.RouteFunc = GinHandler(func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id"), "rest": c.Param("rest")})
+	})
+	conf.Path = "/files/42/a/b.txt"
+	conf.URLPattern = "/files/{id}/{rest...}"
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.JSONEq(t, `{"id": "42", "rest": "/a/b.txt"}`, string(result.Body))
+}
+
+func Test_ColonPattern(t *testing.T) {
+	assert.Equal(t, "/test/:id", colonPattern("/test/{id}"))
+	assert.Equal(t, "/a/:b/c/*rest", colonPattern("/a/{b}/c/{rest...}"))
+	assert.Equal(t, "/already/:id", colonPattern("/already/:id"))
+}
+
+func Test_RunWithEchoMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Response().Header().Set("X-Echo-Middleware", "ran")
+			return next(c)
+		}
+	})
+
+	conf := Init(&RouterAdapter{e})
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(EchoContext(r).Param("id")))
+	}
+	conf.Path = "/test/123"
+	conf.URLPattern = "/test/:id"
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "ran", result.Headers["X-Echo-Middleware"])
+	assert.Equal(t, "123", result.Body.String())
+}
+
+func Test_RunWithHTTPRouterMethod(t *testing.T) {
+	ctx := context.Background()
+
+	for _, method := range []string{"", http.MethodPost} {
+		conf := Init(&RouterAdapter{httprouter.New()})
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(r.Method + " " + httprouter.ParamsFromContext(r.Context()).ByName("id")))
+		}
+		conf.Path = "/test/123"
+		conf.URLPattern = "/test/:id"
+		conf.Method = method
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+
+		assert.Equal(t, http.StatusOK, result.StatusCode, "method %q", method)
+		if method == "" {
+			method = http.MethodGet
+		}
+		assert.Equal(t, method+" 123", result.Body.String())
+	}
+}
+
+func Test_RunWithExistingRoutes(t *testing.T) {
+	ctx := context.Background()
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Router-Middleware", "ran")
+			next.ServeHTTP(w, r)
+		})
+	})
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("user " + chi.URLParam(r, "id")))
+	})
+
+	conf := Init(r)
+	conf.UseExistingRoutes = true
+	conf.Path = "/users/7"
+
+	// Running twice must not register anything on the router.
+	for range 2 {
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.Equal(t, "ran", result.Headers["X-Router-Middleware"])
+		assert.Equal(t, "user 7", result.Body.String())
+	}
+
+	conf.UseExistingRoutes = false
+	_, err := conf.Run(ctx)
+	assert.EqualError(t, err, "handler cannot be nil")
+}
+
+func Test_RunRepeatedOnSameConfig(t *testing.T) {
+	ctx := context.Background()
+
+	routers := []Router{
+		http.NewServeMux(),
+		chi.NewRouter(),
+		&RouterAdapter{mux.NewRouter()},
+		&RouterAdapter{gin.New()},
+		&RouterAdapter{echo.New()},
+		&RouterAdapter{httprouter.New()},
+	}
+
+	for i, router := range routers {
+		conf := Init(router)
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.Copy(w, r.Body)
+		}
+		conf.Path = "/test"
+		conf.Method = http.MethodPost
+
+		for _, body := range []string{"first", "second", "third"} {
+			conf.SetBodyString(body)
+			result, err := conf.Run(ctx)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+
+			assert.Equal(t, body, result.Body.String(), "failure in the test case: %d", i)
+		}
+
+		// A different handler on the same route must be reached as well.
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, http.StatusAccepted, result.StatusCode, "failure in the test case: %d", i)
+	}
+}
+
+func Test_RunMethodNotAllowed(t *testing.T) {
+	ctx := context.Background()
+
+	routers := []Router{
+		http.NewServeMux(),
+		chi.NewRouter(),
+		&RouterAdapter{mux.NewRouter()},
+		&RouterAdapter{echo.New()},
+		&RouterAdapter{httprouter.New()},
+	}
+
+	for i, router := range routers {
+		conf := Init(router)
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}
+		conf.Path = "/test"
+		conf.Method = http.MethodPost
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, http.StatusOK, result.StatusCode, "failure in the test case: %d", i)
+
+		// Hit the POST-only route with the wrong verb without registering it again.
+		conf.UseExistingRoutes = true
+		conf.Method = http.MethodGet
+		result, err = conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, http.StatusMethodNotAllowed, result.StatusCode, "failure in the test case: %d", i)
+	}
+}
+
+func Test_RunWithUnsupportedRouter(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := NewRouterAdapter(http.NewServeMux())
+	assert.ErrorIs(t, err, ErrUnsupportedRouter)
+	assert.EqualError(t, err, "unsupported router type: *http.ServeMux")
+
+	adapter, err := NewRouterAdapter(mux.NewRouter())
+	assert.NoError(t, err)
+	assert.NotNil(t, adapter)
+
+	reached := false
+	conf := Init(&RouterAdapter{"not a router"})
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}
+	conf.Path = "/test"
+
+	result, err := conf.Run(ctx)
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrUnsupportedRouter)
+	assert.Contains(t, err.Error(), "string")
+	assert.False(t, reached)
+}
+
+// toyRouter is a minimal router that does not implement the Router interface.
+type toyRouter struct {
+	routes map[string]http.Handler
+}
+
+func (t *toyRouter) Register(path string, h http.Handler) {
+	t.routes[path] = h
+}
+
+func (t *toyRouter) Dispatch(w http.ResponseWriter, r *http.Request) {
+	h, ok := t.routes[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+type toyAdapter struct {
+	toy *toyRouter
+}
+
+func (a toyAdapter) Handle(pattern string, h http.Handler)            { a.toy.Register(pattern, h) }
+func (a toyAdapter) ServeHTTP(w http.ResponseWriter, r *http.Request) { a.toy.Dispatch(w, r) }
+
+func Test_RunWithRegisteredAdapter(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(&toyRouter{routes: map[string]http.Handler{}})
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+	conf.Path = "/toy"
+	_, err := conf.Run(ctx)
+	assert.ErrorIs(t, err, ErrUnsupportedRouter)
+	assert.Contains(t, err.Error(), "*checkpoint.toyRouter")
+
+	t.Cleanup(resetAdapters)
+	RegisterAdapter(func(v any) (Router, bool) {
+		toy, ok := v.(*toyRouter)
+		if !ok {
+			return nil, false
+		}
+		return toyAdapter{toy}, true
+	})
+
+	conf = Init(&toyRouter{routes: map[string]http.Handler{}})
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("toy"))
+	}
+	conf.Path = "/toy"
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusTeapot, result.StatusCode)
+	assert.Equal(t, "toy", result.Body.String())
+
+	// Built-in routers keep working alongside the registered adapter.
+	assert.IsType(t, &RouterAdapter{}, Init(mux.NewRouter()).Router)
+	assert.IsType(t, &http.ServeMux{}, Init(http.NewServeMux()).Router)
+}
+
+// resetAdapters removes the adapters added with RegisterAdapter.
+func resetAdapters() {
+	adapters.Lock()
+	defer adapters.Unlock()
+	adapters.registered = nil
+}
+
+func Test_RunConfigsSharingARouter(t *testing.T) {
+	ctx := context.Background()
+
+	// Init wraps the router anew for each config, yet its routes are
+	// registered once.
+	routers := []any{gin.New(), httprouter.New(), echo.New(), httptreemux.NewContextMux()}
+	for i, router := range routers {
+		for _, body := range []string{"first", "second"} {
+			conf := Get(router, "/items")
+			conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(body))
+			}
+			result, err := conf.Run(ctx)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			assert.Equal(t, body, result.Body.String(), "failure in the test case: %d", i)
+		}
+	}
+}
+
+func Test_RunWithChiMount(t *testing.T) {
+	ctx := context.Background()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Route-Pattern", chi.RouteContext(r.Context()).RoutePattern())
+		_, _ = w.Write([]byte(chi.URLParam(r, "id")))
+	}
+
+	admin := chi.NewRouter()
+	admin.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Admin", "true")
+			next.ServeHTTP(w, r)
+		})
+	})
+	parent := chi.NewRouter()
+	parent.Mount("/admin", admin)
+
+	conf := Init(parent)
+	conf.RouteFunc = handler
+	conf.MountAt = "/admin"
+	conf.URLPattern = "/users/{id}"
+	conf.Path = "/admin/users/42"
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "42", result.Body.String())
+	assert.Equal(t, "true", result.Headers["X-Admin"])
+	assert.Equal(t, "/admin/users/{id}", result.Headers["X-Route-Pattern"])
+
+	// Without an existing mount a fresh sub-router is mounted, once.
+	conf = Init(chi.NewRouter())
+	conf.RouteFunc = handler
+	conf.MountAt = "/api"
+	conf.URLPattern = "/items/{id}"
+	conf.Path = "/api/items/7"
+	for range 2 {
+		result, err = conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, "7", result.Body.String())
+		assert.Equal(t, "/api/items/{id}", result.Headers["X-Route-Pattern"])
+	}
+
+	conf = Init(http.NewServeMux())
+	conf.RouteFunc = handler
+	conf.MountAt = "/api"
+	conf.Path = "/api/items/7"
+	_, err = conf.Run(ctx)
+	assert.ErrorIs(t, err, ErrUnsupportedRouter)
+}
+
+func Test_RunWithServeMuxMethodPatterns(t *testing.T) {
+	ctx := context.Background()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Method + " " + r.PathValue("id") + r.PathValue("rest")))
+	}
+
+	tc := []struct {
+		pattern    string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{pattern: "GET /items/{id}", path: "/items/5", wantStatus: http.StatusOK, wantBody: "GET 5"},
+		{pattern: "/items/{id}", method: http.MethodPut, path: "/items/6", wantStatus: http.StatusOK, wantBody: "PUT 6"},
+		{pattern: "POST /items/{id}", method: http.MethodGet, path: "/items/7", wantStatus: http.StatusMethodNotAllowed},
+		{pattern: "/files/{rest...}", path: "/files/a/b/c.txt", wantStatus: http.StatusOK, wantBody: "GET a/b/c.txt"},
+		{pattern: "DELETE /files/{rest...}", path: "/files/x/y", wantStatus: http.StatusOK, wantBody: "DELETE x/y"},
+	}
+
+	for i, test := range tc {
+		conf := Init(http.NewServeMux())
+		conf.RouteFunc = handler
+		conf.URLPattern = test.pattern
+		conf.Method = test.method
+		conf.Path = test.path
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+
+		assert.Equal(t, test.wantStatus, result.StatusCode, "failure in the test case: %d", i)
+		if test.wantStatus == http.StatusOK {
+			assert.Equal(t, test.wantBody, result.Body.String(), "failure in the test case: %d", i)
+		} else {
+			assert.Equal(t, "POST", result.Headers["Allow"], "failure in the test case: %d", i)
+		}
+	}
+}
+
+func Test_RunWithHost(t *testing.T) {
+	ctx := context.Background()
+
+	router := &RouterAdapter{mux.NewRouter()}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		_, _ = fmt.Fprintf(w, "%s:%s", vars["tenant"], vars["id"])
+	}
+
+	conf := Init(router)
+	conf.RouteFunc = handler
+	conf.Host = "acme.example.com"
+	conf.HostPattern = "{tenant}.example.com"
+	conf.URLPattern = "/users/{id}"
+	conf.Path = "/users/9"
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "acme:9", result.Body.String())
+
+	// The host-scoped route must not match other hosts.
+	conf.UseExistingRoutes = true
+	conf.Host = "api.other.com"
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusNotFound, result.StatusCode)
+
+	// ServeMux supports fixed hosts in its patterns.
+	conf = Init(http.NewServeMux())
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}
+	conf.Host = "api.example.com"
+	conf.Path = "/status"
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "api.example.com", result.Body.String())
+
+	// It matches the host of a request without its port.
+	conf = Init(http.NewServeMux())
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}
+	conf.Host = "api.example.com:8080"
+	conf.Path = "/status"
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "api.example.com:8080", result.Body.String())
+}
+
+func Test_RunWithPathPrefix(t *testing.T) {
+	ctx := context.Background()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path + " " + r.PathValue("id")))
+	}
+
+	for _, prefix := range []string{"/api/v1", "/api/v1/"} {
+		conf := Init(http.NewServeMux()).WithPathPrefix(prefix)
+		conf.RouteFunc = handler
+		conf.URLPattern = "/users/{id}"
+		conf.Path = "/api/v1/users/3"
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, http.StatusOK, result.StatusCode, "prefix %q", prefix)
+		assert.Equal(t, "/users/3 3", result.Body.String(), "prefix %q", prefix)
+	}
+
+	for _, path := range []string{"/users/3", "/api/v10/users/3"} {
+		conf := Init(http.NewServeMux()).WithPathPrefix("/api/v1")
+		conf.RouteFunc = handler
+		conf.URLPattern = "/users/{id}"
+		conf.Path = path
+
+		_, err := conf.Run(ctx)
+		assert.EqualError(t, err, "path "+path+" does not start with the prefix /api/v1")
+	}
+}
+
+func Test_RunWithRouterMiddlewares(t *testing.T) {
+	ctx := context.Background()
+
+	// routePattern records the chi route pattern known when the middleware runs,
+	// which is empty for middleware attached with Use since it runs before routing.
+	routePattern := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pattern := "none"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				pattern = rctx.RoutePattern()
+			}
+			w.Header().Set("X-Pattern-Before-Routing", pattern)
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	conf := Init(chi.NewRouter()).WithRouterMiddlewares(middleware.RequestID, routePattern)
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(middleware.GetReqID(r.Context())))
+	}
+	conf.URLPattern = "/items/{id}"
+	conf.Path = "/items/1"
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.NotEmpty(t, result.Body.String())
+	assert.Equal(t, "", result.Headers["X-Pattern-Before-Routing"])
+
+	// Attached to the router, the middlewares also run for unmatched paths.
+	conf.UseExistingRoutes = true
+	conf.Path = "/missing"
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusNotFound, result.StatusCode)
+	assert.Equal(t, "", result.Headers["X-Pattern-Before-Routing"])
+
+	// httprouter has no router-level middleware, so they wrap the handler.
+	conf = Init(httprouter.New()).WithRouterMiddlewares(middleware.RequestID)
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(middleware.GetReqID(r.Context())))
+	}
+	conf.Path = "/items"
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.NotEmpty(t, result.Body.String())
+}
+
+func Test_RunWithRouterMiddlewaresSharedRouter(t *testing.T) {
+	ctx := context.Background()
+
+	// The dispatching middleware is attached to the router once, however
+	// many configs wrap it, so router middlewares run once per request.
+	for i, router := range []any{mux.NewRouter(), gin.New(), echo.New()} {
+		calls := 0
+		counting := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				next.ServeHTTP(w, r)
+			})
+		}
+		for _, path := range []string{"/first", "/second"} {
+			conf := Get(router, path).WithRouterMiddlewares(counting)
+			conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+			if _, err := conf.Run(ctx); err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+		}
+		assert.Equal(t, 2, calls, "failure in the test case: %d", i)
+	}
+}
+
+func Test_RunWithRoutes(t *testing.T) {
+	ctx := context.Background()
+
+	postHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}
+	deleteHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	for i, router := range []Router{http.NewServeMux(), chi.NewRouter()} {
+		conf := Init(router).
+			WithRoute(http.MethodPost, postHandler).
+			WithRoute(http.MethodDelete, deleteHandler)
+		conf.URLPattern = "/items/{id}"
+		conf.Path = "/items/1"
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, http.StatusMethodNotAllowed, result.StatusCode, "failure in the test case: %d", i)
+		assert.ElementsMatch(t, []string{"DELETE", "POST"}, strings.Split(result.Headers["Allow"], ", "),
+			"failure in the test case: %d", i)
+
+		for method, want := range map[string]int{http.MethodPost: http.StatusCreated, http.MethodDelete: http.StatusNoContent} {
+			conf.Method = method
+			result, err = conf.Run(ctx)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			assert.Equal(t, want, result.StatusCode, "failure in the test case: %d", i)
+		}
+	}
+}
+
+func Test_RunWithRedirects(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}
+	conf.URLPattern = "/docs/"
+	conf.Path = "/docs"
+
+	// ServeMux redirects to the pattern with the trailing slash, with a 301 or
+	// a 307 depending on the Go version.
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	redirectCode := result.StatusCode
+	assert.Contains(t, []int{http.StatusMovedPermanently, http.StatusTemporaryRedirect}, redirectCode)
+	assert.Equal(t, "/docs/", result.Location.String())
+	assert.Empty(t, result.Redirects)
+
+	conf.FollowRedirects = 3
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "/docs/", result.Body.String())
+	assert.Nil(t, result.Location)
+	assert.Equal(t, []Redirect{{StatusCode: redirectCode, Location: &url.URL{Path: "/docs/"}}}, result.Redirects)
+
+	// A redirect loop stops after the configured number of hops.
+	conf = Init(http.NewServeMux())
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path, http.StatusFound)
+	}
+	conf.Path = "/loop"
+	conf.FollowRedirects = 2
+	_, err = conf.Run(ctx)
+	assert.EqualError(t, err, "stopped after 2 redirects")
+}
+
+func Test_RunWithFiber(t *testing.T) {
+	ctx := context.Background()
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Set("X-Fiber-Middleware", "ran")
+		return c.Next()
+	})
+
+	conf := Init(app)
+	conf.URLPattern = "/items/{id}"
+	conf.Path = "/items/5?verbose=1"
+	conf.Method = http.MethodPost
+
+	// Every Run reaches its own handler, even though the route is registered once.
+	for _, suffix := range []string{"a", "b"} {
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = fmt.Fprintf(w, "%s %s %s %s %s", r.Method, FiberContext(r).Params("id"),
+				r.URL.Query().Get("verbose"), body, suffix)
+		}
+		conf.SetBodyString("payload")
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, http.StatusCreated, result.StatusCode)
+		assert.Equal(t, "ran", result.Headers["X-Fiber-Middleware"])
+		assert.Equal(t, "POST 5 1 payload "+suffix, result.Body.String())
+	}
+}
+
+func Test_RunWithEncodedSlash(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		router any
+		param  func(r *http.Request) string
+		want   string
+	}{
+		{
+			router: http.NewServeMux(),
+			param:  func(r *http.Request) string { return r.PathValue("name") },
+			want:   "a/b",
+		},
+		{
+			router: chi.NewRouter(),
+			param:  func(r *http.Request) string { return chi.URLParam(r, "name") },
+			want:   "a%2Fb",
+		},
+		{
+			router: httptreemux.NewContextMux(),
+			param:  func(r *http.Request) string { return httptreemux.ContextParams(r.Context())["name"] },
+			want:   "a/b",
+		},
+	}
+
+	for i, tc := range testCases {
+		conf := Init(tc.router)
+		conf.URLPattern = "/files/{name}"
+		conf.Path = "/files/a%2Fb"
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprintf(w, "%s %s", tc.param(r), r.URL.EscapedPath())
+		}
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, http.StatusOK, result.StatusCode, "failure in the test case: %d", i)
+		assert.Equal(t, tc.want+" /files/a%2Fb", result.Body.String(), "failure in the test case: %d", i)
+	}
+}
+
+func Test_RunWithExtraRoutes(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		router  any
+		path    string
+		body    string
+		pattern string
+	}{
+		{router: http.NewServeMux(), path: "/users/new", body: "new", pattern: "/users/new"},
+		{router: http.NewServeMux(), path: "/users/7", body: "user", pattern: "/users/{id}"},
+		{router: http.NewServeMux(), path: "/static/css/site.css", body: "static", pattern: "/static/{path...}"},
+		{router: chi.NewRouter(), path: "/users/new", body: "new", pattern: "/users/new"},
+		{router: chi.NewRouter(), path: "/users/7", body: "user", pattern: "/users/{id}"},
+		{router: &RouterAdapter{mux.NewRouter()}, path: "/users/7", body: "user", pattern: "/users/{id}"},
+	}
+
+	for i, tc := range testCases {
+		conf := Init(tc.router)
+		conf.URLPattern = "/users/{id}"
+		conf.Path = tc.path
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("user"))
+		}
+		conf.WithExtraRoute("GET /users/new", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("new"))
+		})
+		if _, ok := tc.router.(*http.ServeMux); ok {
+			conf.WithExtraRoute("/static/{path...}", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("static"))
+			})
+		}
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, tc.body, result.Body.String(), "failure in the test case: %d", i)
+		assert.Equal(t, tc.pattern, result.Pattern, "failure in the test case: %d", i)
+	}
+}
+
+func Test_RunWithQuery(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/search"
+	conf.Path = "/search?page=2"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		_, _ = fmt.Fprintf(w, "%s|%s|%s|%s", q.Get("page"), strings.Join(q["tag"], ","), q.Get("q"), r.URL.RawQuery)
+	}
+	conf.WithQuery("tag", "a").
+		WithQuery("tag", "b").
+		WithQueryValues(url.Values{"q": {"fish & chips/50%"}})
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "2|a,b|fish & chips/50%|page=2&q=fish+%26+chips%2F50%25&tag=a&tag=b", result.Body.String())
+}
+
+func Test_RunWithCookies(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(chi.NewRouter())
+	conf.URLPattern = "/login"
+	conf.Path = "/login"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		theme, err := r.Cookie("theme")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/", HttpOnly: true, Secure: true, SameSite: http.SameSiteStrictMode})
+		http.SetCookie(w, &http.Cookie{Name: "theme", Value: theme.Value, Path: "/app", Expires: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)})
+	}
+	conf.WithCookies(&http.Cookie{Name: "theme", Value: "dark"}, &http.Cookie{Name: "lang", Value: "en"})
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+
+	cookies := result.Cookies()
+	if assert.Len(t, cookies, 2) {
+		assert.Equal(t, "session", cookies[0].Name)
+		assert.Equal(t, "abc", cookies[0].Value)
+		assert.Equal(t, "/", cookies[0].Path)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.True(t, cookies[0].Secure)
+		assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+
+		assert.Equal(t, "theme", cookies[1].Name)
+		assert.Equal(t, "dark", cookies[1].Value)
+		assert.Equal(t, "/app", cookies[1].Path)
+		assert.True(t, cookies[1].Expires.Equal(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)))
+	}
+}
+
+func Test_RunWithBasicAuth(t *testing.T) {
+	ctx := context.Background()
+
+	requireAuth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			if !ok || user != "admin" || pass != "s3cret" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	testCases := []struct {
+		configure func(conf *TestConfig)
+		status    int
+	}{
+		{configure: func(conf *TestConfig) {}, status: http.StatusUnauthorized},
+		{configure: func(conf *TestConfig) { conf.WithBasicAuth("admin", "s3cret") }, status: http.StatusOK},
+		{configure: func(conf *TestConfig) { conf.WithBasicAuth("admin", "wrong") }, status: http.StatusUnauthorized},
+		// The last Authorization header set wins, whichever helper set it.
+		{configure: func(conf *TestConfig) {
+			conf.WithBasicAuth("admin", "s3cret").WithHeaders(Header("authorization", "Bearer token"))
+		}, status: http.StatusUnauthorized},
+		{configure: func(conf *TestConfig) {
+			conf.WithHeaders(Header("Authorization", "Bearer token")).WithBasicAuth("admin", "s3cret")
+		}, status: http.StatusOK},
+	}
+
+	for i, tc := range testCases {
+		conf := Init(http.NewServeMux())
+		conf.URLPattern = "/admin"
+		conf.Path = "/admin"
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("welcome"))
+		}
+		conf.WithMiddlewares(requireAuth)
+		tc.configure(conf)
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, tc.status, result.StatusCode, "failure in the test case: %d", i)
+	}
+}
+
+func Test_RunWithBearerToken(t *testing.T) {
+	ctx := context.Background()
+
+	requireToken := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(token))
+		})
+	}
+
+	testCases := []struct {
+		configure func(conf *TestConfig)
+		status    int
+		body      string
+	}{
+		{configure: func(conf *TestConfig) {}, status: http.StatusUnauthorized},
+		{configure: func(conf *TestConfig) { conf.WithBearerToken("jwt") }, status: http.StatusOK, body: "jwt"},
+		{configure: func(conf *TestConfig) { conf.WithAuthorization("Token", "jwt") }, status: http.StatusUnauthorized},
+		{configure: func(conf *TestConfig) { conf.WithAuthorization("Bearer", "other") }, status: http.StatusOK, body: "other"},
+		// The last Authorization header set wins, whichever helper set it.
+		{configure: func(conf *TestConfig) {
+			conf.WithHeaders(Header("Authorization", "Bearer header")).WithBearerToken("jwt")
+		}, status: http.StatusOK, body: "jwt"},
+		{configure: func(conf *TestConfig) {
+			conf.WithBearerToken("jwt").WithBasicAuth("admin", "s3cret")
+		}, status: http.StatusUnauthorized},
+	}
+
+	for i, tc := range testCases {
+		conf := Init(chi.NewRouter())
+		conf.URLPattern = "/me"
+		conf.Path = "/me"
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+		conf.WithMiddlewares(requireToken)
+		tc.configure(conf)
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, tc.status, result.StatusCode, "failure in the test case: %d", i)
+		assert.Equal(t, tc.body, result.Body.String(), "failure in the test case: %d", i)
+	}
+}
+
+func Test_RunWithPathParams(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		router any
+		param  func(r *http.Request, name string) string
+	}{
+		{router: http.NewServeMux(), param: func(r *http.Request, name string) string { return r.PathValue(name) }},
+		{router: chi.NewRouter(), param: chi.URLParam},
+		{router: &RouterAdapter{mux.NewRouter()}, param: func(r *http.Request, name string) string { return mux.Vars(r)[name] }},
+	}
+
+	for i, tc := range testCases {
+		conf := Init(tc.router)
+		conf.URLPattern = "/orgs/{org}/repos/{repo}"
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprintf(w, "%s %s %s", tc.param(r, "org"), tc.param(r, "repo"), r.URL.EscapedPath())
+		}
+		conf.WithPathParams(map[string]string{"org": "acme", "repo": "wid get"})
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, http.StatusOK, result.StatusCode, "failure in the test case: %d", i)
+		assert.Equal(t, "acme wid get /orgs/acme/repos/wid%20get", result.Body.String(), "failure in the test case: %d", i)
+	}
+
+	errorCases := []struct {
+		pattern string
+		path    string
+		params  map[string]string
+		wantErr string
+	}{
+		{
+			pattern: "/orgs/{org}/repos/{repo}",
+			params:  map[string]string{"org": "acme"},
+			wantErr: "missing path parameter repo for pattern /orgs/{org}/repos/{repo}",
+		},
+		{
+			pattern: "GET /orgs/{org}",
+			params:  map[string]string{"org": "acme", "team": "a", "repo": "b"},
+			wantErr: "unknown path parameters repo, team for pattern /orgs/{org}",
+		},
+		{
+			pattern: "/orgs/{org}",
+			path:    "/orgs/acme",
+			params:  map[string]string{"org": "acme"},
+			wantErr: "Path and WithPathParams both set the path",
+		},
+	}
+	for i, tc := range errorCases {
+		conf := Init(http.NewServeMux())
+		conf.URLPattern = tc.pattern
+		conf.Path = tc.path
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+		conf.WithPathParams(tc.params)
+
+		_, err := conf.Run(ctx)
+		assert.EqualError(t, err, tc.wantErr, "failure in the test case: %d", i)
+	}
+}
+
+func Test_RunWithRequestModifier(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(chi.NewRouter())
+	conf.URLPattern = "/whoami"
+	conf.Path = "/whoami"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%s %t %s", r.RemoteAddr, r.TLS != nil, r.Header.Get("X-Seen"))
+	}
+	conf.WithHeaders(Header("X-Token", "abc")).
+		WithRequestModifier(func(r *http.Request) {
+			r.RemoteAddr = "203.0.113.9:4321"
+			r.TLS = &tls.ConnectionState{}
+		}).
+		WithRequestModifier(func(r *http.Request) {
+			// Modifiers see the built request and run in order.
+			r.Header.Set("X-Seen", r.Header.Get("X-Token")+" "+r.RemoteAddr)
+		}).
+		WithMiddlewares(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.RemoteAddr != "203.0.113.9:4321" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+		})
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "203.0.113.9:4321 true abc 203.0.113.9:4321", result.Body.String())
+}
+
+type testContextKey string
+
+func Test_RunWithContextValue(t *testing.T) {
+	ctx := context.WithValue(context.Background(), testContextKey("request"), "from ctx")
+
+	conf := Init(chi.NewRouter())
+	conf.URLPattern = "/profile"
+	conf.Path = "/profile"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%v|%v|%v|%v", r.Context().Value(testContextKey("tenant")), r.Context().Value(testContextKey("claims")),
+			r.Context().Value(testContextKey("trace")), r.Context().Value(testContextKey("request")))
+	}
+	conf.WithContextValue(testContextKey("tenant"), "acme").
+		WithContextValue(testContextKey("claims"), []string{"admin"}).
+		WithMiddlewares(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Middlewares see the injected values.
+				trace := fmt.Sprintf("trace-%v", r.Context().Value(testContextKey("tenant")))
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), testContextKey("trace"), trace)))
+			})
+		})
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "acme|[admin]|trace-acme|from ctx", result.Body.String())
+}
+
+func Test_RunWithTLS(t *testing.T) {
+	ctx := context.Background()
+
+	forceHTTPS := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil {
+				http.Redirect(w, r, "https://"+r.Host+r.URL.RequestURI(), http.StatusMovedPermanently)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	newConf := func() *TestConfig {
+		conf := Init(http.NewServeMux())
+		conf.URLPattern = "/account"
+		conf.Path = "/account?tab=1"
+		conf.Host = "example.com"
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			name := ""
+			if len(r.TLS.PeerCertificates) > 0 {
+				name = r.TLS.PeerCertificates[0].Subject.CommonName
+			}
+			_, _ = fmt.Fprintf(w, "%s %s", r.URL.Scheme, name)
+		}
+		return conf.WithMiddlewares(forceHTTPS)
+	}
+
+	result, err := newConf().Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusMovedPermanently, result.StatusCode)
+	assert.Equal(t, "https://example.com/account?tab=1", result.Location.String())
+
+	result, err = newConf().WithTLS().Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "https ", result.Body.String())
+
+	// Following the redirect to https reaches the handler over TLS.
+	conf := newConf()
+	conf.FollowRedirects = 1
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Len(t, result.Redirects, 1)
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client-1"}}
+	result, err = newConf().WithClientCertificate(cert).Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "https client-1", result.Body.String())
+}
+
+func Test_RunWithRemoteAddr(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		configure func(conf *TestConfig)
+		want      string
+	}{
+		{configure: func(conf *TestConfig) { conf.WithRemoteAddr("198.51.100.7:5000") }, want: "198.51.100.7:5000||"},
+		// RealIP replaces RemoteAddr with the forwarded client address.
+		{configure: func(conf *TestConfig) {
+			conf.WithRemoteAddr("10.0.0.1:5000").
+				WithForwardedFor("203.0.113.9", "10.0.0.1").
+				WithRouterMiddlewares(middleware.RealIP)
+		}, want: "203.0.113.9|203.0.113.9, 10.0.0.1|203.0.113.9"},
+	}
+
+	for i, tc := range testCases {
+		conf := Init(chi.NewRouter())
+		conf.URLPattern = "/ip"
+		conf.Path = "/ip"
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprintf(w, "%s|%s|%s", r.RemoteAddr, r.Header.Get("X-Forwarded-For"), r.Header.Get("X-Real-IP"))
+		}
+		tc.configure(conf)
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, tc.want, result.Body.String(), "failure in the test case: %d", i)
+	}
+}
+
+func Test_RunWithAddedHeaders(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/headers"
+	conf.Path = "/headers"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%q %q %q", r.Header.Values("Accept-Encoding"), r.Header.Values("X-Forwarded-For"),
+			r.Header.Values("X-Token"))
+	}
+	conf.WithHeaders(Header("Accept-Encoding", "gzip"), Header("X-Token", "old")).
+		WithAddedHeaders(Header("Accept-Encoding", "br"), Header("X-Forwarded-For", "10.0.0.1")).
+		WithAddedHeaders(Header("x-forwarded-for", "10.0.0.2"), Header("X-Token", "added")).
+		// Setting a header replaces its added values too.
+		WithHeaders(Header("X-Token", "new"))
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, `["gzip" "br"] ["10.0.0.1" "10.0.0.2"] ["new"]`, result.Body.String())
+}
+
+func Test_RunVerbConstructors(t *testing.T) {
+	ctx := context.Background()
+	echoRequest := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.Path, body)
+	}
+
+	testCases := []struct {
+		conf *TestConfig
+		want string
+	}{
+		{conf: Get(chi.NewRouter(), "/items/1"), want: "GET /items/1 "},
+		{conf: Delete(chi.NewRouter(), "/items/1"), want: "DELETE /items/1 "},
+		{conf: Post(chi.NewRouter(), "/items/1", "new"), want: "POST /items/1 new"},
+		{conf: Put(chi.NewRouter(), "/items/1", "replaced"), want: "PUT /items/1 replaced"},
+		{conf: Patch(chi.NewRouter(), "/items/1", "changed"), want: "PATCH /items/1 changed"},
+	}
+
+	for i, tc := range testCases {
+		tc.conf.URLPattern = "/items/{id}"
+		tc.conf.RouteFunc = echoRequest
+		result, err := tc.conf.WithHeaders(Header("X-Test", "1")).Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, tc.want, result.Body.String(), "failure in the test case: %d", i)
+	}
+
+	// Strict mode rejects unknown methods and GET requests with a body.
+	strictCases := []struct {
+		conf    *TestConfig
+		wantErr string
+	}{
+		{conf: Get(chi.NewRouter(), "/items/1")},
+		{conf: Post(chi.NewRouter(), "/items/1", "new")},
+		{conf: Get(chi.NewRouter(), "/items/1").WithJSONBody(1), wantErr: "GET request cannot have a body"},
+		{conf: Head(chi.NewRouter(), "/items/1").WithBodyBytes([]byte("x")), wantErr: "HEAD request cannot have a body"},
+		{conf: newRequestConfig(chi.NewRouter(), "FETCH", "/items/1"), wantErr: "unknown method FETCH"},
+	}
+	for i, tc := range strictCases {
+		tc.conf.URLPattern = "/items/{id}"
+		tc.conf.RouteFunc = echoRequest
+		tc.conf.Strict = true
+		_, err := tc.conf.Run(ctx)
+		if tc.wantErr == "" {
+			assert.NoError(t, err, "failure in the test case: %d", i)
+		} else {
+			assert.EqualError(t, err, tc.wantErr, "failure in the test case: %d", i)
+		}
+	}
+
+	// Without strict mode, any method goes.
+	conf := newRequestConfig(http.NewServeMux(), "PROPFIND", "/items/1")
+	conf.URLPattern = "/items/{id}"
+	conf.RouteFunc = echoRequest
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "PROPFIND /items/1 ", result.Body.String())
+}
+
+func Test_RunFluentSetters(t *testing.T) {
+	ctx := context.Background()
+
+	result, err := Init(chi.NewRouter()).
+		WithPattern("/items/{id}").
+		WithPath("/items/1").
+		WithMethod(http.MethodPut).
+		WithBody(strings.NewReader("payload")).
+		WithRouteFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			_, _ = fmt.Fprintf(w, "%s %s %s", r.Method, chi.URLParam(r, "id"), body)
+		}).
+		WithHeaders(Header("X-Test", "1")).
+		Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "PUT 1 payload", result.Body.String())
+
+	// Setters and fields write the same values, the last write wins.
+	conf := Init(http.NewServeMux()).WithPath("/first").WithMethod(http.MethodPost)
+	conf.Path = "/second"
+	conf.WithMethod(http.MethodDelete).WithBody(strings.NewReader("x")).WithBody(nil)
+	assert.Equal(t, "/second", conf.Path)
+	assert.Equal(t, http.MethodDelete, conf.Method)
+	assert.Nil(t, conf.Body)
+
+	conf.WithRouteFunc(func(w http.ResponseWriter, r *http.Request) {}).WithRouteFunc(nil)
+	_, err = conf.Run(ctx)
+	assert.EqualError(t, err, "handler cannot be nil")
+}
+
+func Test_InitOptions(t *testing.T) {
+	ctx := context.Background()
+
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Chain", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	defaults := []Option{
+		WithDefaultHeaders(Header("X-Api-Key", "default"), Header("Accept", "application/json")),
+		WithDefaultMethod(http.MethodPost),
+		WithDefaultMiddlewares(tag("default")),
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%s %s %s", r.Method, r.Header.Get("X-Api-Key"), r.Header.Get("Accept"))
+	}
+
+	// Defaults apply as they are.
+	conf := Init(chi.NewRouter(), defaults...)
+	conf.URLPattern = "/items"
+	conf.Path = "/items"
+	conf.RouteFunc = handler
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "POST default application/json", result.Body.String())
+	assert.Equal(t, "default", result.Headers["X-Chain"])
+
+	// Settings made on the config override the defaults, without changing
+	// other configs built from the same options.
+	conf = Init(chi.NewRouter(), defaults...).
+		WithHeaders(Header("x-api-key", "override")).
+		WithMiddlewares(tag("own"))
+	conf.Method = http.MethodPut
+	conf.URLPattern = "/items"
+	conf.Path = "/items"
+	conf.RouteFunc = handler
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "PUT override application/json", result.Body.String())
+	assert.Equal(t, "default, own", result.Headers["X-Chain"])
+
+	other := Init(chi.NewRouter(), defaults...)
+	assert.Equal(t, map[string]string{"X-Api-Key": "default", "Accept": "application/json"}, other.Headers)
+	assert.Len(t, other.Middlewares, 1)
+}
+
+func Test_SetDefaultMiddlewares(t *testing.T) {
+	ctx := context.Background()
+
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Chain", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	SetDefaultMiddlewares(tag("global"), tag("global-2"))
+	t.Cleanup(func() { SetDefaultMiddlewares() })
+
+	newConf := func(opts ...Option) *TestConfig {
+		conf := Init(chi.NewRouter(), opts...)
+		conf.URLPattern = "/items"
+		conf.Path = "/items"
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+		return conf
+	}
+
+	// The package defaults come first, then the option defaults, then the
+	// middlewares of the config.
+	conf := newConf(WithDefaultMiddlewares(tag("option"))).
+		WithMiddlewares(tag("own")).
+		WithNamedMiddleware("named", tag("named"))
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, []string{"global", "global-2", "option", "own", "named"}, result.RawHeaders["X-Chain"])
+	var names []string
+	for _, l := range result.Timing.Layers {
+		names = append(names, l.Layer.String())
+	}
+	assert.Equal(t, []string{"middleware[0]", "middleware[1]", "middleware[2]", "middleware[3]", "named", "handler"}, names)
+
+	// A config opts out of every default, keeping its own middlewares.
+	result, err = conf.Clone().WithoutDefaultMiddlewares().Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, []string{"own", "named"}, result.RawHeaders["X-Chain"])
+	names = nil
+	for _, l := range result.Timing.Layers {
+		names = append(names, l.Layer.String())
+	}
+	assert.Equal(t, []string{"middleware[0]", "named", "handler"}, names)
+	assert.Len(t, conf.Middlewares, 5)
+
+	// Middlewares assigned to the field are the config's own.
+	assigned := conf.Clone()
+	assigned.Middlewares = []func(http.Handler) http.Handler{tag("first"), tag("second"), tag("third")}
+	result, err = assigned.WithoutDefaultMiddlewares().Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, []string{"first", "second", "third"}, result.RawHeaders["X-Chain"])
+
+	// Changing the defaults leaves the configs already created alone.
+	SetDefaultMiddlewares(tag("changed"))
+	result, err = newConf().Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, []string{"changed"}, result.RawHeaders["X-Chain"])
+	assert.Len(t, conf.Middlewares, 5)
+}
+
+func Test_Clone(t *testing.T) {
+	ctx := context.Background()
+
+	base := Init(chi.NewRouter()).
+		WithHeaders(Header("X-Base", "1")).
+		WithQuery("page", "1").
+		WithFormField("name", "base")
+	base.URLPattern = "POST /items/{id}"
+	base.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%s %s %s %s %s", chi.URLParam(r, "id"), r.Header.Get("X-Base"), r.Header.Get("X-Case"),
+			r.URL.Query()["page"], r.PostFormValue("name"))
+	}
+
+	clone := base.Clone().
+		WithHeaders(Header("X-Case", "clone"), Header("X-Base", "changed")).
+		WithQuery("page", "2").
+		WithFormField("name", "clone").
+		WithMiddlewares(func(next http.Handler) http.Handler { return next })
+	assert.Equal(t, map[string]string{"X-Base": "1"}, base.Headers)
+	assert.Equal(t, url.Values{"page": {"1"}}, base.query)
+	assert.Equal(t, url.Values{"name": {"base"}}, base.form)
+	assert.Empty(t, base.Middlewares)
+
+	// Clones of the same config run concurrently.
+	results := make([]string, 10)
+	errs := make([]error, 10)
+	var wg sync.WaitGroup
+	for i := range results {
+		c := base.Clone()
+		c.Path = fmt.Sprintf("/items/%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := c.Run(ctx)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			results[i] = result.Body.String()
+		}()
+	}
+	wg.Wait()
+	for i := range results {
+		assert.NoError(t, errs[i])
+		assert.Equal(t, fmt.Sprintf("%d 1  [1] base", i), results[i], "failure in the test case: %d", i)
+	}
+
+	clone.Path = "/items/c"
+	result, err := clone.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "c changed clone [1 2] base", result.Body.String())
+}
+
+func Test_RunResultCookies(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/session"
+	conf.Path = "/session"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", HttpOnly: true, SameSite: http.SameSiteLaxMode})
+		http.SetCookie(w, &http.Cookie{Name: "csrf", Value: "c1", Secure: true, SameSite: http.SameSiteNoneMode})
+	}
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, []string{"session=s1; HttpOnly; SameSite=Lax", "csrf=c1; Secure; SameSite=None"},
+		result.RawHeaders.Values("Set-Cookie"))
+
+	cookies := result.Cookies()
+	if assert.Len(t, cookies, 2) {
+		assert.Equal(t, "session", cookies[0].Name)
+		assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.Equal(t, "csrf", cookies[1].Name)
+		assert.Equal(t, http.SameSiteNoneMode, cookies[1].SameSite)
+		assert.True(t, cookies[1].Secure)
+	}
+}
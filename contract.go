@@ -0,0 +1,25 @@
+package checkpoint
+
+import (
+	"net/http"
+)
+
+// Contract checks requests and responses against an API description, such as
+// the OpenAPI document loaded by the openapi sub-package. pattern is the
+// route pattern the request is aimed at, as registered on the router.
+type Contract interface {
+	// CheckRequest is called before the request is served. A request body
+	// it reads must be left readable for the handler.
+	CheckRequest(req *http.Request, pattern string) error
+	// CheckResponse is called with the request that produced result, which
+	// is the last one when redirects were followed.
+	CheckResponse(req *http.Request, pattern string, result *Result) error
+}
+
+// WithContract makes Run check the request and the response against c. The
+// request is served even when it breaks the contract, and violations are
+// returned by Run along with the Result.
+func (tc *TestConfig) WithContract(c Contract) *TestConfig {
+	tc.contract = c
+	return tc
+}
@@ -0,0 +1,63 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeContract struct {
+	requests  []string
+	responses []string
+	reqErr    error
+}
+
+func (f *fakeContract) CheckRequest(req *http.Request, pattern string) error {
+	body, _ := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	f.requests = append(f.requests, req.Method+" "+pattern+" "+string(body))
+	return f.reqErr
+}
+
+func (f *fakeContract) CheckResponse(req *http.Request, pattern string, result *Result) error {
+	f.responses = append(f.responses, req.URL.Path+" "+pattern)
+	if result.StatusCode != http.StatusOK {
+		return errors.New("undocumented status")
+	}
+	return nil
+}
+
+func Test_RunWithContract(t *testing.T) {
+	ctx := context.Background()
+
+	contract := &fakeContract{}
+	conf := Post(http.NewServeMux(), "/items/1", "payload")
+	conf.URLPattern = "POST /items/{id}"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}
+	conf.WithContract(contract)
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "payload", result.Body.String())
+	assert.Equal(t, []string{"POST /items/{id} payload"}, contract.requests)
+	assert.Equal(t, []string{"/items/1 /items/{id}"}, contract.responses)
+
+	contract.reqErr = errors.New("bad parameter")
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}
+	conf.SetBodyString("payload")
+	result, err = conf.Run(ctx)
+	assert.NotNil(t, result)
+	assert.EqualError(t, err, "bad parameter\nundocumented status")
+}
@@ -0,0 +1,68 @@
+package checkpoint
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ControllerCall is a call a handler made through http.ResponseController
+// that the recorder cannot act upon, recorded in Result.ControllerCalls.
+type ControllerCall struct {
+	Method   string        // "SetReadDeadline", "SetWriteDeadline" or "EnableFullDuplex"
+	Deadline time.Time     // the deadline set, zero when it was cleared or for EnableFullDuplex
+	At       time.Duration // the time of the call since the handler started
+}
+
+// controllerRecorder implements the methods http.ResponseController looks for
+// besides Flush and Hijack, so they succeed as on a server instead of
+// returning http.ErrNotSupported, and records their calls. The deadlines are
+// not enforced.
+type controllerRecorder struct {
+	http.ResponseWriter
+	start time.Time
+
+	mu    sync.Mutex // the handler may outlive Run when it times out
+	calls []ControllerCall
+}
+
+func newControllerRecorder(w http.ResponseWriter) *controllerRecorder {
+	return &controllerRecorder{ResponseWriter: w, start: time.Now()}
+}
+
+func (c *controllerRecorder) add(method string, deadline time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, ControllerCall{Method: method, Deadline: deadline, At: time.Since(c.start)})
+	return nil
+}
+
+func (c *controllerRecorder) SetReadDeadline(deadline time.Time) error {
+	return c.add("SetReadDeadline", deadline)
+}
+
+func (c *controllerRecorder) SetWriteDeadline(deadline time.Time) error {
+	return c.add("SetWriteDeadline", deadline)
+}
+
+func (c *controllerRecorder) EnableFullDuplex() error {
+	return c.add("EnableFullDuplex", time.Time{})
+}
+
+func (c *controllerRecorder) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the recorder.
+func (c *controllerRecorder) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// record sets the ControllerCalls of r.
+func (c *controllerRecorder) record(r *Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r.ControllerCalls = append([]ControllerCall(nil), c.calls...)
+}
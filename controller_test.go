@@ -0,0 +1,71 @@
+package checkpoint
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exportHandler bounds the time spent writing each part of an export with a
+// write deadline, flushing the parts as they are ready, as a production
+// handler behind a server WriteTimeout would.
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	if err := rc.EnableFullDuplex(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, part := range []string{"part 1\n", "part 2\n"} {
+		if err := rc.SetWriteDeadline(time.Now().Add(time.Minute)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(part))
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+	_ = rc.SetWriteDeadline(time.Time{})
+}
+
+func Test_ResultControllerCalls(t *testing.T) {
+	ctx := context.Background()
+
+	mutations := []func(conf *TestConfig){
+		func(conf *TestConfig) {},
+		// Through the middleware wrappers and the timeout guard.
+		func(conf *TestConfig) {
+			conf.WithMiddlewares(passThrough)
+			conf.Timeout = time.Second
+		},
+	}
+	for i, configure := range mutations {
+		conf := Get(http.NewServeMux(), "/export")
+		conf.RouteFunc = exportHandler
+		configure(conf)
+
+		start := time.Now()
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, http.StatusOK, result.StatusCode, "failure in the test case: %d", i)
+		assert.Equal(t, [][]byte{[]byte("part 1\n"), []byte("part 2\n")}, result.Chunks, "failure in the test case: %d", i)
+
+		calls := result.ControllerCalls
+		if !assert.Len(t, calls, 4, "failure in the test case: %d", i) {
+			continue
+		}
+		assert.Equal(t, "EnableFullDuplex", calls[0].Method, "failure in the test case: %d", i)
+		assert.True(t, calls[0].Deadline.IsZero(), "failure in the test case: %d", i)
+		for _, call := range calls[1:3] {
+			assert.Equal(t, "SetWriteDeadline", call.Method, "failure in the test case: %d", i)
+			assert.WithinDuration(t, start.Add(time.Minute), call.Deadline, time.Second, "failure in the test case: %d", i)
+		}
+		assert.Equal(t, ControllerCall{Method: "SetWriteDeadline", At: calls[3].At}, calls[3], "failure in the test case: %d", i)
+		assert.LessOrEqual(t, calls[1].At, calls[2].At, "failure in the test case: %d", i)
+	}
+}
@@ -0,0 +1,174 @@
+package checkpoint
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/mux"
+)
+
+// Coverage lists the routes of a router exercised by Run. Routes are written
+// as "METHOD /pattern", or just "/pattern" for routes serving any method.
+type Coverage struct {
+	Hit    []string // routes matched by at least one Run
+	Missed []string // routes registered on the router that no Run matched
+}
+
+// coverage holds the routes matched by Run, per router, as "METHOD /pattern".
+var coverage = struct {
+	sync.Mutex
+	hits map[any]map[string]bool
+}{hits: make(map[any]map[string]bool)}
+
+// CoverageReport returns the routes of router exercised by Run so far. The
+// router is the value passed to Init. Missed routes are listed for chi and
+// gorilla, which can walk their routes; for http.ServeMux only the hit routes
+// are known, and other routers are not tracked.
+func CoverageReport(router any) Coverage {
+	key := coverageKey(router)
+	coverage.Lock()
+	hits := make(map[string]bool)
+	if key != nil {
+		for route := range coverage.hits[key] {
+			hits[route] = true
+		}
+	}
+	coverage.Unlock()
+
+	routes, ok := walkRoutes(key)
+	if !ok {
+		report := Coverage{}
+		for route := range hits {
+			report.Hit = append(report.Hit, route)
+		}
+		slices.Sort(report.Hit)
+		return report
+	}
+
+	// A route serving any method is hit by a request with any method.
+	hitPatterns := make(map[string]bool)
+	for route := range hits {
+		_, pattern := splitPattern(route)
+		hitPatterns[pattern] = true
+	}
+	report := Coverage{}
+	for _, route := range routes {
+		if hits[route] || (!strings.Contains(route, " ") && hitPatterns[route]) {
+			report.Hit = append(report.Hit, route)
+		} else {
+			report.Missed = append(report.Missed, route)
+		}
+	}
+	return report
+}
+
+// recordCoverage records the route of r that matches req, if r can report it,
+// and returns the pattern of that route.
+func recordCoverage(r Router, req *http.Request) string {
+	key := coverageKey(r)
+	if key == nil {
+		return ""
+	}
+	route := matchRoute(key, req)
+	if route == "" {
+		return ""
+	}
+
+	coverage.Lock()
+	defer coverage.Unlock()
+	if coverage.hits[key] == nil {
+		coverage.hits[key] = make(map[string]bool)
+	}
+	coverage.hits[key][route] = true
+	_, pattern := splitPattern(route)
+	return pattern
+}
+
+// coverageKey returns the value coverage is tracked under: the router itself,
+// or the router wrapped by a RouterAdapter.
+func coverageKey(router any) any {
+	if a, ok := router.(*RouterAdapter); ok {
+		router = a.Mux
+	}
+	if router == nil || !reflect.TypeOf(router).Comparable() {
+		return nil
+	}
+	return router
+}
+
+// matchRoute returns the route of router matching req as "METHOD /pattern",
+// or "" when nothing matches or the router cannot report it.
+func matchRoute(router any, req *http.Request) string {
+	switch m := router.(type) {
+	case *http.ServeMux:
+		_, pattern := m.Handler(req)
+		return pattern
+	case chi.Routes:
+		if pattern := m.Find(chi.NewRouteContext(), req.Method, req.URL.Path); pattern != "" {
+			return req.Method + " " + pattern
+		}
+	case *mux.Router:
+		var match mux.RouteMatch
+		if m.Match(req, &match) && match.Route != nil {
+			if pattern, err := match.Route.GetPathTemplate(); err == nil {
+				return req.Method + " " + pattern
+			}
+		}
+	}
+	return ""
+}
+
+// walkRoutes lists the routes registered on router, sorted. It reports false
+// when the router cannot be walked.
+func walkRoutes(router any) ([]string, bool) {
+	var routes []string
+	switch m := router.(type) {
+	case chi.Routes:
+		routes = walkChi(m, "")
+	case *mux.Router:
+		_ = m.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			pattern, err := route.GetPathTemplate()
+			if err != nil || route.GetHandler() == nil {
+				return nil
+			}
+			methods, err := route.GetMethods()
+			if err != nil {
+				routes = append(routes, pattern)
+				return nil
+			}
+			for _, method := range methods {
+				routes = append(routes, method+" "+pattern)
+			}
+			return nil
+		})
+	default:
+		return nil, false
+	}
+	slices.Sort(routes)
+	return slices.Compact(routes), true
+}
+
+// walkChi lists the routes of a chi router including mounted sub-routers.
+// Routes registered for any method are listed once, without a method.
+func walkChi(r chi.Routes, prefix string) []string {
+	var routes []string
+	for _, route := range r.Routes() {
+		pattern := prefix + route.Pattern
+		if route.SubRoutes != nil {
+			routes = append(routes, walkChi(route.SubRoutes, strings.TrimSuffix(pattern, "/*"))...)
+			continue
+		}
+		if _, ok := route.Handlers["*"]; ok {
+			routes = append(routes, pattern)
+			continue
+		}
+		for method := range route.Handlers {
+			routes = append(routes, method+" "+pattern)
+		}
+	}
+	return routes
+}
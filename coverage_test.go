@@ -0,0 +1,68 @@
+package checkpoint
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CoverageReport(t *testing.T) {
+	ctx := context.Background()
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	admin := chi.NewRouter()
+	admin.Get("/users/{id}", ok)
+	admin.Delete("/users/{id}", ok)
+	r := chi.NewRouter()
+	r.Get("/health", ok)
+	r.Post("/items", ok)
+	r.Handle("/static/*", http.HandlerFunc(ok))
+	r.Mount("/admin", admin)
+
+	for _, path := range []string{"/health", "/static/app.js", "/admin/users/1", "/missing"} {
+		conf := Init(r)
+		conf.UseExistingRoutes = true
+		conf.Path = path
+		if _, err := conf.Run(ctx); err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+	}
+
+	report := CoverageReport(r)
+	assert.Equal(t, []string{"/static/*", "GET /admin/users/{id}", "GET /health"}, report.Hit)
+	assert.Equal(t, []string{"DELETE /admin/users/{id}", "POST /items"}, report.Missed)
+
+	m := mux.NewRouter()
+	m.HandleFunc("/users/{id}", ok).Methods(http.MethodGet)
+	m.HandleFunc("/users", ok).Methods(http.MethodPost)
+	m.HandleFunc("/ping", ok)
+
+	conf := Init(m)
+	conf.UseExistingRoutes = true
+	conf.Path = "/users/5"
+	if _, err := conf.Run(ctx); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	report = CoverageReport(m)
+	assert.Equal(t, []string{"GET /users/{id}"}, report.Hit)
+	assert.Equal(t, []string{"/ping", "POST /users"}, report.Missed)
+
+	// ServeMux cannot be walked, so only hit routes are reported.
+	sm := http.NewServeMux()
+	conf = Init(sm)
+	conf.RouteFunc = ok
+	conf.URLPattern = "GET /orders/{id}"
+	conf.Path = "/orders/1"
+	if _, err := conf.Run(ctx); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	report = CoverageReport(sm)
+	assert.Equal(t, []string{"GET /orders/{id}"}, report.Hit)
+	assert.Empty(t, report.Missed)
+}
@@ -0,0 +1,480 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// curlBaseURL is the server the curl commands of in-process runs are sent to.
+const curlBaseURL = "http://localhost:8080"
+
+// CurlCommand renders the request Run sent, or the last one when redirects
+// were followed, as a curl command replaying it. Requests served in process
+// are sent to http://localhost:8080, with their Host header. Multipart and
+// streamed bodies are left out, with a comment saying so.
+func (r *Result) CurlCommand() string {
+	if r.request == nil {
+		return ""
+	}
+	return curlCommand(r.request)
+}
+
+// CurlCommand renders the request tc sends as a curl command, as
+// Result.CurlCommand does, without running it. Multipart and streamed bodies
+// are not read, so they can still be sent by Run.
+func (tc *TestConfig) CurlCommand() (string, error) {
+	if err := tc.Validate(); err != nil {
+		return "", err
+	}
+	path, err := tc.requestPath()
+	if err != nil {
+		return "", err
+	}
+	routeMethod, _ := tc.routePattern(path)
+	method := tc.requestMethod(routeMethod)
+
+	var body io.Reader
+	var contentType string
+	if tc.body != nil && (tc.body.source == "WithMultipart" || tc.body.source == "WithBodyReader") {
+		// A body that cannot be read again, so it is left out as for a run.
+		body, contentType = io.MultiReader(), tc.body.contentType
+	} else {
+		if tc.body == nil && tc.Body != nil {
+			raw, err := io.ReadAll(tc.Body)
+			if err != nil {
+				return "", fmt.Errorf("reading the request body: %w", err)
+			}
+			tc.Body = io.NopCloser(bytes.NewReader(raw))
+			defer func() { tc.Body = io.NopCloser(bytes.NewReader(raw)) }()
+		}
+		if body, contentType, err = tc.openBody(); err != nil {
+			return "", err
+		}
+		if c, ok := body.(io.Closer); ok && tc.body != nil {
+			defer c.Close()
+		}
+		if body != nil {
+			// Buffer the body so GetBody can read it again.
+			b, err := io.ReadAll(body)
+			if err != nil {
+				return "", fmt.Errorf("reading the request body: %w", err)
+			}
+			body = bytes.NewReader(b)
+		}
+	}
+	req, err := tc.newRequest(context.Background(), method, path, body, contentType)
+	if err != nil {
+		return "", err
+	}
+	if tc.BaseURL != "" {
+		if err := rebaseRequest(req, tc.BaseURL); err != nil {
+			return "", err
+		}
+	}
+	return curlCommand(req), nil
+}
+
+// curlCommand renders req as a curl command. Bodies holding single quotes or
+// newlines are passed in a heredoc, binary ones base64 encoded.
+func curlCommand(req *http.Request) string {
+	u := *req.URL
+	host := req.Host
+	if u.Scheme == "" {
+		if host == "" {
+			host = u.Host
+		}
+		u.Scheme, u.Host = "http", strings.TrimPrefix(curlBaseURL, "http://")
+	}
+
+	var note string
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		switch {
+		case isMultipart(req.Header.Get("Content-Type")):
+			note = "# multipart bodies are not supported, the body is left out\n"
+		case req.GetBody == nil:
+			note = "# the streamed body was not captured, it is left out\n"
+		default:
+			if r, err := req.GetBody(); err != nil {
+				note = fmt.Sprintf("# cannot read the body: %v\n", err)
+			} else {
+				body, err = io.ReadAll(r)
+				r.Close()
+				if err != nil {
+					note = fmt.Sprintf("# cannot read the body: %v\n", err)
+				}
+			}
+		}
+	}
+
+	args := []string{"curl"}
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodHead:
+		args = append(args, "--head")
+	default:
+		args = append(args, "-X", req.Method)
+	}
+	args = append(args, shellQuote(u.String()))
+	if host != "" && host != u.Host {
+		args = append(args, "-H", shellQuote("Host: "+host))
+	}
+	for _, key := range slices.Sorted(maps.Keys(req.Header)) {
+		if key == "Content-Length" {
+			continue
+		}
+		for _, value := range req.Header[key] {
+			args = append(args, "-H", shellQuote(key+": "+value))
+		}
+	}
+
+	var prefix, heredoc string
+	switch {
+	case len(body) == 0:
+	case !utf8.Valid(body) || bytes.IndexByte(body, 0) >= 0:
+		prefix = "echo " + base64.StdEncoding.EncodeToString(body) + " | base64 -d | "
+		args = append(args, "--data-binary", "@-")
+	case bytes.ContainsAny(body, "'\n\r"):
+		delimiter := heredocDelimiter(body)
+		args = append(args, "--data-binary", "@-")
+		if !bytes.HasSuffix(body, []byte("\n")) {
+			// A heredoc ends with a newline the body does not have.
+			prefix = fmt.Sprintf("head -c %d <<'%s' | ", len(body), delimiter)
+			heredoc = string(body) + "\n" + delimiter + "\n"
+		} else {
+			args = append(args, "<<'"+delimiter+"'")
+			heredoc = string(body) + delimiter + "\n"
+		}
+	default:
+		args = append(args, "--data-binary", shellQuote(string(body)))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(note)
+	sb.WriteString(prefix)
+	for i, arg := range args {
+		switch {
+		case i == 0:
+		case arg == "-H" || arg == "--data-binary":
+			sb.WriteString(" \\\n  ")
+		default:
+			sb.WriteString(" ")
+		}
+		sb.WriteString(arg)
+	}
+	sb.WriteString("\n")
+	sb.WriteString(heredoc)
+	return sb.String()
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// heredocDelimiter returns a heredoc delimiter no line of body equals.
+func heredocDelimiter(body []byte) string {
+	delimiter := "CHECKPOINT_BODY"
+	lines := strings.Split(string(body), "\n")
+	for i := 1; slices.Contains(lines, delimiter); i++ {
+		delimiter = fmt.Sprintf("CHECKPOINT_BODY_%d", i)
+	}
+	return delimiter
+}
+
+// isMultipart reports whether contentType is a multipart media type.
+func isMultipart(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return strings.HasPrefix(mediaType, "multipart/")
+}
+
+// curlFlags maps the curl flags FromCurl takes a value for to their long
+// name.
+var curlFlags = map[string]string{
+	"-X": "--request", "--request": "--request",
+	"-H": "--header", "--header": "--header",
+	"-d": "--data", "--data": "--data", "--data-ascii": "--data",
+	"--data-raw": "--data-raw", "--data-binary": "--data-binary",
+	"-u": "--user", "--user": "--user",
+	"-b": "--cookie", "--cookie": "--cookie",
+	"--url": "--url",
+}
+
+// curlIgnoredFlags lists the curl flags FromCurl accepts without effect, as
+// they only change what curl prints or how it connects.
+var curlIgnoredFlags = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true,
+	"-v": true, "--verbose": true, "-i": true, "--include": true,
+	"-k": true, "--insecure": true, "--compressed": true,
+}
+
+// FromCurl builds a TestConfig for router sending the request of a curl
+// command, as found in bug reports, so it can be turned into a regression
+// test once a handler or UseExistingRoutes is set. The method, URL, headers,
+// body, basic authentication and cookies are taken from -X, -H, -d, --data,
+// --data-raw, --data-binary, -u, --url and -b. -L follows redirects, as
+// FollowRedirects does, and flags changing the output only are ignored. Other
+// flags make it fail, listing them.
+//
+// The host of the URL becomes Host, and an https URL sends the request as
+// WithTLS does. As with curl, a body makes the request a POST of a form unless
+// -X or a Content-Type header say otherwise.
+func FromCurl(router any, command string) (*TestConfig, error) {
+	args, err := splitShellWords(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return nil, errors.New("not a curl command")
+	}
+
+	tc := Init(router)
+	var urls []string
+	var data [][]byte
+	var unsupported []string
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		flag, value := arg, ""
+		// Short flags may carry their value, as in -XPOST.
+		if len(arg) > 2 && arg[0] == '-' && arg[1] != '-' && curlFlags[arg[:2]] != "" {
+			flag, value = arg[:2], arg[2:]
+		}
+		name, ok := curlFlags[flag]
+		if !ok {
+			switch {
+			case arg == "-L" || arg == "--location":
+				tc.FollowRedirects = 50 // as curl's --max-redirs
+			case curlIgnoredFlags[arg]:
+			case len(arg) > 2 && arg[0] == '-' && arg[1] != '-' && strings.Trim(arg[1:], "sSvikL") == "":
+				// Grouped flags, as in -sSL.
+				if strings.Contains(arg, "L") {
+					tc.FollowRedirects = 50
+				}
+			case strings.HasPrefix(arg, "-") && arg != "-":
+				unsupported = append(unsupported, arg)
+			default:
+				urls = append(urls, arg)
+			}
+			continue
+		}
+		if value == "" {
+			if i++; i == len(args) {
+				return nil, fmt.Errorf("curl flag %s without a value", flag)
+			}
+			value = args[i]
+		}
+
+		switch name {
+		case "--request":
+			tc.Method = value
+		case "--header":
+			key, v, found := strings.Cut(value, ":")
+			if !found {
+				return nil, fmt.Errorf("curl header %q is not a key: value pair", value)
+			}
+			key, v = strings.TrimSpace(key), strings.TrimSpace(v)
+			if strings.EqualFold(key, "Host") {
+				tc.Host = v
+			} else {
+				tc.WithAddedHeaders(Header(key, v))
+			}
+		case "--data", "--data-binary":
+			if strings.HasPrefix(value, "@") {
+				return nil, fmt.Errorf("curl data read from %s is not supported, pass it with --data-raw", value[1:])
+			}
+			data = append(data, []byte(value))
+		case "--data-raw":
+			data = append(data, []byte(value))
+		case "--user":
+			user, password, _ := strings.Cut(value, ":")
+			tc.WithBasicAuth(user, password)
+		case "--cookie":
+			if !strings.Contains(value, "=") {
+				return nil, fmt.Errorf("curl cookies read from %s are not supported", value)
+			}
+			cookies, err := http.ParseCookie(value)
+			if err != nil {
+				return nil, fmt.Errorf("curl cookies %q: %w", value, err)
+			}
+			tc.WithCookies(cookies...)
+		case "--url":
+			urls = append(urls, value)
+		}
+	}
+	if len(unsupported) > 0 {
+		return nil, fmt.Errorf("unsupported curl flags: %s", strings.Join(unsupported, ", "))
+	}
+	switch len(urls) {
+	case 0:
+		return nil, errors.New("curl command without a URL")
+	case 1:
+	default:
+		return nil, fmt.Errorf("curl command with several URLs: %s", strings.Join(urls, ", "))
+	}
+	rawURL := urls[0]
+
+	// As curl, take a URL without a scheme as an http one.
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("curl URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("curl URL %s is not an http or https URL", rawURL)
+	}
+	tc.Path = u.EscapedPath()
+	if tc.Path == "" {
+		tc.Path = "/"
+	}
+	if u.RawQuery != "" {
+		tc.Path += "?" + u.RawQuery
+	}
+	if tc.Host == "" {
+		tc.Host = u.Host
+	}
+	if u.Scheme == "https" {
+		tc.WithTLS()
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		tc.WithBasicAuth(u.User.Username(), password)
+	}
+
+	if data != nil {
+		body := bytes.Join(data, []byte("&"))
+		tc.setBody(&requestBody{
+			source:      "FromCurl",
+			contentType: "application/x-www-form-urlencoded",
+			open: func(*TestConfig) (io.Reader, error) {
+				return bytes.NewReader(body), nil
+			},
+		})
+		if tc.Method == "" {
+			tc.Method = http.MethodPost
+		}
+	}
+	return tc, nil
+}
+
+// splitShellWords splits command into words as a POSIX shell does, handling
+// single and double quotes, $'...' strings, backslash escapes and line
+// continuations. Expansions are left as they are.
+func splitShellWords(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			i++
+			if i == len(command) {
+				return nil, errors.New("command ends with a backslash")
+			}
+			if command[i] == '\n' {
+				continue
+			}
+			if command[i] == '\r' && i+1 < len(command) && command[i+1] == '\n' {
+				i++
+				continue
+			}
+			word.WriteByte(command[i])
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(command) && command[i+1] == '\'':
+			n, err := readANSIQuoted(command[i+2:], &word)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 1
+			inWord = true
+		case c == '"':
+			closed := false
+			for i++; i < len(command); i++ {
+				if command[i] == '"' {
+					closed = true
+					break
+				}
+				if command[i] == '\\' && i+1 < len(command) && strings.IndexByte("$`\"\\\n", command[i+1]) >= 0 {
+					i++
+					if command[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(command[i])
+			}
+			if !closed {
+				return nil, errors.New("unterminated double quote")
+			}
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// readANSIQuoted writes the content of the $'...' string s starts with, after
+// the opening quote, to word and returns the length read, closing quote
+// included.
+func readANSIQuoted(s string, word *strings.Builder) (int, error) {
+	escapes := map[byte]byte{
+		'n': '\n', 't': '\t', 'r': '\r', '0': 0, 'a': '\a', 'b': '\b',
+		'e': 0x1b, 'f': '\f', 'v': '\v', '\\': '\\', '\'': '\'', '"': '"',
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'':
+			return i + 1, nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			if e, ok := escapes[s[i]]; ok {
+				word.WriteByte(e)
+			} else if s[i] == 'x' && i+2 < len(s) {
+				b, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+				if err != nil {
+					return 0, fmt.Errorf("invalid escape \\x%s", s[i+1:i+3])
+				}
+				word.WriteByte(byte(b))
+				i += 2
+			} else {
+				word.WriteByte('\\')
+				word.WriteByte(s[i])
+			}
+		default:
+			word.WriteByte(c)
+		}
+	}
+	return 0, errors.New("unterminated $' quote")
+}
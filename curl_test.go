@@ -0,0 +1,272 @@
+package checkpoint
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// replayCurl runs command with sh, curl being a function printing its
+// arguments, one per line, followed by what it reads from its input.
+func replayCurl(t *testing.T, command string) string {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell to run the command with")
+	}
+	out, err := exec.Command(sh, "-c", `curl() { for arg; do printf '[%s]\n' "$arg"; done; cat; }
+`+command).CombinedOutput()
+	if err != nil {
+		t.Fatalf("running the command: %v: %s", err, out)
+	}
+	return string(out)
+}
+
+func Test_CurlCommand(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		body     string
+		command  string
+		received string
+	}{
+		{
+			body:     `{"name":"widget"}`,
+			command:  `--data-binary '{"name":"widget"}'`,
+			received: "[--data-binary]\n[{\"name\":\"widget\"}]\n",
+		},
+		{
+			body:     `costs $5 and $(rm -rf /)`,
+			command:  `--data-binary 'costs $5 and $(rm -rf /)'`,
+			received: "[--data-binary]\n[costs $5 and $(rm -rf /)]\n",
+		},
+		{
+			body:     `it's "quoted"`,
+			command:  "head -c 13 <<'CHECKPOINT_BODY' | curl -X POST",
+			received: "[--data-binary]\n[@-]\nit's \"quoted\"",
+		},
+		{
+			body:     "line 1\nline $2\n",
+			command:  "--data-binary @- <<'CHECKPOINT_BODY'\nline 1\nline $2\nCHECKPOINT_BODY\n",
+			received: "[--data-binary]\n[@-]\nline 1\nline $2\n",
+		},
+		{
+			body:     "CHECKPOINT_BODY\n'\n",
+			command:  "<<'CHECKPOINT_BODY_1'",
+			received: "[@-]\nCHECKPOINT_BODY\n'\n",
+		},
+		{
+			body:     "\x00\xff binary",
+			command:  "echo AP8gYmluYXJ5 | base64 -d | curl",
+			received: "[@-]\n\x00\xff binary",
+		},
+	}
+
+	for i, tt := range tests {
+		conf := Init(http.NewServeMux()).
+			WithPath("/items").
+			WithQuery("q", "a b").
+			WithHeaders(Header("X-Note", "it's $HOME")).
+			WithBodyBytes([]byte(tt.body))
+		conf.Method = http.MethodPost
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		command := result.CurlCommand()
+		assert.Contains(t, command, "curl -X POST 'http://localhost:8080/items?q=a+b'", "failure in the test case: %d", i)
+		assert.Contains(t, command, tt.command, "failure in the test case: %d", i)
+
+		out := replayCurl(t, command)
+		assert.Contains(t, out, "[X-Note: it's $HOME]\n", "failure in the test case: %d", i)
+		assert.True(t, strings.HasSuffix(out, tt.received), "failure in the test case: %d: %q", i, out)
+
+		// The config renders the same command without running.
+		unrun, err := conf.CurlCommand()
+		assert.NoError(t, err)
+		assert.Equal(t, command, unrun, "failure in the test case: %d", i)
+	}
+}
+
+func Test_CurlCommandRequests(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Get(http.NewServeMux(), "/users/7").
+		WithCookies(&http.Cookie{Name: "session", Value: "abc"})
+	conf.Host = "api.example.com"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "curl 'http://localhost:8080/users/7' \\\n"+
+		"  -H 'Host: api.example.com' \\\n"+
+		"  -H 'Cookie: session=abc'\n", result.CurlCommand())
+
+	// Remote runs are replayed against the service.
+	remote := InitRemote("https://api.example.com/v1").WithPath("/users/7")
+	remote.Method = http.MethodHead
+	command, err := remote.CurlCommand()
+	assert.NoError(t, err)
+	assert.Equal(t, "curl --head 'https://api.example.com/v1/users/7'\n", command)
+
+	// Multipart bodies are left out, their files are still sent by Run.
+	upload := Init(http.NewServeMux()).WithPath("/upload")
+	upload.URLPattern = "POST /upload"
+	upload.WithMultipart().AddFile("file", "a.txt", strings.NewReader("content"))
+	var received string
+	upload.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("file")
+		if err == nil {
+			b := make([]byte, 7)
+			_, _ = f.Read(b)
+			received = string(b)
+		}
+	}
+	command, err = upload.CurlCommand()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(command, "# multipart bodies are not supported, the body is left out\ncurl -X POST"), command)
+	assert.Contains(t, command, "-H 'Content-Type: multipart/form-data; boundary=")
+	assert.NotContains(t, command, "--data-binary")
+
+	result, err = upload.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "content", received)
+	assert.True(t, strings.HasPrefix(result.CurlCommand(), "# multipart bodies are not supported"))
+
+	_, err = Init(nil).CurlCommand()
+	assert.Error(t, err)
+	assert.Empty(t, (&Result{}).CurlCommand())
+}
+
+func Test_FromCurl(t *testing.T) {
+	ctx := context.Background()
+
+	type request struct {
+		method string
+		uri    string
+		host   string
+		tls    bool
+		header http.Header
+		body   string
+	}
+	tests := []struct {
+		command string
+		want    request
+	}{
+		{
+			command: `curl -X POST 'https://api/x?a=1' -H 'Content-Type: application/json' --data '{"a":1}'`,
+			want: request{method: "POST", uri: "/x?a=1", host: "api", tls: true, body: `{"a":1}`,
+				header: http.Header{"Content-Type": {"application/json"}}},
+		},
+		{
+			command: `curl api.example.com/items`,
+			want:    request{method: "GET", uri: "/items", host: "api.example.com", header: http.Header{}},
+		},
+		{
+			command: "curl --request PUT --url \"http://localhost:8080/items/7\" \\\n  --header \"X-Note: say \\\"hi\\\" for \\$5\" \\\n  --data-raw 'it'\\''s'",
+			want: request{method: "PUT", uri: "/items/7", host: "localhost:8080", body: "it's",
+				header: http.Header{"X-Note": {`say "hi" for $5`}, "Content-Type": {"application/x-www-form-urlencoded"}}},
+		},
+		{
+			command: `curl -sSL -XDELETE -HAccept:text/plain http://svc/a%2Fb`,
+			want:    request{method: "DELETE", uri: "/a%2Fb", host: "svc", header: http.Header{"Accept": {"text/plain"}}},
+		},
+		{
+			command: `curl http://svc/form -d a=1 --data-binary b=2 -H 'X-Tag: one' -H 'X-Tag: two'`,
+			want: request{method: "POST", uri: "/form", host: "svc", body: "a=1&b=2",
+				header: http.Header{"X-Tag": {"one", "two"}, "Content-Type": {"application/x-www-form-urlencoded"}}},
+		},
+		{
+			command: `curl -u alice:secret -b 'session=abc; theme=dark' -H 'Host: api.example.com' http://127.0.0.1/me`,
+			want: request{method: "GET", uri: "/me", host: "api.example.com", header: http.Header{
+				"Authorization": {"Basic YWxpY2U6c2VjcmV0"},
+				"Cookie":        {"session=abc; theme=dark"},
+			}},
+		},
+		{
+			command: `curl $'http://svc/tab' --data $'line\n\tnext \x41'`,
+			want: request{method: "POST", uri: "/tab", host: "svc", body: "line\n\tnext A",
+				header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}},
+		},
+	}
+
+	for i, tt := range tests {
+		conf, err := FromCurl(http.NewServeMux(), tt.command)
+		if err != nil {
+			t.Fatalf("failure in the test case: %d: %v", i, err)
+		}
+		var got request
+		conf.WithExtraRoute("/", func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			header := r.Header.Clone()
+			header.Del("Content-Length")
+			got = request{method: r.Method, uri: r.RequestURI, host: r.Host, tls: r.TLS != nil, header: header, body: string(b)}
+		})
+		if _, err := conf.Run(ctx); err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, tt.want, got, "failure in the test case: %d", i)
+	}
+}
+
+func Test_FromCurlErrors(t *testing.T) {
+	tests := []struct {
+		command string
+		err     string
+	}{
+		{command: `wget http://svc/`, err: "not a curl command"},
+		{command: `curl -H 'Accept: text/plain'`, err: "curl command without a URL"},
+		{command: `curl http://svc/ -o out.json --retry 3 -H 'A: b'`, err: "unsupported curl flags: -o, --retry"},
+		{command: `curl http://svc/ -d @payload.json`, err: "curl data read from payload.json is not supported, pass it with --data-raw"},
+		{command: `curl http://svc/ -b cookies.txt`, err: "curl cookies read from cookies.txt are not supported"},
+		{command: `curl http://svc/ -H`, err: "curl flag -H without a value"},
+		{command: `curl http://svc/ -H 'broken'`, err: `curl header "broken" is not a key: value pair`},
+		{command: `curl http://a/ http://b/`, err: "curl command with several URLs: http://a/, http://b/"},
+		{command: `curl ftp://svc/file`, err: "curl URL ftp://svc/file is not an http or https URL"},
+		{command: `curl 'http://svc/`, err: "unterminated single quote"},
+		{command: `curl "http://svc/`, err: "unterminated double quote"},
+	}
+	for i, tt := range tests {
+		_, err := FromCurl(http.NewServeMux(), tt.command)
+		assert.EqualError(t, err, tt.err, "failure in the test case: %d", i)
+	}
+}
+
+func Test_FromCurlRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Put(http.NewServeMux(), "/items/7?force=true", `{"name":"it is $5"}`).
+		WithHeaders(Header("Content-Type", "application/json"), Header("X-Note", "it's"))
+	conf.URLPattern = "PUT /items/{id}"
+	var body string
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}
+	command, err := conf.CurlCommand()
+	assert.NoError(t, err)
+
+	replayed, err := FromCurl(http.NewServeMux(), command)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	replayed.URLPattern, replayed.RouteFunc = conf.URLPattern, conf.RouteFunc
+	result, err := replayed.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, `{"name":"it is $5"}`, body)
+	assert.Equal(t, http.MethodPut, result.Request().Method)
+	assert.Equal(t, "/items/7?force=true", result.Request().URL.RequestURI())
+	assert.Equal(t, "it's", result.Request().Header.Get("X-Note"))
+	assert.Equal(t, "localhost:8080", result.Request().Host)
+}
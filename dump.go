@@ -0,0 +1,97 @@
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"unicode/utf8"
+)
+
+const (
+	dumpTextLimit   = 4 << 10 // bytes of a text body shown by the dumps
+	dumpBinaryLimit = 512     // bytes of a binary body shown hex-dumped
+)
+
+// Dump renders the response the way httputil.DumpResponse does, for failure
+// messages and bug reports. Long bodies are truncated and binary bodies are
+// hex-dumped.
+func (r *Result) Dump() string {
+	raw := r.Raw()
+	if raw == nil {
+		return ""
+	}
+	head, err := httputil.DumpResponse(raw, false)
+	if err != nil {
+		return fmt.Sprintf("cannot dump the response: %v", err)
+	}
+	return string(head) + dumpBody(r.Body, len(r.Body))
+}
+
+// Request returns the request Run sent, or the last one when redirects were
+// followed. Its body has been read.
+func (r *Result) Request() *http.Request {
+	return r.request
+}
+
+// DumpRequest renders the request Run sent, or the last one when redirects
+// were followed. The body is included when it can be read again, as for
+// bodies set from strings, bytes, JSON or forms.
+func (r *Result) DumpRequest() string {
+	return r.requestDump
+}
+
+// dumpRequest renders req without consuming its body.
+func dumpRequest(req *http.Request) string {
+	head, err := httputil.DumpRequest(req, false)
+	if err != nil {
+		return fmt.Sprintf("cannot dump the request: %v", err)
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return string(head)
+	}
+	if req.GetBody == nil {
+		return string(head) + "[streamed body not captured]\n"
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return string(head) + fmt.Sprintf("[cannot read the body: %v]\n", err)
+	}
+	defer body.Close()
+	b, err := io.ReadAll(io.LimitReader(body, dumpTextLimit+1))
+	if err != nil {
+		return string(head) + fmt.Sprintf("[cannot read the body: %v]\n", err)
+	}
+	total := len(b)
+	if req.ContentLength > int64(total) {
+		total = int(req.ContentLength)
+	}
+	return string(head) + dumpBody(b, total)
+}
+
+// dumpBody renders the start of a body of total bytes, as text or as a hex
+// dump when it is not UTF-8 text.
+func dumpBody(b []byte, total int) string {
+	if len(b) == 0 {
+		return ""
+	}
+	limit := dumpBinaryLimit
+	text := utf8.Valid(b[:min(len(b), dumpTextLimit)]) && bytes.IndexByte(b, 0) < 0
+	if text {
+		limit = dumpTextLimit
+	}
+	shown := b[:min(len(b), limit)]
+
+	var out string
+	if text {
+		out = string(shown) + "\n"
+	} else {
+		out = hex.Dump(shown)
+	}
+	if total > len(shown) {
+		out += fmt.Sprintf("[truncated, %d more bytes]\n", total-len(shown))
+	}
+	return out
+}
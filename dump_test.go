@@ -0,0 +1,55 @@
+package checkpoint
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ResultDump(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "POST /items"
+	conf.Path = "/items"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-42")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":1}` + strings.Repeat(" ", dumpTextLimit)))
+	}
+	conf.WithJSONBody(map[string]string{"name": "widget"})
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	dump := result.Dump()
+	assert.True(t, strings.HasPrefix(dump, "HTTP/1.1 201 Created\r\n"), dump)
+	assert.Contains(t, dump, "X-Request-Id: req-42\r\n")
+	assert.Contains(t, dump, "\r\n\r\n{\"id\":1}")
+	assert.Contains(t, dump, "[truncated, 8 more bytes]")
+
+	request := result.DumpRequest()
+	assert.True(t, strings.HasPrefix(request, "POST /items HTTP/1.1\r\n"), request)
+	assert.Contains(t, request, "Content-Type: application/json\r\n")
+	assert.Contains(t, request, `{"name":"widget"}`)
+
+	// Binary bodies are hex-dumped.
+	conf = Init(http.NewServeMux())
+	conf.URLPattern = "/blob"
+	conf.Path = "/blob"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(append([]byte{0x00, 0xff, 'A'}, make([]byte, dumpBinaryLimit)...))
+	}
+
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	dump = result.Dump()
+	assert.Contains(t, dump, "00000000  00 ff 41 00")
+	assert.Contains(t, dump, "[truncated, 3 more bytes]")
+}
@@ -0,0 +1,217 @@
+package checkpoint
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TB is the part of testing.TB used by Expect, so *testing.T, *testing.B and
+// fakes can be used.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+	FailNow()
+}
+
+// Expectation checks a Result, collecting the failed checks into a single
+// report with the request and the response dumps. See Result.Expect.
+type Expectation struct {
+	t         TB
+	result    *Result
+	require   bool
+	immediate bool
+	failures  []string
+}
+
+// Expect returns an Expectation on r. Checks are chained, and a failed check
+// does not stop the ones after it unless Require or Strict was called before.
+// The failures are reported together by Done, which runs when the test ends
+// if t has a Cleanup method, as *testing.T does. Without one, each failure is
+// reported as the check fails, so that none is lost.
+func (r *Result) Expect(t TB) *Expectation {
+	e := &Expectation{t: t, result: r}
+	if c, ok := t.(interface{ Cleanup(func()) }); ok {
+		c.Cleanup(e.Done)
+	} else {
+		e.immediate = true
+	}
+	return e
+}
+
+// Require makes the checks that follow stop the test when they fail, after
+// reporting the failures so far.
+func (e *Expectation) Require() *Expectation {
+	e.require = true
+	return e
+}
+
+// Strict stops the test at the first failed check, for those who prefer to
+// fail fast. It is Require set for the whole chain.
+func (e *Expectation) Strict() *Expectation {
+	return e.Require()
+}
+
+// Done reports the failed checks not reported yet, numbered, in one error.
+// Checks chained after Done are reported by the next call.
+func (e *Expectation) Done() {
+	e.t.Helper()
+	if len(e.failures) == 0 {
+		return
+	}
+	e.t.Errorf("%s", e.report())
+	e.failures = nil
+}
+
+// Status checks the status code.
+func (e *Expectation) Status(code int) *Expectation {
+	e.t.Helper()
+	if e.result.StatusCode != code {
+		e.fail("expected status %d, got %d", code, e.result.StatusCode)
+	}
+	return e
+}
+
+// StatusIn checks the status code is one of codes.
+func (e *Expectation) StatusIn(codes ...int) *Expectation {
+	e.t.Helper()
+	if !slices.Contains(codes, e.result.StatusCode) {
+		e.fail("expected status in %v, got %s", codes, describeStatus(e.result))
+	}
+	return e
+}
+
+// StatusClass checks the status code is in the class, such as 2 for 2xx.
+func (e *Expectation) StatusClass(class int) *Expectation {
+	e.t.Helper()
+	if e.result.StatusCode/100 != class {
+		e.fail("expected status %dxx, got %s", class, describeStatus(e.result))
+	}
+	return e
+}
+
+// Header checks the value of a response header. Repeated headers are compared
+// joined with ", ", as in Result.Headers.
+func (e *Expectation) Header(key, value string) *Expectation {
+	e.t.Helper()
+	values := e.result.HeaderValues(key)
+	if len(values) == 0 {
+		e.fail("expected header %s: %s, header is missing", key, value)
+	} else if got := strings.Join(values, ", "); got != value {
+		e.fail("expected header %s: %s, got %s", key, value, got)
+	}
+	return e
+}
+
+// HeaderPresent checks the response has the header.
+func (e *Expectation) HeaderPresent(key string) *Expectation {
+	e.t.Helper()
+	if len(e.result.HeaderValues(key)) == 0 {
+		e.fail("expected header %s, header is missing", key)
+	}
+	return e
+}
+
+// Headers checks the response headers with each matcher, such as
+// HeaderAbsent or ContentTypeIs.
+func (e *Expectation) Headers(matchers ...HeaderMatcher) *Expectation {
+	e.t.Helper()
+	for _, m := range matchers {
+		if err := m(e.result.RawHeaders); err != nil {
+			e.fail("%v", err)
+		}
+	}
+	return e
+}
+
+// HeaderMatches checks the value of a header matches the regular expression
+// expr.
+func (e *Expectation) HeaderMatches(key, expr string) *Expectation {
+	e.t.Helper()
+	return e.Headers(HeaderMatches(key, expr))
+}
+
+// HeaderHasPrefix checks the value of a header starts with prefix.
+func (e *Expectation) HeaderHasPrefix(key, prefix string) *Expectation {
+	e.t.Helper()
+	return e.Headers(HeaderHasPrefix(key, prefix))
+}
+
+// HeaderAbsent checks the response does not have the header.
+func (e *Expectation) HeaderAbsent(key string) *Expectation {
+	e.t.Helper()
+	return e.Headers(HeaderAbsent(key))
+}
+
+// ContentTypeIs checks the media type of the Content-Type, ignoring
+// parameters such as charset.
+func (e *Expectation) ContentTypeIs(mediaType string) *Expectation {
+	e.t.Helper()
+	return e.Headers(ContentTypeIs(mediaType))
+}
+
+// BodyContains checks the body contains s.
+func (e *Expectation) BodyContains(s string) *Expectation {
+	e.t.Helper()
+	if !strings.Contains(e.result.Body.String(), s) {
+		e.fail("expected body to contain %q", s)
+	}
+	return e
+}
+
+// BodyJSONEq checks the body is JSON equivalent to expected, whatever the
+// formatting and the order of object keys. See Result.JSONEq.
+func (e *Expectation) BodyJSONEq(expected string, opts ...JSONOptions) *Expectation {
+	e.t.Helper()
+	if err := e.result.JSONEq(expected, opts...); err != nil {
+		e.fail("expected a JSON body equivalent to %s: %v", expected, err)
+	}
+	return e
+}
+
+// ExecutionOrder checks the Result.ExecutionTrace recorded with
+// TraceMiddlewares set is want, as
+// ExecutionOrder("auth:enter", "handler", "auth:exit").
+func (e *Expectation) ExecutionOrder(want ...string) *Expectation {
+	e.t.Helper()
+	switch {
+	case e.result.ExecutionTrace == nil:
+		e.fail("expected execution order %s, no trace was recorded: set TraceMiddlewares", strings.Join(want, " -> "))
+	case !slices.Equal(e.result.ExecutionTrace, want):
+		e.fail("expected execution order %s, got %s", strings.Join(want, " -> "), strings.Join(e.result.ExecutionTrace, " -> "))
+	}
+	return e
+}
+
+func (e *Expectation) fail(format string, args ...any) {
+	e.t.Helper()
+	failure := fmt.Sprintf(format, args...)
+	e.failures = append(e.failures, failure)
+	e.result.reportFailure(failure)
+	if e.require {
+		e.Done()
+		e.t.FailNow()
+	} else if e.immediate {
+		e.Done()
+	}
+}
+
+// report renders the failures with the request line, followed by the dumps.
+func (e *Expectation) report() string {
+	var b strings.Builder
+	noun := "expectations"
+	if len(e.failures) == 1 {
+		noun = "expectation"
+	}
+	requestLine, _, _ := strings.Cut(e.result.DumpRequest(), "\r\n")
+	requestLine = strings.TrimSuffix(requestLine, " HTTP/1.1")
+	fmt.Fprintf(&b, "%d %s failed for %s:\n", len(e.failures), noun, requestLine)
+	for i, f := range e.failures {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, strings.ReplaceAll(f, "\n", "\n   "))
+	}
+	if by := e.result.WrittenBy; by != nil && !e.result.HandlerReached {
+		fmt.Fprintf(&b, "\nThe response was written by %s, the handler was not reached.\n", by)
+	}
+	fmt.Fprintf(&b, "\nRequest:\n%s\nResponse:\n%s", e.result.DumpRequest(), e.result.Dump())
+	return b.String()
+}
@@ -0,0 +1,131 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeT struct {
+	errors   []string
+	stopped  bool
+	cleanups []func()
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) FailNow() {
+	f.stopped = true
+}
+
+func (f *fakeT) Cleanup(fn func()) {
+	f.cleanups = append(f.cleanups, fn)
+}
+
+// finish runs the cleanup functions as the end of a test would.
+func (f *fakeT) finish() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+	f.cleanups = nil
+}
+
+// noCleanupT hides the Cleanup method of the TB it wraps.
+type noCleanupT struct {
+	TB
+}
+
+func Test_ResultExpect(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/items/{id}"
+	conf.Path = "/items/1"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": 1, "tags": ["a", "b"]}`))
+	}
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	// Passing checks report nothing, with *testing.T as well.
+	result.Expect(t).
+		Status(http.StatusOK).
+		Header("Content-Type", "application/json").
+		HeaderPresent("content-type").
+		BodyContains(`"id": 1`).
+		BodyJSONEq(`{"tags":["a","b"],"id":1}`)
+
+	// Failures are reported together when the test ends.
+	fake := &fakeT{}
+	result.Expect(fake).
+		Status(http.StatusCreated).
+		Header("Content-Type", "text/plain").
+		HeaderPresent("ETag").
+		BodyContains("widget").
+		BodyJSONEq(`{"id":2}`)
+	assert.Empty(t, fake.errors)
+	fake.finish()
+	if assert.Len(t, fake.errors, 1) {
+		report := fake.errors[0]
+		assert.True(t, strings.HasPrefix(report, "5 expectations failed for GET /items/1:\n"+
+			"1. expected status 201, got 200\n"+
+			"2. expected header Content-Type: text/plain, got application/json\n"+
+			"3. expected header ETag, header is missing\n"+
+			`4. expected body to contain "widget"`+"\n"+
+			`5. expected a JSON body equivalent to {"id":2}: JSON bodies differ:`+"\n"+
+			"   $.id: got 1, want 2\n"+
+			`   $.tags: got ["a","b"], want nothing`+"\n"+
+			"\nRequest:\nGET /items/1 HTTP/1.1\r\n"), report)
+		assert.Contains(t, report, "\nResponse:\nHTTP/1.1 200 OK\r\n")
+		assert.Contains(t, report, `{"id": 1, "tags": ["a", "b"]}`)
+	}
+	assert.False(t, fake.stopped)
+
+	// Done reports the failures so far, and only once.
+	fake = &fakeT{}
+	e := result.Expect(fake).Status(http.StatusNotFound)
+	e.Done()
+	e.Done()
+	fake.finish()
+	if assert.Len(t, fake.errors, 1) {
+		assert.True(t, strings.HasPrefix(fake.errors[0], "1 expectation failed for GET /items/1:\n1. expected status 404, got 200\n"))
+	}
+
+	// Require stops at the first failure that follows it, reporting the
+	// failures collected before.
+	fake = &fakeT{}
+	result.Expect(fake).BodyContains("widget").Require().Status(http.StatusOK).Status(http.StatusNotFound)
+	if assert.Len(t, fake.errors, 1) {
+		assert.Contains(t, fake.errors[0], "2 expectations failed for GET /items/1:\n"+
+			`1. expected body to contain "widget"`+"\n"+
+			"2. expected status 404, got 200\n")
+	}
+	assert.True(t, fake.stopped)
+
+	// Strict fails fast.
+	fake = &fakeT{}
+	result.Expect(fake).Strict().Status(http.StatusNotFound)
+	assert.Len(t, fake.errors, 1)
+	assert.True(t, fake.stopped)
+
+	// Without Cleanup, each failure is reported as it happens.
+	fake = &fakeT{}
+	result.Expect(noCleanupT{fake}).Status(http.StatusNotFound).BodyContains("widget")
+	if assert.Len(t, fake.errors, 2) {
+		assert.True(t, strings.HasPrefix(fake.errors[0], "1 expectation failed for GET /items/1:\n1. expected status 404, got 200\n"), fake.errors[0])
+		assert.True(t, strings.HasPrefix(fake.errors[1], "1 expectation failed for GET /items/1:\n"+`1. expected body to contain "widget"`+"\n"), fake.errors[1])
+	}
+	assert.Empty(t, fake.cleanups)
+	assert.False(t, fake.stopped)
+}
@@ -0,0 +1,128 @@
+package checkpoint
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"mime"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Fixture returns the content of the fixture file at path, stopping the test
+// when it cannot be read. JSON fixtures, named *.json, are expanded as
+// text/template first, with {{.now}}, the current time in RFC 3339 format and
+// UTC, {{.uuid}}, a random UUID drawn once per expansion, and the values of
+// vars, which take precedence. A placeholder without a value is an error.
+func Fixture(t TB, path string, vars ...map[string]any) []byte {
+	t.Helper()
+	b, err := readFixture(path, vars)
+	if err != nil {
+		t.Errorf("%v", err)
+		t.FailNow()
+	}
+	return b
+}
+
+// readFixture reads the fixture at path, expanded with vars when it is JSON.
+func readFixture(path string, vars []map[string]any) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the fixture: %w", err)
+	}
+	return expandFixture(path, b, vars)
+}
+
+// expandFixture expands the content b of the fixture at path with vars when
+// it is JSON, and returns it as is otherwise.
+func expandFixture(path string, b []byte, vars []map[string]any) ([]byte, error) {
+	if filepath.Ext(path) != ".json" || !bytes.Contains(b, []byte("{{")) {
+		return b, nil
+	}
+	data := map[string]any{
+		"now":  time.Now().UTC().Format(time.RFC3339),
+		"uuid": newUUID(),
+	}
+	for _, v := range vars {
+		maps.Copy(data, v)
+	}
+	expanded, err := expandTemplate(string(b), data)
+	if err != nil {
+		return nil, fmt.Errorf("expanding the fixture %s: %w", path, err)
+	}
+	return []byte(expanded), nil
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var u [16]byte
+	_, _ = rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// WithBodyFixture sends the fixture file at path as the request body, with
+// the Content-Type header guessed from its extension unless it is set
+// already. JSON fixtures are expanded with vars by every Run, so {{.uuid}}
+// differs from one run to the next; see Fixture.
+func (tc *TestConfig) WithBodyFixture(path string, vars ...map[string]any) *TestConfig {
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	tc.setBody(&requestBody{
+		source:      "WithBodyFixture",
+		contentType: contentType,
+		open: func(*TestConfig) (io.Reader, error) {
+			b, err := readFixture(path, vars)
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(b), nil
+		},
+	})
+	return tc
+}
+
+// AssertBodyFixture compares the response body with the fixture file at path,
+// expanded with vars, and reports the differences to t. JSON bodies are
+// compared as JSON when the fixture is JSON, other bodies as MatchGolden does.
+// As golden files, a missing fixture is created and every fixture is
+// rewritten with the response when golden files are, except those holding
+// placeholders, which would be lost.
+func (r *Result) AssertBodyFixture(t TB, path string, vars ...map[string]any) {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && updateGolden() {
+		if bytes.Contains(raw, []byte("{{")) && filepath.Ext(path) == ".json" {
+			t.Errorf("not rewriting the fixture %s: it holds placeholders, update it by hand", path)
+			return
+		}
+		if err := writeGolden(path, r.snapshot(nil)); err != nil {
+			t.Errorf("writing the fixture: %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("reading the fixture: %v", err)
+		return
+	}
+	want, err := expandFixture(path, raw, vars)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if filepath.Ext(path) == ".json" && isJSON(r.RawHeaders.Get("Content-Type")) {
+		if err := r.JSONEq(string(want)); err != nil {
+			t.Errorf("response body differs from the fixture %s, rerun with CHECKPOINT_UPDATE=1 to accept it: %v", path, err)
+		}
+		return
+	}
+	reportGoldenDiff(t, path, r.snapshot(nil), want)
+}
@@ -0,0 +1,162 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFixture(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+}
+
+func Test_Fixture(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "create_user.json")
+	writeFixture(t, path, `{"id":"{{.uuid}}","name":"{{.name}}","age":{{.age}},"createdAt":"{{.now}}","ref":"{{.uuid}}"}`)
+
+	var user struct {
+		ID        string    `json:"id"`
+		Name      string    `json:"name"`
+		Age       int       `json:"age"`
+		CreatedAt time.Time `json:"createdAt"`
+		Ref       string    `json:"ref"`
+	}
+	b := Fixture(t, path, map[string]any{"name": "ann", "age": 42})
+	if err := json.Unmarshal(b, &user); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, user.ID)
+	assert.Equal(t, user.ID, user.Ref)
+	assert.Equal(t, "ann", user.Name)
+	assert.Equal(t, 42, user.Age)
+	assert.WithinDuration(t, time.Now(), user.CreatedAt, time.Minute)
+
+	// Supplied values take precedence.
+	b = Fixture(t, path, map[string]any{"name": "ann", "age": 1}, map[string]any{"uuid": "fixed", "age": 2})
+	assert.Contains(t, string(b), `"id":"fixed"`)
+	assert.Contains(t, string(b), `"age":2`)
+
+	// Other fixtures are read as they are.
+	raw := filepath.Join(dir, "template.txt")
+	writeFixture(t, raw, "{{.name}}")
+	assert.Equal(t, "{{.name}}", string(Fixture(t, raw)))
+
+	fake := &fakeT{}
+	Fixture(fake, path)
+	assert.True(t, fake.stopped)
+	if assert.Len(t, fake.errors, 1) {
+		assert.Contains(t, fake.errors[0], "expanding the fixture "+path+": ")
+		assert.Contains(t, fake.errors[0], `map has no entry for key "name"`)
+	}
+
+	fake = &fakeT{}
+	Fixture(fake, filepath.Join(dir, "missing.json"))
+	assert.True(t, fake.stopped)
+	assert.Equal(t, []string{"reading the fixture: open " + filepath.Join(dir, "missing.json") + ": no such file or directory"}, fake.errors)
+}
+
+func Test_RunWithBodyFixture(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "order.json")
+	writeFixture(t, path, `{"id":"{{.uuid}}","item":"{{.item}}"}`)
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "POST /orders"
+	conf.Path = "/orders"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		_, _ = io.Copy(w, r.Body)
+	}
+	conf.WithBodyFixture(path, map[string]any{"item": "widget"})
+
+	first, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	second, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "application/json", first.Headers["Content-Type"])
+	assert.Contains(t, first.Body.String(), `"item":"widget"`)
+	assert.NotEqual(t, first.Body.String(), second.Body.String(), "a new uuid is drawn by every run")
+
+	_, err = conf.Clone().WithBodyFixture(path).Run(ctx)
+	assert.ErrorContains(t, err, "expanding the fixture "+path+": ")
+}
+
+func Test_ResultAssertBodyFixture(t *testing.T) {
+	dir := t.TempDir()
+
+	// JSON fixtures are compared as JSON, after expansion.
+	expected := filepath.Join(dir, "user.json")
+	writeFixture(t, expected, `{
+  "name": "ann",
+  "id": {{.id}}
+}`)
+	result := goldenResult(t, "application/json", `{"id":7,"name":"ann"}`)
+	fake := &fakeT{}
+	result.AssertBodyFixture(fake, expected, map[string]any{"id": 7})
+	assert.Empty(t, fake.errors)
+
+	result.AssertBodyFixture(fake, expected, map[string]any{"id": 8})
+	if assert.Len(t, fake.errors, 1) {
+		assert.True(t, strings.HasPrefix(fake.errors[0], "response body differs from the fixture "+expected+", rerun with CHECKPOINT_UPDATE=1 to accept it: "), fake.errors[0])
+		assert.Contains(t, fake.errors[0], "$.id")
+	}
+
+	// Other fixtures are compared as golden files.
+	text := filepath.Join(dir, "greeting.txt")
+	writeFixture(t, text, "hello\n")
+	fake = &fakeT{}
+	goldenResult(t, "text/plain", "hello\n").AssertBodyFixture(fake, text)
+	assert.Empty(t, fake.errors)
+	goldenResult(t, "text/plain", "bye\n").AssertBodyFixture(fake, text)
+	if assert.Len(t, fake.errors, 1) {
+		assert.Contains(t, fake.errors[0], "-hello\n+bye\n")
+	}
+
+	// A missing fixture is created from the response.
+	created := filepath.Join(dir, "testdata", "created.json")
+	fake = &fakeT{}
+	goldenResult(t, "application/json", `{"b":1,"a":2}`).AssertBodyFixture(fake, created)
+	assert.Empty(t, fake.errors)
+	content, err := os.ReadFile(created)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "{\n  \"a\": 2,\n  \"b\": 1\n}\n", string(content))
+
+	// A fixture created from a JSON response is compared as it was written,
+	// even when it is not a .json file.
+	golden := filepath.Join(dir, "testdata", "resp.golden")
+	fake = &fakeT{}
+	goldenResult(t, "application/json", `{"b":1,"a":2}`).AssertBodyFixture(fake, golden)
+	goldenResult(t, "application/json", `{"a":2, "b":1}`).AssertBodyFixture(fake, golden)
+	assert.Empty(t, fake.errors)
+
+	// Update mode rewrites fixtures, except those with placeholders.
+	t.Setenv("CHECKPOINT_UPDATE", "1")
+	goldenResult(t, "text/plain", "bye\n").AssertBodyFixture(fake, text)
+	assert.Empty(t, fake.errors)
+	content, _ = os.ReadFile(text)
+	assert.Equal(t, "bye\n", string(content))
+
+	result.AssertBodyFixture(fake, expected, map[string]any{"id": 7})
+	assert.Equal(t, []string{"not rewriting the fixture " + expected + ": it holds placeholders, update it by hand"}, fake.errors)
+}
@@ -0,0 +1,122 @@
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// GoldenOptions tune the snapshots of Result.MatchGolden.
+type GoldenOptions struct {
+	// Headers lists response headers kept in the snapshot, written before
+	// the body as "Key: value" lines followed by an empty line.
+	Headers []string
+}
+
+// MatchGolden compares the response with the golden file at path and reports
+// a unified diff to t when they differ. JSON bodies are indented with sorted
+// keys before the comparison, other text bodies are compared as they are and
+// binary bodies byte by byte. A missing file is created, and every file is
+// rewritten when tests run with CHECKPOINT_UPDATE=1, or with -update when the
+// test package defines that flag.
+func (r *Result) MatchGolden(t TB, path string, opts ...GoldenOptions) {
+	t.Helper()
+	got := r.snapshot(opts)
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) || updateGolden() {
+		if err := writeGolden(path, got); err != nil {
+			t.Errorf("writing the golden file: %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("reading the golden file: %v", err)
+		return
+	}
+	reportGoldenDiff(t, path, got, want)
+}
+
+// reportGoldenDiff reports to t how got differs from want, the content of
+// the golden file at path, if it does.
+func reportGoldenDiff(t TB, path string, got, want []byte) {
+	t.Helper()
+	if bytes.Equal(got, want) {
+		return
+	}
+
+	if !utf8.Valid(got) || !utf8.Valid(want) {
+		i := 0
+		for i < len(got) && i < len(want) && got[i] == want[i] {
+			i++
+		}
+		t.Errorf("response differs from the golden file %s: got %d bytes, want %d, first difference at byte %d",
+			path, len(got), len(want), i)
+		return
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(want)),
+		B:        difflib.SplitLines(string(got)),
+		FromFile: path,
+		ToFile:   "response",
+		Context:  3,
+	})
+	t.Errorf("response differs from the golden file %s, rerun with CHECKPOINT_UPDATE=1 to accept it:\n%s", path, diff)
+}
+
+// snapshot returns the golden file content of r: the selected headers, then
+// the normalized body.
+func (r *Result) snapshot(opts []GoldenOptions) []byte {
+	var b bytes.Buffer
+	var headers int
+	for _, o := range opts {
+		for _, key := range o.Headers {
+			for _, value := range r.RawHeaders.Values(key) {
+				fmt.Fprintf(&b, "%s: %s\n", http.CanonicalHeaderKey(key), value)
+				headers++
+			}
+		}
+	}
+	if headers > 0 {
+		b.WriteString("\n")
+	}
+
+	if isJSON(r.RawHeaders.Get("Content-Type")) {
+		if v, err := decodeJSON(r.Body); err == nil {
+			enc := json.NewEncoder(&b)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+			if enc.Encode(v) == nil {
+				return b.Bytes()
+			}
+		}
+	}
+	b.Write(r.Body)
+	return b.Bytes()
+}
+
+// updateGolden reports whether golden files are to be rewritten. The -update
+// flag is looked up rather than defined, so that test packages defining their
+// own do not clash with this one.
+func updateGolden() bool {
+	if os.Getenv("CHECKPOINT_UPDATE") == "1" {
+		return true
+	}
+	f := flag.Lookup("update")
+	return f != nil && f.Value.String() == "true"
+}
+
+func writeGolden(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0o644)
+}
@@ -0,0 +1,119 @@
+package checkpoint
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// update is the flag of a test package that defines its own -update, which
+// MatchGolden honors.
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func goldenResult(t *testing.T, contentType, body string) *Result {
+	t.Helper()
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/users"
+	conf.Path = "/users"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write([]byte(body))
+	}
+	result, err := conf.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	return result
+}
+
+func Test_ResultMatchGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "users_list.json")
+	opts := GoldenOptions{Headers: []string{"cache-control"}}
+
+	// The first run creates the file.
+	fake := &fakeT{}
+	result := goldenResult(t, "application/json", `{"users":[{"name":"ann","id":1}],"next":"<a&b>"}`)
+	result.MatchGolden(fake, path, opts)
+	assert.Empty(t, fake.errors)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "Cache-Control: no-store\n\n"+`{
+  "next": "<a&b>",
+  "users": [
+    {
+      "id": 1,
+      "name": "ann"
+    }
+  ]
+}
+`, string(content))
+
+	// Formatting and key order do not matter.
+	result = goldenResult(t, "application/json", `{"next":"<a&b>", "users":[{"id":1,"name":"ann"}]}`)
+	result.MatchGolden(fake, path, opts)
+	assert.Empty(t, fake.errors)
+
+	// Differences are reported as a unified diff, leaving the file alone.
+	result = goldenResult(t, "application/json", `{"next":"<a&b>","users":[{"id":1,"name":"bob"}]}`)
+	result.MatchGolden(fake, path, opts)
+	assert.Equal(t, []string{"response differs from the golden file " + path + ", rerun with CHECKPOINT_UPDATE=1 to accept it:\n" +
+		"--- " + path + "\n" +
+		"+++ response\n" +
+		"@@ -5,7 +5,7 @@\n" +
+		`   "users": [` + "\n" +
+		"     {\n" +
+		`       "id": 1,` + "\n" +
+		`-      "name": "ann"` + "\n" +
+		`+      "name": "bob"` + "\n" +
+		"     }\n" +
+		"   ]\n" +
+		" }\n"}, fake.errors)
+	after, _ := os.ReadFile(path)
+	assert.Equal(t, content, after)
+
+	// Update mode rewrites the file.
+	t.Setenv("CHECKPOINT_UPDATE", "1")
+	fake = &fakeT{}
+	result.MatchGolden(fake, path, opts)
+	assert.Empty(t, fake.errors)
+	after, _ = os.ReadFile(path)
+	assert.Contains(t, string(after), `"name": "bob"`)
+
+	// So does the -update flag of the test package.
+	t.Setenv("CHECKPOINT_UPDATE", "")
+	*update = true
+	t.Cleanup(func() { *update = false })
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	fake = &fakeT{}
+	result.MatchGolden(fake, path, opts)
+	assert.Empty(t, fake.errors)
+	after, _ = os.ReadFile(path)
+	assert.Contains(t, string(after), `"name": "bob"`)
+}
+
+func Test_ResultMatchGoldenBinary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logo.png")
+	if err := os.WriteFile(path, []byte{0x89, 'P', 'N', 'G', 0xff, 0x00}, 0o644); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	fake := &fakeT{}
+	result := goldenResult(t, "image/png", "\x89PNG\xff\x00")
+	result.MatchGolden(fake, path)
+	assert.Empty(t, fake.errors)
+
+	result = goldenResult(t, "image/png", "\x89PNG\xfe\x00\x01")
+	result.MatchGolden(fake, path)
+	assert.Equal(t, []string{"response differs from the golden file " + path +
+		": got 7 bytes, want 6, first difference at byte 4"}, fake.errors)
+}
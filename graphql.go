@@ -0,0 +1,142 @@
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// GraphQLOption tunes WithGraphQL.
+type GraphQLOption func(*graphQLConfig)
+
+type graphQLConfig struct {
+	get bool
+}
+
+// GraphQLOverGET sends the operation as a GET request, the query, the
+// variables as JSON and the operation name being query parameters, rather
+// than as a JSON body. Servers take only queries this way, not mutations.
+func GraphQLOverGET() GraphQLOption {
+	return func(c *graphQLConfig) {
+		c.get = true
+	}
+}
+
+// graphQLRequest is the envelope of a GraphQL operation sent over HTTP.
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	OperationName string         `json:"operationName,omitempty"`
+}
+
+// WithGraphQL sends the GraphQL operation query with variables, both
+// optional, as a POST request with the {"query", "variables",
+// "operationName"} envelope as its JSON body. The Content-Type header is set
+// to application/json unless it is set already. Variables are marshaled by
+// Run, which reports marshaling errors, in either mode. The route must take
+// the method WithGraphQL sets, so a GraphQL endpoint is best routed without
+// one, as "/graphql".
+func (tc *TestConfig) WithGraphQL(query string, variables map[string]any, operationName string, opts ...GraphQLOption) *TestConfig {
+	var c graphQLConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	envelope := graphQLRequest{Query: query, Variables: variables, OperationName: operationName}
+	if c.get {
+		// The body of an earlier call in POST mode would be sent along.
+		if tc.body != nil && tc.body.source == "WithGraphQL" {
+			tc.body = nil
+		}
+		tc.Method = http.MethodGet
+		tc.graphQL = &envelope
+		return tc
+	}
+
+	tc.Method = http.MethodPost
+	tc.graphQL = nil
+	tc.setBody(&requestBody{
+		source:      "WithGraphQL",
+		contentType: "application/json",
+		open: func(*TestConfig) (io.Reader, error) {
+			b, err := json.Marshal(envelope)
+			if err != nil {
+				return nil, fmt.Errorf("marshaling the GraphQL variables: %w", err)
+			}
+			return bytes.NewReader(b), nil
+		},
+	})
+	return tc
+}
+
+// values returns query with the operation added as the query, variables and
+// operationName parameters of a GET request, replacing those already set.
+func (r *graphQLRequest) values(query url.Values) (url.Values, error) {
+	q := cloneValues(query)
+	if q == nil {
+		q = make(url.Values)
+	}
+	q.Set("query", r.Query)
+	q.Del("variables")
+	q.Del("operationName")
+	if len(r.Variables) > 0 {
+		b, err := json.Marshal(r.Variables)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling the GraphQL variables: %w", err)
+		}
+		q.Set("variables", string(b))
+	}
+	if r.OperationName != "" {
+		q.Set("operationName", r.OperationName)
+	}
+	return q, nil
+}
+
+// GraphQLResponse is the response to a GraphQL operation. Data is kept as
+// JSON, to be decoded into the type the operation selects, and is null or
+// missing when the operation failed as a whole.
+type GraphQLResponse struct {
+	Data       json.RawMessage `json:"data,omitempty"`
+	Errors     []GraphQLError  `json:"errors,omitempty"`
+	Extensions map[string]any  `json:"extensions,omitempty"`
+}
+
+// GraphQLError is an error of a GraphQL response. Path holds the field names,
+// as strings, and the list indices, as float64, of the field that failed.
+type GraphQLError struct {
+	Message    string            `json:"message"`
+	Locations  []GraphQLLocation `json:"locations,omitempty"`
+	Path       []any             `json:"path,omitempty"`
+	Extensions map[string]any    `json:"extensions,omitempty"`
+}
+
+// GraphQLLocation is a position in the GraphQL query an error refers to.
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Error returns the message of the error.
+func (e GraphQLError) Error() string {
+	return e.Message
+}
+
+// GraphQL decodes the response body as a GraphQL response. Errors of the
+// operation are returned in the response, not as an error, which is reserved
+// for bodies that are not GraphQL responses, as those of requests rejected
+// before the operation ran.
+func (r *Result) GraphQL() (*GraphQLResponse, error) {
+	var resp GraphQLResponse
+	if err := json.Unmarshal(r.Body, &resp); err != nil {
+		return nil, fmt.Errorf("decoding the GraphQL response: %w", err)
+	}
+	if resp.Data == nil && resp.Errors == nil {
+		return nil, fmt.Errorf("not a GraphQL response: status %d, neither data nor errors", r.StatusCode)
+	}
+	if string(resp.Data) == "null" {
+		resp.Data = nil
+	}
+	return &resp, nil
+}
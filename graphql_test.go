@@ -0,0 +1,197 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// graphQLHandler is a GraphQL-ish endpoint: it echoes the variables and the
+// operation name it was sent, and fails the item field for the id "0".
+func graphQLHandler(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	switch r.Method {
+	case http.MethodPost:
+		if r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if v := r.URL.Query().Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	resp := map[string]any{}
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case req.Query == "":
+		w.WriteHeader(http.StatusBadRequest)
+		resp["errors"] = []map[string]any{{"message": "missing query"}}
+	case req.Variables["id"] == "0":
+		resp["data"] = map[string]any{"item": nil}
+		resp["errors"] = []map[string]any{{
+			"message":    "no such item",
+			"locations":  []map[string]int{{"line": 1, "column": 26}},
+			"path":       []any{"item"},
+			"extensions": map[string]any{"code": "NOT_FOUND", "id": "0"},
+		}}
+	default:
+		resp["data"] = map[string]any{
+			"method":        r.Method,
+			"operationName": req.OperationName,
+			"variables":     req.Variables,
+		}
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+const itemQuery = `query Item($id: ID!) { item(id: $id) { id name } }`
+
+func Test_RunWithGraphQL(t *testing.T) {
+	ctx := context.Background()
+
+	variables := map[string]any{
+		"id":     "7",
+		"filter": map[string]any{"tags": []any{"a", "b"}, "limit": float64(3)},
+		"draft":  false,
+	}
+	tests := []struct {
+		opts   []GraphQLOption
+		method string
+	}{
+		{method: http.MethodPost},
+		{opts: []GraphQLOption{GraphQLOverGET()}, method: http.MethodGet},
+	}
+	for i, tt := range tests {
+		conf := Init(http.NewServeMux())
+		conf.URLPattern = "/graphql"
+		conf.Path = "/graphql"
+		conf.RouteFunc = graphQLHandler
+		result, err := conf.WithGraphQL(itemQuery, variables, "Item", tt.opts...).Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		resp, err := result.GraphQL()
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Empty(t, resp.Errors, "failure in the test case: %d", i)
+
+		var data struct {
+			Method        string         `json:"method"`
+			OperationName string         `json:"operationName"`
+			Variables     map[string]any `json:"variables"`
+		}
+		if err := json.Unmarshal(resp.Data, &data); err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, tt.method, data.Method, "failure in the test case: %d", i)
+		assert.Equal(t, "Item", data.OperationName, "failure in the test case: %d", i)
+		assert.Equal(t, variables, data.Variables, "failure in the test case: %d", i)
+	}
+}
+
+func Test_ResultGraphQLErrors(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/graphql"
+	conf.Path = "/graphql"
+	conf.RouteFunc = graphQLHandler
+
+	// Field errors come with partial data.
+	result, err := conf.Clone().WithGraphQL(itemQuery, map[string]any{"id": "0"}, "").Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	resp, err := result.GraphQL()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.JSONEq(t, `{"item":null}`, string(resp.Data))
+	if assert.Len(t, resp.Errors, 1) {
+		e := resp.Errors[0]
+		assert.Equal(t, "no such item", e.Error())
+		assert.Equal(t, []GraphQLLocation{{Line: 1, Column: 26}}, e.Locations)
+		assert.Equal(t, []any{"item"}, e.Path)
+		assert.Equal(t, map[string]any{"code": "NOT_FOUND", "id": "0"}, e.Extensions)
+	}
+
+	// Requests rejected as a whole have no data.
+	result, err = conf.Clone().WithGraphQL("", nil, "").Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	resp, err = result.GraphQL()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Nil(t, resp.Data)
+	assert.Equal(t, []GraphQLError{{Message: "missing query"}}, resp.Errors)
+
+	// Other bodies are not GraphQL responses.
+	result, err = conf.Clone().WithGraphQL(itemQuery, nil, "").WithHeaders(Header("Content-Type", "text/plain")).Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	_, err = result.GraphQL()
+	assert.True(t, strings.HasPrefix(err.Error(), "decoding the GraphQL response: "), err)
+
+	result = goldenResult(t, "application/json", `{"status":"ok"}`)
+	_, err = result.GraphQL()
+	assert.EqualError(t, err, "not a GraphQL response: status 200, neither data nor errors")
+
+	// Variables that cannot be marshaled fail the run.
+	_, err = conf.Clone().WithGraphQL(itemQuery, map[string]any{"id": make(chan int)}, "").Run(ctx)
+	assert.ErrorContains(t, err, "marshaling the GraphQL variables: json: unsupported type: chan int")
+	_, err = conf.Clone().WithGraphQL(itemQuery, map[string]any{"id": make(chan int)}, "", GraphQLOverGET()).Run(ctx)
+	assert.ErrorContains(t, err, "marshaling the GraphQL variables: json: unsupported type: chan int")
+
+	// The envelope leaves out what is not set.
+	echo := conf.Clone()
+	echo.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	}
+	result, err = echo.WithGraphQL("{ health }", nil, "").Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, `{"query":"{ health }"}`, result.Body.String())
+
+	// Switching to GET drops the body of an earlier call, and the operation
+	// is added to the query at Run, leaving the config's own alone.
+	echo.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		_, _ = fmt.Fprintf(w, "%s %s %q %q", r.Method, r.URL.RawQuery, b, r.Header.Get("Content-Type"))
+	}
+	get := echo.Clone().WithQuery("page", "2").WithGraphQL("{ health }", nil, "").WithGraphQL("{ status }", nil, "", GraphQLOverGET())
+	for range 2 {
+		result, err = get.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, `GET page=2&query=%7B+status+%7D "" ""`, result.Body.String())
+	}
+	assert.Equal(t, url.Values{"page": {"2"}}, get.query)
+
+	_, err = conf.Clone().WithJSONBody(nil).WithGraphQL(itemQuery, nil, "").Run(ctx)
+	assert.ErrorContains(t, err, "WithJSONBody and WithGraphQL both set the request body")
+}
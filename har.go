@@ -0,0 +1,284 @@
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HARRecorder collects runs as HAR 1.2 entries, for tools consuming HTTP
+// Archive files. Attach it to a config with WithHAR, or to every config with
+// OnAfterEveryRun(rec.Record). It is safe for concurrent use.
+type HARRecorder struct {
+	mu      sync.Mutex
+	entries []harEntry
+}
+
+// NewHARRecorder returns an empty HARRecorder.
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{}
+}
+
+// WithHAR records the runs of tc in rec.
+func (tc *TestConfig) WithHAR(rec *HARRecorder) *TestConfig {
+	return tc.OnAfterRun(rec.Record)
+}
+
+// Record adds the exchange of req and result as an entry. It is an
+// AfterRunHook.
+func (rec *HARRecorder) Record(req *http.Request, result *Result) error {
+	entry := newHAREntry(req, result)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.entries = append(rec.entries, entry)
+	return nil
+}
+
+// Len returns the number of entries recorded.
+func (rec *HARRecorder) Len() int {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return len(rec.entries)
+}
+
+// WriteTo writes the recorded entries to w as a HAR document.
+func (rec *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	rec.mu.Lock()
+	doc := harDocument{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "checkpoint", Version: moduleVersion()},
+		Entries: slices.Clone(rec.entries),
+	}}
+	rec.mu.Unlock()
+	if doc.Log.Entries == nil {
+		doc.Log.Entries = []harEntry{}
+	}
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(b, '\n'))
+	return int64(n), err
+}
+
+// moduleVersion returns the version of this module in the running binary.
+func moduleVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == "github.com/rkuprov/checkpoint" {
+				return dep.Version
+			}
+		}
+	}
+	return "(devel)"
+}
+
+type harDocument struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func newHAREntry(req *http.Request, result *Result) harEntry {
+	u := *req.URL
+	if u.Host == "" {
+		u.Host = req.Host
+		if u.Host == "" {
+			u.Host = sessionHost
+		}
+	}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if req.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+	proto := result.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+
+	request := harRequest{
+		Method:      req.Method,
+		URL:         u.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     harCookies(req.Cookies()),
+		Headers:     harHeaders(req.Header),
+		QueryString: harQuery(u.Query()),
+		HeadersSize: -1,
+		BodySize:    req.ContentLength,
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			b, _ := io.ReadAll(body)
+			body.Close()
+			if len(b) > 0 {
+				text, encoding := harText(b)
+				request.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: text, Encoding: encoding}
+			}
+			request.BodySize = int64(len(b))
+		}
+	}
+
+	text, encoding := harText(result.Body)
+	response := harResponse{
+		Status:      result.StatusCode,
+		StatusText:  http.StatusText(result.StatusCode),
+		HTTPVersion: proto,
+		Cookies:     harCookies(result.Cookies()),
+		Headers:     harHeaders(result.RawHeaders),
+		Content: harContent{
+			Size:     len(result.Body),
+			MimeType: result.RawHeaders.Get("Content-Type"),
+			Text:     text,
+			Encoding: encoding,
+		},
+		RedirectURL: result.RawHeaders.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    result.BytesWritten,
+	}
+
+	started := time.Now().Add(-result.Duration)
+	return harEntry{
+		StartedDateTime: started.UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		Time:            milliseconds(result.Duration),
+		Request:         request,
+		Response:        response,
+		Timings: harTimings{
+			Send:    milliseconds(result.Timing.Setup),
+			Wait:    milliseconds(result.Timing.Handler),
+			Receive: milliseconds(result.Timing.BodyRead),
+		},
+	}
+}
+
+// harText returns b as HAR text, base64 encoded when it is binary.
+func harText(b []byte) (text, encoding string) {
+	if utf8.Valid(b) && bytes.IndexByte(b, 0) < 0 {
+		return string(b), ""
+	}
+	return base64.StdEncoding.EncodeToString(b), "base64"
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	list := []harNameValue{}
+	for _, key := range slices.Sorted(maps.Keys(header)) {
+		for _, value := range header[key] {
+			list = append(list, harNameValue{Name: key, Value: value})
+		}
+	}
+	return list
+}
+
+func harQuery(query url.Values) []harNameValue {
+	return harHeaders(http.Header(query))
+}
+
+func harCookies(cookies []*http.Cookie) []harCookie {
+	list := []harCookie{}
+	for _, c := range cookies {
+		hc := harCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			hc.Expires = c.Expires.UTC().Format(time.RFC3339)
+		}
+		list = append(list, hc)
+	}
+	return list
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,136 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HARRecorder(t *testing.T) {
+	ctx := context.Background()
+
+	rec := NewHARRecorder()
+	conf := Init(http.NewServeMux()).
+		WithPath("/har/items").
+		WithQuery("page", "2").
+		WithCookies(&http.Cookie{Name: "session", Value: "abc"}).
+		WithJSONBody(map[string]string{"name": "widget"}).
+		WithHAR(rec)
+	conf.URLPattern = "POST /har/items"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/har/items/1")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":1}`))
+	}
+	if _, err := conf.Run(ctx); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	image := Get(http.NewServeMux(), "/har/image.png").WithHAR(rec)
+	image.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte{0x89, 'P', 'N', 'G', 0x00, 0xff})
+	}
+	if _, err := image.Run(ctx); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, 2, rec.Len())
+
+	path := filepath.Join(t.TempDir(), "run.har")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("creating the HAR file: %v", err)
+	}
+	_, err = rec.WriteTo(f)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading the HAR file: %v", err)
+	}
+	var har struct {
+		Log struct {
+			Version string           `json:"version"`
+			Creator map[string]any   `json:"creator"`
+			Entries []map[string]any `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(b, &har); err != nil {
+		t.Fatalf("decoding the HAR file: %v", err)
+	}
+	assert.Equal(t, "1.2", har.Log.Version)
+	assert.Equal(t, "checkpoint", har.Log.Creator["name"])
+	assert.Contains(t, har.Log.Creator, "version")
+	if !assert.Len(t, har.Log.Entries, 2) {
+		return
+	}
+	for i, entry := range har.Log.Entries {
+		for _, field := range []string{"startedDateTime", "time", "request", "response", "cache", "timings"} {
+			assert.Contains(t, entry, field, "failure in the test case: %d", i)
+		}
+		for _, field := range []string{"method", "url", "httpVersion", "cookies", "headers", "queryString", "headersSize", "bodySize"} {
+			assert.Contains(t, entry["request"], field, "failure in the test case: %d", i)
+		}
+		for _, field := range []string{"status", "statusText", "httpVersion", "cookies", "headers", "content", "redirectURL", "headersSize", "bodySize"} {
+			assert.Contains(t, entry["response"], field, "failure in the test case: %d", i)
+		}
+		for _, field := range []string{"send", "wait", "receive"} {
+			assert.Contains(t, entry["timings"], field, "failure in the test case: %d", i)
+		}
+	}
+
+	request := har.Log.Entries[0]["request"].(map[string]any)
+	assert.Equal(t, "POST", request["method"])
+	assert.Equal(t, "http://checkpoint.test/har/items?page=2", request["url"])
+	assert.Equal(t, []any{map[string]any{"name": "page", "value": "2"}}, request["queryString"])
+	assert.Equal(t, []any{map[string]any{"name": "session", "value": "abc"}}, request["cookies"])
+	assert.Equal(t, map[string]any{"mimeType": "application/json", "text": `{"name":"widget"}`}, request["postData"])
+	assert.Equal(t, float64(17), request["bodySize"])
+	response := har.Log.Entries[0]["response"].(map[string]any)
+	assert.Equal(t, float64(201), response["status"])
+	assert.Equal(t, "Created", response["statusText"])
+	assert.Equal(t, "/har/items/1", response["redirectURL"])
+	assert.Equal(t, map[string]any{"size": float64(8), "mimeType": "application/json", "text": `{"id":1}`}, response["content"])
+
+	content := har.Log.Entries[1]["response"].(map[string]any)["content"].(map[string]any)
+	assert.Equal(t, "base64", content["encoding"])
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte{0x89, 'P', 'N', 'G', 0x00, 0xff}), content["text"])
+	assert.NotContains(t, har.Log.Entries[1]["request"], "postData")
+}
+
+func Test_HARRecorderEveryRun(t *testing.T) {
+	ctx := context.Background()
+	defaultHooks.Lock()
+	after := defaultHooks.after
+	defaultHooks.Unlock()
+	t.Cleanup(func() {
+		defaultHooks.Lock()
+		defaultHooks.after = after
+		defaultHooks.Unlock()
+	})
+
+	rec := NewHARRecorder()
+	OnAfterEveryRun(rec.Record)
+	conf := Get(http.NewServeMux(), "/har/every")
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+	for range 3 {
+		if _, err := conf.Run(ctx); err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+	}
+	assert.Equal(t, 3, rec.Len())
+
+	var buf bytes.Buffer
+	_, err := NewHARRecorder().WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"log":{"version":"1.2","creator":{"name":"checkpoint","version":"(devel)"},"entries":[]}}`, buf.String())
+}
@@ -0,0 +1,96 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// HeaderMatcher checks response headers, returning an error describing the
+// mismatch with the full header value. Matchers are used with
+// Result.MatchHeaders or Expectation.Headers.
+type HeaderMatcher func(h http.Header) error
+
+// HeaderMatches matches a header whose value matches the regular expression
+// expr. Repeated headers are matched joined with ", ".
+func HeaderMatches(key, expr string) HeaderMatcher {
+	return func(h http.Header) error {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid regexp for header %s: %w", key, err)
+		}
+		value, ok := headerValue(h, key)
+		if !ok {
+			return fmt.Errorf("expected header %s matching %s, header is missing", key, expr)
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("expected header %s matching %s, got %s", key, expr, value)
+		}
+		return nil
+	}
+}
+
+// HeaderHasPrefix matches a header whose value starts with prefix.
+func HeaderHasPrefix(key, prefix string) HeaderMatcher {
+	return func(h http.Header) error {
+		value, ok := headerValue(h, key)
+		if !ok {
+			return fmt.Errorf("expected header %s starting with %s, header is missing", key, prefix)
+		}
+		if !strings.HasPrefix(value, prefix) {
+			return fmt.Errorf("expected header %s starting with %s, got %s", key, prefix, value)
+		}
+		return nil
+	}
+}
+
+// HeaderAbsent matches responses without the header, such as one that would
+// leak the server version.
+func HeaderAbsent(key string) HeaderMatcher {
+	return func(h http.Header) error {
+		if value, ok := headerValue(h, key); ok {
+			return fmt.Errorf("expected no header %s, got %s", key, value)
+		}
+		return nil
+	}
+}
+
+// ContentTypeIs matches a Content-Type of the given media type, whatever its
+// parameters: "application/json" matches "application/json; charset=utf-8".
+// Media types are compared case-insensitively.
+func ContentTypeIs(mediaType string) HeaderMatcher {
+	return func(h http.Header) error {
+		value, ok := headerValue(h, "Content-Type")
+		if !ok {
+			return fmt.Errorf("expected content type %s, header Content-Type is missing", mediaType)
+		}
+		got, _, err := mime.ParseMediaType(value)
+		if err != nil {
+			return fmt.Errorf("expected content type %s, got %s: %w", mediaType, value, err)
+		}
+		if !strings.EqualFold(got, mediaType) {
+			return fmt.Errorf("expected content type %s, got %s", mediaType, value)
+		}
+		return nil
+	}
+}
+
+// MatchHeaders checks the response headers with every matcher, returning
+// their errors joined.
+func (r *Result) MatchHeaders(matchers ...HeaderMatcher) error {
+	var errs []error
+	for _, m := range matchers {
+		errs = append(errs, m(r.RawHeaders))
+	}
+	return errors.Join(errs...)
+}
+
+// headerValue returns the values of a header joined with ", ", and whether
+// there is any.
+func headerValue(h http.Header, key string) (string, bool) {
+	values := h.Values(key)
+	return strings.Join(values, ", "), len(values) > 0
+}
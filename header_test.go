@@ -0,0 +1,89 @@
+package checkpoint
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ResultMatchHeaders(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/items/{id}"
+	conf.Path = "/items/1"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("ETag", `W/"5e1f"`)
+		w.Header().Set("X-Powered-By", "Go 1.24")
+		w.Header().Add("Vary", "Accept")
+		w.Header().Add("Vary", "Origin")
+		_, _ = w.Write([]byte(`{}`))
+	}
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	tc := []struct {
+		matcher HeaderMatcher
+		wantErr string
+	}{
+		{matcher: ContentTypeIs("application/json")},
+		{matcher: ContentTypeIs("Application/JSON")},
+		{
+			matcher: ContentTypeIs("text/html"),
+			wantErr: "expected content type text/html, got application/json; charset=utf-8",
+		},
+		{matcher: HeaderMatches("etag", `^W/"[0-9a-f]+"$`)},
+		{matcher: HeaderMatches("Vary", `Accept, Origin`)},
+		{
+			matcher: HeaderMatches("ETag", `^"`),
+			wantErr: `expected header ETag matching ^", got W/"5e1f"`,
+		},
+		{
+			matcher: HeaderMatches("ETag", `(`),
+			wantErr: "invalid regexp for header ETag: error parsing regexp: missing closing ): `(`",
+		},
+		{matcher: HeaderHasPrefix("Content-Type", "application/json")},
+		{
+			matcher: HeaderHasPrefix("Cache-Control", "no-store"),
+			wantErr: "expected header Cache-Control starting with no-store, header is missing",
+		},
+		{matcher: HeaderAbsent("Server")},
+		{
+			matcher: HeaderAbsent("X-Powered-By"),
+			wantErr: "expected no header X-Powered-By, got Go 1.24",
+		},
+	}
+
+	for i, c := range tc {
+		err := result.MatchHeaders(c.matcher)
+		if c.wantErr == "" {
+			assert.NoError(t, err, "failure in the test case: %d", i)
+		} else {
+			assert.EqualError(t, err, c.wantErr, "failure in the test case: %d", i)
+		}
+	}
+
+	result.Expect(t).
+		ContentTypeIs("application/json").
+		HeaderMatches("ETag", `^W/`).
+		HeaderHasPrefix("Content-Type", "application/").
+		HeaderAbsent("Strict-Transport-Security")
+
+	fake := &fakeT{}
+	result.Expect(fake).
+		ContentTypeIs("text/plain").
+		HeaderAbsent("X-Powered-By").
+		Headers(HeaderAbsent("ETag"), ContentTypeIs("application/json")).
+		Done()
+	if assert.Len(t, fake.errors, 1) {
+		assert.Contains(t, fake.errors[0], "3 expectations failed for GET /items/1:\n"+
+			"1. expected content type text/plain, got application/json; charset=utf-8\n"+
+			"2. expected no header X-Powered-By, got Go 1.24\n"+
+			`3. expected no header ETag, got W/"5e1f"`+"\n")
+	}
+}
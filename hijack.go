@@ -0,0 +1,89 @@
+package checkpoint
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ErrHijackNotAllowed is returned by Run when the handler hijacked the
+// connection without AllowHijack set.
+var ErrHijackNotAllowed = errors.New("the handler hijacked the connection: set AllowHijack to get it as Result.HijackedConn, or use RunServer")
+
+// hijackWriter is the writer Run serves the handler with, letting it hijack
+// an in-memory connection when AllowHijack is set. Without it, the attempt is
+// recorded and fails.
+type hijackWriter struct {
+	http.ResponseWriter
+	allow    bool
+	hijacked chan struct{} // closed once the handler hijacked the connection
+
+	mu        sync.Mutex
+	attempted bool
+	client    net.Conn // the test end of the connection
+}
+
+func newHijackWriter(w http.ResponseWriter, allow bool) *hijackWriter {
+	return &hijackWriter{ResponseWriter: w, allow: allow, hijacked: make(chan struct{})}
+}
+
+// Hijack hands the handler its end of a net.Pipe, the other end being
+// Result.HijackedConn.
+func (h *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch {
+	case h.client != nil:
+		return nil, nil, http.ErrHijacked
+	case !h.allow:
+		h.attempted = true
+		return nil, nil, ErrHijackNotAllowed
+	}
+	server, client := net.Pipe()
+	h.client = client
+	close(h.hijacked)
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+// conn returns the test end of the hijacked connection, and
+// ErrHijackNotAllowed if the handler tried to hijack it without AllowHijack.
+func (h *hijackWriter) conn() (net.Conn, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.attempted {
+		return nil, ErrHijackNotAllowed
+	}
+	return h.client, nil
+}
+
+func (h *hijackWriter) isHijacked() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.client != nil
+}
+
+func (h *hijackWriter) WriteHeader(code int) {
+	if !h.isHijacked() {
+		h.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (h *hijackWriter) Write(p []byte) (int, error) {
+	if h.isHijacked() {
+		return 0, http.ErrHijacked
+	}
+	return h.ResponseWriter.Write(p)
+}
+
+func (h *hijackWriter) Flush() {
+	if f, ok := h.ResponseWriter.(http.Flusher); ok && !h.isHijacked() {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the recorder.
+func (h *hijackWriter) Unwrap() http.ResponseWriter {
+	return h.ResponseWriter
+}
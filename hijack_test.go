@@ -0,0 +1,95 @@
+package checkpoint
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rawHandler hijacks the connection and answers HTTP/0.9 style: the body
+// alone, ended by closing the connection.
+func rawHandler(w http.ResponseWriter, r *http.Request) {
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+	_, _ = buf.WriteString("<html>legacy</html>\n")
+	_ = buf.Flush()
+}
+
+func Test_RunHijack(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Get(http.NewServeMux(), "/legacy").WithMiddlewares(passThrough)
+	conf.RouteFunc = rawHandler
+
+	// Without AllowHijack the attempt is reported.
+	_, err := conf.Run(ctx)
+	assert.ErrorIs(t, err, ErrHijackNotAllowed)
+	assert.ErrorContains(t, err, "use RunServer")
+
+	conf.AllowHijack = true
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !assert.NotNil(t, result.HijackedConn) {
+		return
+	}
+	defer result.HijackedConn.Close()
+	raw, err := io.ReadAll(result.HijackedConn)
+	assert.NoError(t, err)
+	assert.Equal(t, "<html>legacy</html>\n", string(raw))
+	assert.True(t, result.HandlerReached)
+}
+
+func Test_RunHijackInteractive(t *testing.T) {
+	ctx := context.Background()
+
+	// The handler speaks a line protocol once it hijacked the connection.
+	var writeErr error
+	conf := Get(http.NewServeMux(), "/echo")
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, writeErr = w.Write([]byte("too late"))
+		for {
+			line, err := buf.ReadString('\n')
+			if err != nil || line == "quit\n" {
+				return
+			}
+			_, _ = buf.WriteString("echo: " + line)
+			_ = buf.Flush()
+		}
+	}
+	conf.AllowHijack = true
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	conn := result.HijackedConn
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for _, line := range []string{"ping\n", "pong\n"} {
+		_, err := conn.Write([]byte(line))
+		assert.NoError(t, err)
+		reply, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "echo: "+line, reply)
+	}
+	_, err = conn.Write([]byte("quit\n"))
+	assert.NoError(t, err)
+	_, err = reader.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+	assert.ErrorIs(t, writeErr, http.ErrHijacked)
+}
@@ -0,0 +1,92 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// BeforeRunHook is called with the request about to be served. An error
+// fails the run before the request is served.
+type BeforeRunHook func(req *http.Request) error
+
+// AfterRunHook is called with the last request served and its Result, also
+// when the handler failed with an error status. An error fails the run, which
+// still returns the Result.
+type AfterRunHook func(req *http.Request, result *Result) error
+
+var defaultHooks = struct {
+	sync.RWMutex
+	before []BeforeRunHook
+	after  []AfterRunHook
+}{}
+
+// OnBeforeEveryRun adds a hook called before the request of every config is
+// served, as to reset a fake database, ahead of the hooks of the config. It is
+// meant to be called once, from TestMain or an init function.
+func OnBeforeEveryRun(hook BeforeRunHook) {
+	defaultHooks.Lock()
+	defer defaultHooks.Unlock()
+	defaultHooks.before = append(defaultHooks.before, hook)
+}
+
+// OnAfterEveryRun adds a hook called after the request of every config was
+// served, as to log every request and response, ahead of the hooks of the
+// config. It is meant to be called once, from TestMain or an init function.
+func OnAfterEveryRun(hook AfterRunHook) {
+	defaultHooks.Lock()
+	defer defaultHooks.Unlock()
+	defaultHooks.after = append(defaultHooks.after, hook)
+}
+
+// OnBeforeRun adds a hook called by Run and RunServer with the request before
+// it is served. Hooks are called in the order they were added.
+func (tc *TestConfig) OnBeforeRun(hook BeforeRunHook) *TestConfig {
+	tc.beforeHooks = append(tc.beforeHooks, hook)
+	return tc
+}
+
+// OnAfterRun adds a hook called by Run and RunServer with the request and the
+// Result once it was served. Hooks are called in the order they were added.
+func (tc *TestConfig) OnAfterRun(hook AfterRunHook) *TestConfig {
+	tc.afterHooks = append(tc.afterHooks, hook)
+	return tc
+}
+
+// beforeRun calls the default and the config before-run hooks with req.
+func (tc *TestConfig) beforeRun(req *http.Request) error {
+	defaultHooks.RLock()
+	hooks := append(defaultHooks.before[:len(defaultHooks.before):len(defaultHooks.before)], tc.beforeHooks...)
+	defaultHooks.RUnlock()
+
+	for i, hook := range hooks {
+		if err := hook(req); err != nil {
+			return fmt.Errorf("before-run hook %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// afterRun calls the default and the config after-run hooks with the result
+// of a run that served its request, joining their error to err, logs the run
+// and adds it to the Reporter.
+func (tc *TestConfig) afterRun(result *Result, err error) (*Result, error) {
+	if result == nil || result.request == nil {
+		tc.report(result, err)
+		return result, err
+	}
+	defaultHooks.RLock()
+	hooks := append(defaultHooks.after[:len(defaultHooks.after):len(defaultHooks.after)], tc.afterHooks...)
+	defaultHooks.RUnlock()
+
+	for i, hook := range hooks {
+		if hookErr := hook(result.request, result); hookErr != nil {
+			err = errors.Join(err, fmt.Errorf("after-run hook %d: %w", i+1, hookErr))
+			break
+		}
+	}
+	tc.logRun(result.request, result, err)
+	tc.report(result, err)
+	return result, err
+}
@@ -0,0 +1,124 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RunHooks(t *testing.T) {
+	ctx := context.Background()
+
+	var calls []string
+	conf := Get(http.NewServeMux(), "/hooks/items").
+		OnBeforeRun(func(req *http.Request) error {
+			calls = append(calls, "before 1 "+req.URL.Path)
+			req.Header.Set("X-Trace", "abc")
+			return nil
+		}).
+		OnBeforeRun(func(req *http.Request) error {
+			calls = append(calls, "before 2")
+			return nil
+		}).
+		OnAfterRun(func(req *http.Request, result *Result) error {
+			calls = append(calls, "after 1 "+result.Body.String())
+			return nil
+		}).
+		OnAfterRun(func(req *http.Request, result *Result) error {
+			calls = append(calls, "after 2 "+req.Header.Get("X-Trace"))
+			return nil
+		})
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler "+r.Header.Get("X-Trace"))
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusInternalServerError, result.StatusCode)
+	assert.Equal(t, []string{
+		"before 1 /hooks/items",
+		"before 2",
+		"handler abc",
+		"after 1 broken\n",
+		"after 2 abc",
+	}, calls)
+
+	calls = nil
+	_, err = conf.RunServer(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Len(t, calls, 5)
+}
+
+func Test_RunHookErrors(t *testing.T) {
+	ctx := context.Background()
+
+	served := false
+	conf := Get(http.NewServeMux(), "/hooks/errors")
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	}
+
+	failing := conf.Clone().OnBeforeRun(func(*http.Request) error {
+		return errors.New("database not reset")
+	})
+	result, err := failing.Run(ctx)
+	assert.EqualError(t, err, "before-run hook 1: database not reset")
+	assert.Nil(t, result)
+	assert.False(t, served)
+
+	failing = conf.Clone().
+		OnAfterRun(func(*http.Request, *Result) error { return nil }).
+		OnAfterRun(func(*http.Request, *Result) error { return errors.New("log closed") })
+	result, err = failing.Run(ctx)
+	assert.EqualError(t, err, "after-run hook 2: log closed")
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.True(t, served)
+
+	// The hooks of a clone do not affect the original.
+	_, err = conf.Run(ctx)
+	assert.NoError(t, err)
+}
+
+func Test_RunDefaultHooks(t *testing.T) {
+	ctx := context.Background()
+	defaultHooks.Lock()
+	before, after := defaultHooks.before, defaultHooks.after
+	defaultHooks.Unlock()
+	t.Cleanup(func() {
+		defaultHooks.Lock()
+		defaultHooks.before, defaultHooks.after = before, after
+		defaultHooks.Unlock()
+	})
+
+	var calls []string
+	OnBeforeEveryRun(func(*http.Request) error {
+		calls = append(calls, "default before")
+		return nil
+	})
+	OnAfterEveryRun(func(req *http.Request, result *Result) error {
+		calls = append(calls, req.Method+" "+req.URL.Path+" "+http.StatusText(result.StatusCode))
+		return nil
+	})
+
+	conf := Post(http.NewServeMux(), "/hooks/default", "").
+		OnBeforeRun(func(*http.Request) error {
+			calls = append(calls, "config before")
+			return nil
+		})
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	_, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, []string{"default before", "config before", "POST /hooks/default Accepted"}, calls)
+}
@@ -0,0 +1,361 @@
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// JSONOptions tune the JSON comparisons of Result.
+type JSONOptions struct {
+	// IgnorePaths lists paths left out of the comparison, such as timestamps
+	// or generated IDs, written as "$.items[0].id". [*] matches any index and
+	// .* any key, as in "$.items[*].createdAt".
+	IgnorePaths []string
+}
+
+// JSONEq reports whether the body is JSON equivalent to expected: object keys
+// may come in any order, formatting is ignored and numbers are compared by
+// value, so 1 equals 1.0. The error lists every difference by path, as in
+// `$.items[2].name: got "a", want "b"`.
+func (r *Result) JSONEq(expected string, opts ...JSONOptions) error {
+	want, err := decodeJSON([]byte(expected))
+	if err != nil {
+		return fmt.Errorf("expected JSON is invalid: %w", err)
+	}
+	got, err := decodeJSON(r.Body)
+	if err != nil {
+		return fmt.Errorf("body is not JSON: %w", err)
+	}
+	var ignore []*regexp.Regexp
+	for _, o := range opts {
+		for _, path := range o.IgnorePaths {
+			ignore = append(ignore, pathRegexp(path))
+		}
+	}
+
+	var diffs []string
+	diffJSON("$", got, want, ignore, &diffs)
+	if len(diffs) > 0 {
+		return fmt.Errorf("JSON bodies differ:\n%s", strings.Join(diffs, "\n"))
+	}
+	return nil
+}
+
+// AssertJSONEq reports to t when the body is not JSON equivalent to expected,
+// see JSONEq.
+func (r *Result) AssertJSONEq(t TB, expected string, opts ...JSONOptions) {
+	t.Helper()
+	if err := r.JSONEq(expected, opts...); err != nil {
+		t.Errorf("%v", err)
+	}
+}
+
+// decodeJSON decodes b, which must hold a single JSON value, keeping numbers
+// as json.Number.
+func decodeJSON(b []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("invalid data after the top-level JSON value")
+	}
+	return v, nil
+}
+
+// pathRegexp compiles an ignored path, with [*] and .* wildcards.
+func pathRegexp(path string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(path)
+	expr = strings.ReplaceAll(expr, `\[\*\]`, `\[\d+\]`)
+	expr = strings.ReplaceAll(expr, `\.\*`, `\.[^.\[]+`)
+	return regexp.MustCompile("^" + expr + "$")
+}
+
+// diffJSON appends the differences between got and want at path to diffs.
+func diffJSON(path string, got, want any, ignore []*regexp.Regexp, diffs *[]string) {
+	if ignored(path, ignore) {
+		return
+	}
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(w)+len(g))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		for k := range g {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range slices.Compact(keys) {
+			gv, inGot := g[k]
+			wv, inWant := w[k]
+			child := path + "." + k
+			switch {
+			case !inGot:
+				if !ignored(child, ignore) {
+					*diffs = append(*diffs, fmt.Sprintf("%s: missing, want %s", child, jsonString(wv)))
+				}
+			case !inWant:
+				if !ignored(child, ignore) {
+					*diffs = append(*diffs, fmt.Sprintf("%s: got %s, want nothing", child, jsonString(gv)))
+				}
+			default:
+				diffJSON(child, gv, wv, ignore, diffs)
+			}
+		}
+		return
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			break
+		}
+		if len(g) != len(w) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: got %d elements, want %d", path, len(g), len(w)))
+		}
+		for i := range min(len(g), len(w)) {
+			diffJSON(fmt.Sprintf("%s[%d]", path, i), g[i], w[i], ignore, diffs)
+		}
+		return
+	case json.Number:
+		if g, ok := got.(json.Number); ok && numbersEqual(g, w) {
+			return
+		}
+	default:
+		if got == want {
+			return
+		}
+	}
+	*diffs = append(*diffs, fmt.Sprintf("%s: got %s, want %s", path, jsonString(got), jsonString(want)))
+}
+
+func ignored(path string, ignore []*regexp.Regexp) bool {
+	for _, re := range ignore {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// numbersEqual compares JSON numbers by value, exactly.
+func numbersEqual(a, b json.Number) bool {
+	x, okX := new(big.Rat).SetString(a.String())
+	y, okY := new(big.Rat).SetString(b.String())
+	if !okX || !okY {
+		return a == b
+	}
+	return x.Cmp(y) == 0
+}
+
+func jsonString(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// ErrPathNotFound is returned by Result.JSONPath when nothing matches the path.
+var ErrPathNotFound = errors.New("path not found")
+
+// JSONPath returns the value at path in the JSON body. A practical subset of
+// JSONPath is supported: $ for the root, .name and ['name'] for object
+// fields, [n] for array elements (negative n counts from the end), [*] and .*
+// for every element or field, and ..name for the name fields at any depth.
+// Paths with wildcards or .. return the []any of the matches. Numbers are
+// float64, as with encoding/json.
+func (r *Result) JSONPath(path string) (any, error) {
+	var doc any
+	if err := json.Unmarshal(r.Body, &doc); err != nil {
+		return nil, fmt.Errorf("body is not JSON: %w", err)
+	}
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []any{doc}
+	multiple := false
+	for _, s := range steps {
+		multiple = multiple || s.wildcard || s.recursive
+		var next []any
+		for _, n := range nodes {
+			next = append(next, s.apply(n)...)
+		}
+		nodes = next
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPathNotFound, path)
+	}
+	if multiple {
+		return nodes, nil
+	}
+	return nodes[0], nil
+}
+
+// AssertJSONPath reports to t when path is not found in the JSON body or the
+// value there is not JSON equivalent to expected.
+func (r *Result) AssertJSONPath(t TB, path string, expected any) {
+	t.Helper()
+	got, err := r.JSONPath(path)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	want, err := json.Marshal(expected)
+	if err != nil {
+		t.Errorf("cannot encode the expected value: %v", err)
+		return
+	}
+	gotJSON, _ := json.Marshal(got)
+	g, _ := decodeJSON(gotJSON)
+	w, _ := decodeJSON(want)
+	var diffs []string
+	diffJSON(path, g, w, nil, &diffs)
+	if len(diffs) > 0 {
+		t.Errorf("value mismatch:\n%s", strings.Join(diffs, "\n"))
+	}
+}
+
+// jsonPathStep is one step of a JSONPath.
+type jsonPathStep struct {
+	field     string
+	index     int
+	isIndex   bool
+	wildcard  bool
+	recursive bool // ..field
+}
+
+func (s jsonPathStep) apply(node any) []any {
+	if s.recursive {
+		var found []any
+		var walk func(any)
+		walk = func(n any) {
+			switch v := n.(type) {
+			case map[string]any:
+				if child, ok := v[s.field]; ok {
+					found = append(found, child)
+				}
+				for _, k := range sortedKeys(v) {
+					walk(v[k])
+				}
+			case []any:
+				for _, child := range v {
+					walk(child)
+				}
+			}
+		}
+		walk(node)
+		return found
+	}
+	switch v := node.(type) {
+	case map[string]any:
+		if s.wildcard {
+			var children []any
+			for _, k := range sortedKeys(v) {
+				children = append(children, v[k])
+			}
+			return children
+		}
+		if child, ok := v[s.field]; ok && !s.isIndex {
+			return []any{child}
+		}
+	case []any:
+		if s.wildcard {
+			return v
+		}
+		i := s.index
+		if i < 0 {
+			i += len(v)
+		}
+		if s.isIndex && i >= 0 && i < len(v) {
+			return []any{v[i]}
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// parseJSONPath splits path into steps.
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("invalid path %s: must start with $", path)
+	}
+	var steps []jsonPathStep
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			name, tail := cutName(rest[2:])
+			if name == "" {
+				return nil, fmt.Errorf("invalid path %s: missing field after ..", path)
+			}
+			steps = append(steps, jsonPathStep{field: name, recursive: true})
+			rest = tail
+		case rest[0] == '.':
+			name, tail := cutName(rest[1:])
+			switch name {
+			case "":
+				return nil, fmt.Errorf("invalid path %s: missing field after .", path)
+			case "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			default:
+				steps = append(steps, jsonPathStep{field: name})
+			}
+			rest = tail
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %s: unclosed [", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				steps = append(steps, jsonPathStep{field: inner[1 : len(inner)-1]})
+			default:
+				i, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid path %s: bad index [%s]", path, inner)
+				}
+				steps = append(steps, jsonPathStep{index: i, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("invalid path %s: unexpected %q", path, rest[0])
+		}
+	}
+	return steps, nil
+}
+
+// cutName splits s after the field name it starts with.
+func cutName(s string) (name, rest string) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
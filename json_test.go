@@ -0,0 +1,143 @@
+package checkpoint
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// jsonResult runs a handler writing body and returns its Result.
+func jsonResult(t *testing.T, body string) *Result {
+	t.Helper()
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/data"
+	conf.Path = "/data"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}
+	result, err := conf.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	return result
+}
+
+func Test_ResultJSONEq(t *testing.T) {
+	result := jsonResult(t, `{
+		"id": 1.0,
+		"owner": {"name": "ann", "roles": ["admin"]},
+		"items": [{"name": "a", "createdAt": "2024-01-01"}, {"name": "b", "createdAt": "2024-01-02"}],
+		"requestId": "xyz"
+	}`)
+
+	tc := []struct {
+		expected string
+		opts     []JSONOptions
+		wantErr  string
+	}{
+		{
+			expected: `{"requestId":"xyz","items":[{"createdAt":"2024-01-01","name":"a"},{"createdAt":"2024-01-02","name":"b"}],` +
+				`"owner":{"roles":["admin"],"name":"ann"},"id":1}`,
+		},
+		{
+			expected: `{"id":2,"owner":{"name":"bob","roles":["admin","dev"]},"items":[{"name":"a","createdAt":"2024-01-01"},` +
+				`{"name":"c","createdAt":"2024-01-02"}]}`,
+			wantErr: "JSON bodies differ:\n" +
+				"$.id: got 1.0, want 2\n" +
+				`$.items[1].name: got "b", want "c"` + "\n" +
+				`$.owner.name: got "ann", want "bob"` + "\n" +
+				"$.owner.roles: got 1 elements, want 2\n" +
+				`$.requestId: got "xyz", want nothing`,
+		},
+		{
+			expected: `{"id":1,"owner":{"name":"ann","roles":["admin"]},"items":[{"name":"a"},{"name":"b","createdAt":"today"}]}`,
+			opts:     []JSONOptions{{IgnorePaths: []string{"$.requestId", "$.items[*].createdAt"}}},
+		},
+		{
+			expected: `{"id":1,"owner":{"name":"ann","roles":["admin"]},"items":[],"extra":true}`,
+			opts:     []JSONOptions{{IgnorePaths: []string{"$.requestId", "$.items"}}},
+			wantErr:  "JSON bodies differ:\n$.extra: missing, want true",
+		},
+		{
+			expected: `{"id":`,
+			wantErr:  "expected JSON is invalid: unexpected EOF",
+		},
+		{
+			expected: `{"id":1} {"id":2}`,
+			wantErr:  "expected JSON is invalid: invalid data after the top-level JSON value",
+		},
+	}
+
+	for i, c := range tc {
+		err := result.JSONEq(c.expected, c.opts...)
+		if c.wantErr == "" {
+			assert.NoError(t, err, "failure in the test case: %d", i)
+		} else {
+			assert.EqualError(t, err, c.wantErr, "failure in the test case: %d", i)
+		}
+	}
+
+	fake := &fakeT{}
+	result.AssertJSONEq(fake, `{"id":3}`, JSONOptions{IgnorePaths: []string{"$.*"}})
+	assert.Empty(t, fake.errors)
+	result.AssertJSONEq(fake, `[1]`)
+	assert.Equal(t, []string{"JSON bodies differ:\n$: got {\"id\":1.0,\"items\":[{\"createdAt\":\"2024-01-01\",\"name\":\"a\"}," +
+		"{\"createdAt\":\"2024-01-02\",\"name\":\"b\"}],\"owner\":{\"name\":\"ann\",\"roles\":[\"admin\"]},\"requestId\":\"xyz\"}, want [1]"},
+		fake.errors)
+}
+
+func Test_ResultJSONPath(t *testing.T) {
+	result := jsonResult(t, `{"store":{"name":"corner","books":[`+
+		`{"title":"A","price":8.5,"tags":["new"]},`+
+		`{"title":"B","price":12,"author":{"name":"ann"}}],`+
+		`"owner":{"name":"bob"}}}`)
+
+	tc := []struct {
+		path    string
+		want    any
+		wantErr string
+	}{
+		{path: "$.store.name", want: "corner"},
+		{path: "$['store']['name']", want: "corner"},
+		{path: "$.store.books[1].price", want: 12.0},
+		{path: "$.store.books[-1].title", want: "B"},
+		{path: "$.store.books[0].tags[0]", want: "new"},
+		{path: "$.store.books[*].title", want: []any{"A", "B"}},
+		{path: "$.store.owner.*", want: []any{"bob"}},
+		{path: "$..name", want: []any{"corner", "ann", "bob"}},
+		{path: "$.store.books[2]", wantErr: "path not found: $.store.books[2]"},
+		{path: "$.store.missing", wantErr: "path not found: $.store.missing"},
+		{path: "$..isbn", wantErr: "path not found: $..isbn"},
+		{path: "store.name", wantErr: "invalid path store.name: must start with $"},
+		{path: "$.store.books[x]", wantErr: "invalid path $.store.books[x]: bad index [x]"},
+	}
+
+	for i, c := range tc {
+		got, err := result.JSONPath(c.path)
+		if c.wantErr != "" {
+			assert.EqualError(t, err, c.wantErr, "failure in the test case: %d", i)
+			continue
+		}
+		assert.NoError(t, err, "failure in the test case: %d", i)
+		assert.Equal(t, c.want, got, "failure in the test case: %d", i)
+	}
+
+	_, err := result.JSONPath("$.nope")
+	assert.ErrorIs(t, err, ErrPathNotFound)
+
+	fake := &fakeT{}
+	result.AssertJSONPath(fake, "$.store.books[1].price", 12)
+	result.AssertJSONPath(fake, "$.store.books[*].title", []string{"A", "B"})
+	result.AssertJSONPath(fake, "$.store.books[1].author", map[string]any{"name": "ann"})
+	assert.Empty(t, fake.errors)
+
+	result.AssertJSONPath(fake, "$.store.books[0].price", 9)
+	result.AssertJSONPath(fake, "$.store.isbn", "x")
+	assert.Equal(t, []string{
+		"value mismatch:\n$.store.books[0].price: got 8.5, want 9",
+		"path not found: $.store.isbn",
+	}, fake.errors)
+}
@@ -0,0 +1,258 @@
+package checkpoint
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Layer is a layer of the handler chain Run serves: one of Middlewares, or the
+// handler itself.
+type Layer struct {
+	Index   int    // the index in Middlewares, the outermost being 0, or len(Middlewares) for the handler
+	Name    string // set by WithNamedMiddleware, "middleware[i]" otherwise, and "handler" for the handler
+	Handler bool   // the route handler, or the router with UseExistingRoutes
+}
+
+// String returns the name of the layer.
+func (l Layer) String() string {
+	switch {
+	case l.Name != "":
+		return l.Name
+	case l.Handler:
+		return "handler"
+	}
+	return fmt.Sprintf("middleware[%d]", l.Index)
+}
+
+// LayerTiming is the time spent in a layer of the chain, see Timing.Layers.
+type LayerTiming struct {
+	Layer    Layer
+	Duration time.Duration
+}
+
+// MiddlewareTiming splits the time spent in a middleware around the next
+// layer, see ProfileMiddlewares.
+type MiddlewareTiming struct {
+	Name   string
+	Before time.Duration // from entering the middleware to calling next, or to returning if it did not
+	After  time.Duration // from next returning to the middleware returning
+}
+
+// layerTrackerKey is the context key of the layerTracker of a request.
+type layerTrackerKey struct{}
+
+// layerTracker records which layer of the chain first wrote the response of
+// a request, whether the handler was reached and when each layer was entered
+// and left.
+type layerTracker struct {
+	mu        sync.Mutex
+	writtenBy *Layer
+	reached   bool
+	active    []Layer      // the layers entered and not left, the innermost last
+	entries   []layerEntry // by order of entry
+	panicked  *Layer       // the innermost layer a panic unwound, until a layer returns
+	trace     []string     // the entries and exits, see Result.ExecutionTrace
+}
+
+// layerEntry is a pass of the request through a layer. end is zero when the
+// layer did not return.
+type layerEntry struct {
+	layer      Layer
+	start, end time.Time
+}
+
+// attach returns req carrying t.
+func (t *layerTracker) attach(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), layerTrackerKey{}, t))
+}
+
+func (t *layerTracker) wrote(layer Layer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.writtenBy == nil {
+		t.writtenBy = &layer
+	}
+}
+
+// enter records that the request entered layer, returning the index of the
+// entry.
+func (t *layerTracker) enter(layer Layer) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if layer.Handler {
+		t.reached = true
+	}
+	t.active = append(t.active, layer)
+	t.entries = append(t.entries, layerEntry{layer: layer, start: time.Now()})
+	if layer.Handler {
+		t.trace = append(t.trace, layer.String())
+	} else {
+		t.trace = append(t.trace, layer.String()+":enter")
+	}
+	return len(t.entries) - 1
+}
+
+// leave records that the layer of entry i returned, or that a panic unwound
+// it. The end of a layer that panicked stays zero. The first layer a panic
+// unwinds is the one that panicked; a layer returning means the panic, if
+// any, was recovered inside it. A middleware recovering a panic and panicking
+// again before returning, as to re-raise it, leaves the blame on the first
+// layer.
+func (t *layerTracker) leave(i int, returned bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	layer := t.active[len(t.active)-1]
+	t.active = t.active[:len(t.active)-1]
+	switch {
+	case !returned:
+		t.trace = append(t.trace, layer.String()+":panic")
+	case !layer.Handler:
+		t.trace = append(t.trace, layer.String()+":exit")
+	}
+	switch {
+	case returned:
+		t.entries[i].end = time.Now()
+		t.panicked = nil
+	case t.panicked == nil:
+		t.panicked = &layer
+	}
+}
+
+// origin returns the layer that panicked, once the panic unwound the chain.
+func (t *layerTracker) origin() *Layer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.panicked
+}
+
+// record sets the layer fields of r, its MiddlewareTimings if profile is set
+// and its ExecutionTrace if trace is.
+func (t *layerTracker) record(r *Result, profile, trace bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r.WrittenBy, r.HandlerReached = t.writtenBy, t.reached
+	if trace {
+		r.ExecutionTrace = slices.Clone(t.trace)
+	}
+	r.Timing.Layers = make([]LayerTiming, len(t.entries))
+	for i, e := range t.entries {
+		r.Timing.Layers[i] = LayerTiming{Layer: e.layer}
+		if !e.end.IsZero() {
+			r.Timing.Layers[i].Duration = e.end.Sub(e.start)
+		}
+	}
+	if !profile {
+		return
+	}
+	for i, e := range t.entries {
+		if !e.layer.Handler {
+			r.MiddlewareTimings = append(r.MiddlewareTimings, t.profile(i))
+		}
+	}
+}
+
+// profile splits the time spent in the middleware of entry i around its calls
+// to the next layer, whose entries follow it.
+func (t *layerTracker) profile(i int) MiddlewareTiming {
+	e := t.entries[i]
+	timing := MiddlewareTiming{Name: e.layer.String()}
+	// The next layer is the first entered after this one, skipped
+	// middlewares leaving gaps in the indexes.
+	var first, last *layerEntry
+	next := -1
+	if i+1 < len(t.entries) {
+		next = t.entries[i+1].layer.Index
+	}
+	for j := i + 1; j < len(t.entries) && t.entries[j].layer.Index > e.layer.Index; j++ {
+		if t.entries[j].layer.Index == next {
+			if first == nil {
+				first = &t.entries[j]
+			}
+			last = &t.entries[j]
+		}
+	}
+	switch {
+	case first == nil && !e.end.IsZero():
+		// The middleware did not call next.
+		timing.Before = e.end.Sub(e.start)
+	case first != nil:
+		timing.Before = first.start.Sub(e.start)
+		if !e.end.IsZero() && !last.end.IsZero() {
+			timing.After = e.end.Sub(last.end)
+		}
+	}
+	return timing
+}
+
+// trackLayer serves h with a writer telling the layerTracker of the request,
+// if any, when layer writes the response.
+func trackLayer(h http.Handler, layer Layer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, ok := r.Context().Value(layerTrackerKey{}).(*layerTracker)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		i := t.enter(layer)
+		returned := false
+		// Deferred without recovering, so the panic unwinds the outer
+		// layers and runs their deferred calls.
+		defer func() { t.leave(i, returned) }()
+		h.ServeHTTP(exposeInterfaces(&layerWriter{ResponseWriter: w, tracker: t, layer: layer}), r)
+		returned = true
+	})
+}
+
+// layerWriter is the writer a layer of the chain is served with, offering the
+// optional interfaces of the writer it wraps. Writes of inner layers go
+// through it as well, after their own layerWriter recorded them.
+type layerWriter struct {
+	http.ResponseWriter
+	tracker *layerTracker
+	layer   Layer
+}
+
+func (l *layerWriter) WriteHeader(code int) {
+	l.tracker.wrote(l.layer)
+	l.ResponseWriter.WriteHeader(code)
+}
+
+func (l *layerWriter) Write(p []byte) (int, error) {
+	l.tracker.wrote(l.layer)
+	return l.ResponseWriter.Write(p)
+}
+
+func (l *layerWriter) Flush() {
+	_ = l.FlushError()
+}
+
+func (l *layerWriter) FlushError() error {
+	l.tracker.wrote(l.layer)
+	return http.NewResponseController(l.ResponseWriter).Flush()
+}
+
+func (l *layerWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	l.tracker.wrote(l.layer)
+	return hijack(l.ResponseWriter)
+}
+
+func (l *layerWriter) Push(target string, opts *http.PushOptions) error {
+	return push(l.ResponseWriter, target, opts)
+}
+
+func (l *layerWriter) ReadFrom(r io.Reader) (int64, error) {
+	l.tracker.wrote(l.layer)
+	return readFrom(l.ResponseWriter, r)
+}
+
+// Unwrap lets http.ResponseController reach the recorder.
+func (l *layerWriter) Unwrap() http.ResponseWriter {
+	return l.ResponseWriter
+}
@@ -0,0 +1,388 @@
+package checkpoint
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// passThrough is a middleware calling next without writing.
+func passThrough(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Seen", "yes")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireToken rejects requests without an Authorization header.
+func requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func Test_ResultWrittenBy(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Get(http.NewServeMux(), "/layers").
+		WithMiddlewares(passThrough, passThrough, requireToken, passThrough)
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	// The third middleware rejects the request before calling next.
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+	assert.Equal(t, &Layer{Index: 2, Name: "middleware[2]"}, result.WrittenBy)
+	assert.Equal(t, "middleware[2]", result.WrittenBy.String())
+	assert.False(t, result.HandlerReached)
+
+	// A clean chain passes the request through to the handler.
+	result, err = conf.WithBearerToken("token").Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+	assert.Equal(t, &Layer{Index: 4, Name: "handler", Handler: true}, result.WrittenBy)
+	assert.Equal(t, "handler", result.WrittenBy.String())
+	assert.True(t, result.HandlerReached)
+}
+
+func Test_ResultWrittenByCases(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		middlewares []func(http.Handler) http.Handler
+		handler     func(w http.ResponseWriter, r *http.Request)
+		writtenBy   *Layer
+		reached     bool
+	}{
+		{
+			// Nothing writes: the implicit 200 has no author.
+			handler: func(w http.ResponseWriter, r *http.Request) {},
+			reached: true,
+		},
+		{
+			// A middleware writing after next returned comes second.
+			middlewares: []func(http.Handler) http.Handler{func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					next.ServeHTTP(w, r)
+					_, _ = w.Write([]byte(" and more"))
+				})
+			}},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("body"))
+			},
+			writtenBy: &Layer{Index: 1, Name: "handler", Handler: true},
+			reached:   true,
+		},
+		{
+			// Writes through a writer wrapped by a middleware are the handler's.
+			middlewares: []func(http.Handler) http.Handler{func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					next.ServeHTTP(&statusWriter{ResponseWriter: w}, r)
+				})
+			}},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_ = http.NewResponseController(w).Flush()
+			},
+			writtenBy: &Layer{Index: 1, Name: "handler", Handler: true},
+			reached:   true,
+		},
+	}
+
+	for i, tt := range tests {
+		conf := Get(http.NewServeMux(), "/layers").WithMiddlewares(tt.middlewares...)
+		conf.RouteFunc = tt.handler
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, tt.writtenBy, result.WrittenBy, "failure in the test case: %d", i)
+		assert.Equal(t, tt.reached, result.HandlerReached, "failure in the test case: %d", i)
+	}
+
+	// A handler panicking after a write is reported on the partial result.
+	conf := Get(http.NewServeMux(), "/layers").WithMiddlewares(passThrough)
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("boom")
+	}
+	result, err := conf.Run(ctx)
+	assert.Error(t, err)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, &Layer{Index: 1, Name: "handler", Handler: true}, result.WrittenBy)
+		assert.True(t, result.HandlerReached)
+	}
+}
+
+// statusWriter is a middleware's own ResponseWriter wrapper.
+type statusWriter struct {
+	http.ResponseWriter
+}
+
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func Test_NamedMiddlewares(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Get(http.NewServeMux(), "/layers").
+		WithMiddlewares(passThrough).
+		WithNamedMiddleware("auth", requireToken).
+		WithMiddlewares(passThrough)
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, &Layer{Index: 1, Name: "auth"}, result.WrittenBy)
+	assert.False(t, result.HandlerReached)
+
+	// The name shows in the expectation report, the timings and the budget.
+	fake := &fakeT{}
+	result.Expect(fake).Status(http.StatusOK).Done()
+	if assert.Len(t, fake.errors, 1) {
+		assert.Contains(t, fake.errors[0], "\nThe response was written by auth, the handler was not reached.\n")
+	}
+	var names []string
+	for _, l := range result.Timing.Layers {
+		names = append(names, l.Layer.Name)
+	}
+	assert.Equal(t, []string{"middleware[0]", "auth"}, names)
+	assert.ErrorContains(t, result.Within(0), "; middleware[0] ")
+	assert.ErrorContains(t, result.Within(0), ", auth ")
+
+	// A clean pass-through goes through every layer.
+	result, err = conf.Clone().WithBearerToken("token").Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	names = nil
+	for _, l := range result.Timing.Layers {
+		names = append(names, l.Layer.String())
+	}
+	assert.Equal(t, []string{"middleware[0]", "auth", "middleware[2]", "handler"}, names)
+	assert.True(t, result.HandlerReached)
+
+	// A panic names the layer that panicked.
+	conf.WithNamedMiddleware("recorder", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("recorder broke")
+		})
+	}).WithBearerToken("token")
+	_, err = conf.Run(ctx)
+	var panicErr *PanicError
+	if assert.ErrorAs(t, err, &panicErr) {
+		assert.Equal(t, &Layer{Index: 3, Name: "recorder"}, panicErr.Origin)
+		assert.ErrorContains(t, err, "middleware[3] (recorder) panicked: recorder broke\n\n")
+	}
+}
+
+// sleepAround is a middleware sleeping before and after calling next.
+func sleepAround(before, after time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(before)
+			next.ServeHTTP(w, r)
+			time.Sleep(after)
+		})
+	}
+}
+
+func Test_ProfileMiddlewares(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Get(http.NewServeMux(), "/profile").
+		WithNamedMiddleware("slow-in", sleepAround(30*time.Millisecond, 5*time.Millisecond)).
+		WithNamedMiddleware("slow-out", sleepAround(5*time.Millisecond, 30*time.Millisecond))
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Profiling is opt-in.
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Nil(t, result.MiddlewareTimings)
+
+	conf.ProfileMiddlewares = true
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	timings := result.MiddlewareTimings
+	if !assert.Len(t, timings, 2) {
+		return
+	}
+	assert.Equal(t, "slow-in", timings[0].Name)
+	assert.Equal(t, "slow-out", timings[1].Name)
+	assert.GreaterOrEqual(t, timings[0].Before, 30*time.Millisecond)
+	assert.GreaterOrEqual(t, timings[0].After, 5*time.Millisecond)
+	assert.GreaterOrEqual(t, timings[1].Before, 5*time.Millisecond)
+	assert.GreaterOrEqual(t, timings[1].After, 30*time.Millisecond)
+	// The inner layers are not counted in the outer one.
+	layers := result.Timing.Layers
+	if assert.Len(t, layers, 3) {
+		assert.Equal(t, layers[0].Duration, timings[0].Before+layers[1].Duration+timings[0].After)
+		assert.Equal(t, layers[1].Duration, timings[1].Before+layers[2].Duration+timings[1].After)
+	}
+
+	// A middleware that does not call next spends all its time before.
+	conf = Get(http.NewServeMux(), "/profile").
+		WithMiddlewares(sleepAround(0, 0)).
+		WithNamedMiddleware("auth", requireToken)
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+	conf.ProfileMiddlewares = true
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if assert.Len(t, result.MiddlewareTimings, 2) {
+		assert.Equal(t, "middleware[0]", result.MiddlewareTimings[0].Name)
+		assert.Equal(t, "auth", result.MiddlewareTimings[1].Name)
+		assert.Zero(t, result.MiddlewareTimings[1].After)
+	}
+}
+
+func Test_SkipMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	base := Get(http.NewServeMux(), "/private").
+		WithMiddlewares(passThrough).
+		WithNamedMiddleware("auth", requireToken).
+		WithNamedMiddleware("slow", sleepAround(0, 0))
+	base.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+
+	result, err := base.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+
+	// Skipping auth on a clone lets the request through, and leaves the
+	// base config alone.
+	result, err = base.Clone().SkipMiddleware("auth").Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "yes", result.Headers["X-Seen"])
+	var names []string
+	for _, l := range result.Timing.Layers {
+		names = append(names, l.Layer.String())
+	}
+	assert.Equal(t, []string{"middleware[0]", "slow", "handler"}, names)
+	assert.True(t, result.HandlerReached)
+
+	result, err = base.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+
+	// Only the named middlewares are applied, in their order in the chain.
+	conf := base.Clone().OnlyMiddlewares("slow", "middleware[0]")
+	conf.ProfileMiddlewares = true
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	names = nil
+	for _, m := range result.MiddlewareTimings {
+		names = append(names, m.Name)
+	}
+	assert.Equal(t, []string{"middleware[0]", "slow"}, names)
+
+	result, err = base.Clone().OnlyMiddlewares().Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Empty(t, result.Headers["X-Seen"])
+
+	// A typo fails the run.
+	_, err = base.Clone().SkipMiddleware("Auth").Run(ctx)
+	assert.EqualError(t, err, `unknown middleware "Auth", the middlewares are: middleware[0], auth, slow`)
+	_, err = base.Clone().OnlyMiddlewares("auth", "middleware[3]").Run(ctx)
+	assert.EqualError(t, err, `unknown middleware "middleware[3]", the middlewares are: middleware[0], auth, slow`)
+}
+
+func Test_TraceMiddlewares(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Get(http.NewServeMux(), "/trace").
+		WithNamedMiddleware("logging", passThrough).
+		WithNamedMiddleware("auth", requireToken).
+		WithNamedMiddleware("ratelimit", passThrough)
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+
+	// Tracing is opt-in.
+	result, err := conf.Clone().WithBearerToken("token").Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Nil(t, result.ExecutionTrace)
+	fake := &fakeT{}
+	result.Expect(fake).ExecutionOrder("handler").Done()
+	if assert.Len(t, fake.errors, 1) {
+		assert.Contains(t, fake.errors[0], "1. expected execution order handler, no trace was recorded: set TraceMiddlewares\n")
+	}
+
+	conf.TraceMiddlewares = true
+	result, err = conf.Clone().WithBearerToken("token").Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, []string{
+		"logging:enter", "auth:enter", "ratelimit:enter", "handler",
+		"ratelimit:exit", "auth:exit", "logging:exit",
+	}, result.ExecutionTrace)
+	fake = &fakeT{}
+	result.Expect(fake).ExecutionOrder(
+		"logging:enter", "auth:enter", "ratelimit:enter", "handler",
+		"ratelimit:exit", "auth:exit", "logging:exit",
+	).Done()
+	assert.Empty(t, fake.errors)
+
+	// A short-circuit leaves the inner layers out.
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, []string{"logging:enter", "auth:enter", "auth:exit", "logging:exit"}, result.ExecutionTrace)
+	fake = &fakeT{}
+	result.Expect(fake).ExecutionOrder(
+		"logging:enter", "auth:enter", "ratelimit:enter", "handler",
+		"ratelimit:exit", "auth:exit", "logging:exit",
+	).Done()
+	if assert.Len(t, fake.errors, 1) {
+		assert.Contains(t, fake.errors[0], "1. expected execution order logging:enter -> auth:enter -> ratelimit:enter -> "+
+			"handler -> ratelimit:exit -> auth:exit -> logging:exit, got logging:enter -> auth:enter -> auth:exit -> logging:exit\n")
+	}
+
+	// A panic unwinds the layers without exiting them.
+	conf.WithNamedMiddleware("broken", panickingMiddleware).WithBearerToken("token")
+	result, err = conf.Run(ctx)
+	assert.Error(t, err)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, []string{
+			"logging:enter", "auth:enter", "ratelimit:enter", "broken:enter",
+			"broken:panic", "ratelimit:panic", "auth:panic", "logging:panic",
+		}, result.ExecutionTrace)
+	}
+}
@@ -0,0 +1,133 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LoadOptions sets the load RunLoad puts on a handler. Either Requests or
+// Duration must be set; when both are, the load stops at whichever comes
+// first.
+type LoadOptions struct {
+	// Concurrency is the number of requests in flight at once, 1 by default.
+	Concurrency int
+	// Requests is the number of requests to send.
+	Requests int
+	// Duration is how long to keep sending requests.
+	Duration time.Duration
+}
+
+// LoadReport sums up a RunLoad.
+type LoadReport struct {
+	Requests int
+	// Errors counts the requests that could not be served, as when the
+	// handler panicked or timed out. Their latency is not counted.
+	Errors int
+	// Statuses counts the requests served by status code.
+	Statuses map[int]int
+	P50      time.Duration
+	P90      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+	// Elapsed is the time the whole load took.
+	Elapsed time.Duration
+}
+
+// RunLoad sends the request of tc to its handler from opts.Concurrency
+// goroutines until opts.Requests were sent or opts.Duration passed, and
+// reports the statuses and the latency percentiles of the requests served.
+// As with RunBench the route is registered once and every request gets its
+// own copy of the body. When ctx ends, RunLoad stops early and returns the
+// report of the requests sent so far along with the error of ctx.
+func (tc *TestConfig) RunLoad(ctx context.Context, opts LoadOptions) (*LoadReport, error) {
+	if opts.Requests <= 0 && opts.Duration <= 0 {
+		return nil, errors.New("RunLoad needs a number of requests or a duration")
+	}
+	concurrency := max(opts.Concurrency, 1)
+	serve, newRequest, _, err := tc.prepareRequests()
+	if err != nil {
+		return nil, err
+	}
+
+	var deadline time.Time
+	if opts.Duration > 0 {
+		deadline = time.Now().Add(opts.Duration)
+	}
+	var sent atomic.Int64
+	next := func() bool {
+		if ctx.Err() != nil || (!deadline.IsZero() && !time.Now().Before(deadline)) {
+			return false
+		}
+		return opts.Requests <= 0 || sent.Add(1) <= int64(opts.Requests)
+	}
+
+	var mu sync.Mutex
+	report := &LoadReport{Statuses: make(map[int]int)}
+	var latencies []time.Duration
+	record := func(code int, latency time.Duration, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		report.Requests++
+		if err != nil {
+			report.Errors++
+			return
+		}
+		report.Statuses[code]++
+		latencies = append(latencies, latency)
+	}
+	serveOne := func() {
+		reqCtx := ctx
+		if tc.Timeout > 0 {
+			var cancel context.CancelFunc
+			reqCtx, cancel = context.WithTimeout(ctx, tc.Timeout)
+			defer cancel()
+		}
+		req, err := newRequest(reqCtx)
+		if err != nil {
+			record(0, 0, err)
+			return
+		}
+		rr := httptest.NewRecorder()
+		start := time.Now()
+		_, err = tc.serveHTTP(reqCtx, serve, rr, rr, req)
+		record(rr.Code, time.Since(start), err)
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for next() {
+				serveOne()
+			}
+		}()
+	}
+	wg.Wait()
+	report.Elapsed = time.Since(start)
+
+	slices.Sort(latencies)
+	report.P50 = percentile(latencies, 50)
+	report.P90 = percentile(latencies, 90)
+	report.P99 = percentile(latencies, 99)
+	if len(latencies) > 0 {
+		report.Max = latencies[len(latencies)-1]
+	}
+	return report, ctx.Err()
+}
+
+// percentile returns the p-th percentile of the sorted latencies by the
+// nearest-rank method, zero when there are none.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,98 @@
+package checkpoint
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RunLoad(t *testing.T) {
+	ctx := context.Background()
+
+	var calls, bodies atomic.Int32
+	conf := Post(http.NewServeMux(), "/load", "payload")
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		if b, _ := io.ReadAll(r.Body); string(b) == "payload" {
+			bodies.Add(1)
+		}
+		time.Sleep(time.Millisecond)
+		if calls.Add(1)%10 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}
+
+	report, err := conf.RunLoad(ctx, LoadOptions{Concurrency: 8, Requests: 200})
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, 200, report.Requests)
+	assert.Equal(t, int32(200), bodies.Load())
+	assert.Zero(t, report.Errors)
+	assert.Equal(t, map[int]int{http.StatusOK: 180, http.StatusServiceUnavailable: 20}, report.Statuses)
+	assert.GreaterOrEqual(t, report.P50, time.Millisecond)
+	assert.LessOrEqual(t, report.P50, report.P90)
+	assert.LessOrEqual(t, report.P90, report.P99)
+	assert.LessOrEqual(t, report.P99, report.Max)
+	// 200 requests of 1ms each, 8 at a time.
+	assert.Less(t, report.Elapsed, 200*time.Millisecond)
+
+	report, err = conf.RunLoad(ctx, LoadOptions{Concurrency: 2, Duration: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Positive(t, report.Requests)
+	assert.GreaterOrEqual(t, report.Elapsed, 20*time.Millisecond)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	report, err = conf.RunLoad(cancelled, LoadOptions{Requests: 10})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, report.Requests)
+
+	_, err = conf.RunLoad(ctx, LoadOptions{Concurrency: 4})
+	assert.EqualError(t, err, "RunLoad needs a number of requests or a duration")
+}
+
+func Test_RunLoadErrors(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Get(http.NewServeMux(), "/load/panic")
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}
+	report, err := conf.RunLoad(ctx, LoadOptions{Concurrency: 4, Requests: 20})
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, 20, report.Requests)
+	assert.Equal(t, 20, report.Errors)
+	assert.Empty(t, report.Statuses)
+	assert.Zero(t, report.P99)
+}
+
+func Test_percentile(t *testing.T) {
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	tt := []struct {
+		sorted   []time.Duration
+		p        int
+		expected time.Duration
+	}{
+		{sorted: latencies, p: 50, expected: 50 * time.Millisecond},
+		{sorted: latencies, p: 99, expected: 99 * time.Millisecond},
+		{sorted: latencies[:10], p: 90, expected: 9 * time.Millisecond},
+		{sorted: latencies[:1], p: 50, expected: time.Millisecond},
+		{sorted: nil, p: 50, expected: 0},
+	}
+	for i, tc := range tt {
+		assert.Equal(t, tc.expected, percentile(tc.sorted, tc.p), "failure in the test case: %d", i)
+	}
+}
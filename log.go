@@ -0,0 +1,168 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// logBodyLimit is the number of body bytes logged at Debug level.
+const logBodyLimit = 1 << 10
+
+// redactedHeaders are logged as [REDACTED] unless allowed with LogUnredacted.
+var redactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+
+// LogOption tunes the logging set up by WithLogger and SetDefaultLogger.
+type LogOption func(*runLogger)
+
+// LogUnredacted logs the values of the given sensitive headers, such as
+// Authorization, instead of redacting them.
+func LogUnredacted(keys ...string) LogOption {
+	return func(l *runLogger) {
+		for _, key := range keys {
+			l.unredacted = append(l.unredacted, http.CanonicalHeaderKey(key))
+		}
+	}
+}
+
+type runLogger struct {
+	logger     *slog.Logger
+	unredacted []string
+}
+
+var defaultLogger = struct {
+	sync.RWMutex
+	*runLogger
+}{}
+
+// SetDefaultLogger logs the runs of the configs without a logger of their own
+// to logger, see WithLogger. A nil logger turns it off.
+func SetDefaultLogger(logger *slog.Logger, opts ...LogOption) {
+	defaultLogger.Lock()
+	defer defaultLogger.Unlock()
+	defaultLogger.runLogger = newRunLogger(logger, opts)
+}
+
+// WithLogger logs an entry to logger for every Run and RunServer, with the
+// method, path, matched pattern, status, duration and sizes of the exchange,
+// and the error of a failed run. The size of the request body is left out
+// when it is empty or unknown, as for a streamed body. When logger is enabled
+// for Debug the entry also holds the headers and the start of the bodies. The
+// Authorization, Proxy-Authorization, Cookie and Set-Cookie headers are
+// redacted unless allowed with LogUnredacted.
+func (tc *TestConfig) WithLogger(logger *slog.Logger, opts ...LogOption) *TestConfig {
+	tc.logger = newRunLogger(logger, opts)
+	return tc
+}
+
+func newRunLogger(logger *slog.Logger, opts []LogOption) *runLogger {
+	if logger == nil {
+		return nil
+	}
+	l := &runLogger{logger: logger}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// logRun logs the run that served req to the logger of tc, or the default one.
+func (tc *TestConfig) logRun(req *http.Request, result *Result, err error) {
+	l := tc.logger
+	if l == nil {
+		defaultLogger.RLock()
+		l = defaultLogger.runLogger
+		defaultLogger.RUnlock()
+	}
+	if l == nil {
+		return
+	}
+
+	ctx := context.Background()
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelError
+	}
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.String("pattern", result.Pattern),
+		slog.Int("status", result.StatusCode),
+		slog.Duration("duration", result.Duration),
+	}
+	// The size of a streamed request body is not known.
+	if req.ContentLength > 0 {
+		attrs = append(attrs, slog.Int64("request_bytes", req.ContentLength))
+	}
+	attrs = append(attrs, slog.Int64("response_bytes", result.BytesWritten))
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	if l.logger.Enabled(ctx, slog.LevelDebug) {
+		attrs = append(attrs,
+			slog.Group("request",
+				slog.Any("headers", l.headers(req.Header)),
+				slog.String("body", logBody(loggedRequestBody(req))),
+			),
+			slog.Group("response",
+				slog.Any("headers", l.headers(result.RawHeaders)),
+				slog.String("body", logBody(result.Body)),
+			),
+		)
+	}
+	l.logger.LogAttrs(ctx, level, "checkpoint run", attrs...)
+}
+
+// headers returns header as a log value, with the sensitive headers redacted.
+func (l *runLogger) headers(header http.Header) slog.Value {
+	var attrs []slog.Attr
+	for _, key := range slices.Sorted(maps.Keys(header)) {
+		value := strings.Join(header[key], ", ")
+		if slices.Contains(redactedHeaders, key) && !slices.Contains(l.unredacted, key) {
+			value = "[REDACTED]"
+		}
+		attrs = append(attrs, slog.String(key, value))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// loggedRequestBody returns the start of the body of req when it can be read
+// again.
+func loggedRequestBody(req *http.Request) []byte {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer body.Close()
+	b, _ := io.ReadAll(io.LimitReader(body, logBodyLimit+1))
+	return b
+}
+
+// logBody renders the start of a body for the logs.
+func logBody(b []byte) string {
+	shown := b
+	if len(b) > logBodyLimit {
+		n := logBodyLimit
+		for n > 0 && !utf8.RuneStart(b[n]) {
+			n--
+		}
+		shown = b[:n]
+	}
+	if !utf8.Valid(shown) {
+		return fmt.Sprintf("[%d bytes of binary data]", len(b))
+	}
+	if len(shown) < len(b) {
+		return string(shown) + "[truncated]"
+	}
+	return string(b)
+}
@@ -0,0 +1,130 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// logEntries decodes the JSON lines written by a slog.JSONHandler.
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("decoding the log entry %q: %v", line, err)
+		}
+		delete(entry, "time")
+		delete(entry, "duration")
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func Test_WithLogger(t *testing.T) {
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	conf := Init(http.NewServeMux()).
+		WithPath("/log/items/1").
+		WithJSONBody(map[string]string{"name": "widget"}).
+		WithHeaders(Header("Authorization", "Bearer secret"), Header("X-Trace", "abc")).
+		WithLogger(logger)
+	conf.URLPattern = "POST /log/items/{id}"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(strings.Repeat("a", 2000)))
+	}
+
+	_, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	entries := logEntries(t, &buf)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, map[string]any{
+		"level":          "INFO",
+		"msg":            "checkpoint run",
+		"method":         "POST",
+		"path":           "/log/items/1",
+		"pattern":        "/log/items/{id}",
+		"status":         float64(201),
+		"request_bytes":  float64(17),
+		"response_bytes": float64(2000),
+		"request": map[string]any{
+			"headers": map[string]any{
+				"Authorization": "[REDACTED]",
+				"Content-Type":  "application/json",
+				"X-Trace":       "abc",
+			},
+			"body": `{"name":"widget"}`,
+		},
+		"response": map[string]any{
+			"headers": map[string]any{
+				"Set-Cookie": "[REDACTED]",
+			},
+			"body": strings.Repeat("a", logBodyLimit) + "[truncated]",
+		},
+	}, entries[0])
+
+	buf.Reset()
+	_, err = conf.WithLogger(logger, LogUnredacted("authorization")).Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	entries = logEntries(t, &buf)
+	assert.Equal(t, "Bearer secret", entries[0]["request"].(map[string]any)["headers"].(map[string]any)["Authorization"])
+}
+
+func Test_SetDefaultLogger(t *testing.T) {
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	SetDefaultLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { SetDefaultLogger(nil) })
+
+	conf := Get(http.NewServeMux(), "/log/broken").
+		OnAfterRun(func(*http.Request, *Result) error { return http.ErrAbortHandler })
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}
+	_, err := conf.Run(ctx)
+	assert.Error(t, err)
+
+	// Bodies and headers are only logged at Debug level.
+	entries := logEntries(t, &buf)
+	assert.Equal(t, []map[string]any{{
+		"level":          "ERROR",
+		"msg":            "checkpoint run",
+		"method":         "GET",
+		"path":           "/log/broken",
+		"pattern":        "/log/broken",
+		"status":         float64(500),
+		"response_bytes": float64(7),
+		"error":          "after-run hook 1: net/http: abort Handler",
+	}}, entries)
+}
+
+func Test_logBody(t *testing.T) {
+	tt := []struct {
+		body     []byte
+		expected string
+	}{
+		{body: nil, expected: ""},
+		{body: []byte("short"), expected: "short"},
+		{body: []byte{0xff, 0xfe, 0x00}, expected: "[3 bytes of binary data]"},
+		{body: append(bytes.Repeat([]byte("a"), logBodyLimit-1), "é"...), expected: strings.Repeat("a", logBodyLimit-1) + "[truncated]"},
+	}
+	for i, tc := range tt {
+		assert.Equal(t, tc.expected, logBody(tc.body), "failure in the test case: %d", i)
+	}
+}
@@ -0,0 +1,222 @@
+package checkpoint
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Metrics are the metric families scraped by ScrapeMetrics, by name.
+type Metrics map[string]*MetricFamily
+
+// MetricFamily is a metric family of the Prometheus text format. The samples
+// of a histogram or a summary are named after the family with their _bucket,
+// _sum and _count suffixes.
+type MetricFamily struct {
+	Name    string
+	Help    string
+	Type    string // counter, gauge, histogram, summary or untyped
+	Samples []Sample
+}
+
+// Sample is a sample of a metric family.
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// ScrapeMetrics runs conf, a request to a Prometheus metrics handler such as
+// promhttp.Handler, and parses the text exposition format it answers with.
+// It fails unless the handler answers 200.
+func ScrapeMetrics(ctx context.Context, conf *TestConfig) (Metrics, error) {
+	// Ask for the text format unless the config asks for another one.
+	conf = conf.Clone().WithRequestModifier(func(r *http.Request) {
+		if r.Header.Get("Accept") == "" {
+			r.Header.Set("Accept", "text/plain; version=0.0.4")
+		}
+	})
+	result, err := conf.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if result.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scraping the metrics: status %d", result.StatusCode)
+	}
+	return ParseMetrics(result.Body)
+}
+
+// ParseMetrics parses metrics in the Prometheus text exposition format.
+// Timestamps are ignored.
+func ParseMetrics(b []byte) (Metrics, error) {
+	metrics := make(Metrics)
+	family := func(name string) *MetricFamily {
+		f, ok := metrics[name]
+		if !ok {
+			f = &MetricFamily{Name: name, Type: "untyped"}
+			metrics[name] = f
+		}
+		return f
+	}
+
+	var current *MetricFamily
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if comment, ok := strings.CutPrefix(line, "#"); ok {
+			fields := strings.Fields(comment)
+			if len(fields) < 2 || (fields[0] != "HELP" && fields[0] != "TYPE") {
+				continue
+			}
+			current = family(fields[1])
+			if fields[0] == "TYPE" && len(fields) > 2 {
+				current.Type = fields[2]
+			} else if fields[0] == "HELP" {
+				_, help, _ := strings.Cut(strings.TrimSpace(comment)[len("HELP "):], " ")
+				current.Help = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(strings.TrimSpace(help))
+			}
+			continue
+		}
+
+		sample, err := parseSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if current == nil || !current.holds(sample.Name) {
+			current = family(sample.Name)
+		}
+		current.Samples = append(current.Samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+// holds reports whether a sample named name belongs to f.
+func (f *MetricFamily) holds(name string) bool {
+	if name == f.Name {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(name, f.Name+"_")
+	if !ok {
+		return false
+	}
+	switch f.Type {
+	case "histogram":
+		return suffix == "bucket" || suffix == "sum" || suffix == "count" || suffix == "created"
+	case "summary":
+		return suffix == "sum" || suffix == "count" || suffix == "created"
+	case "counter":
+		return suffix == "total" || suffix == "created"
+	}
+	return false
+}
+
+// parseSample parses a sample line: a name, optional labels in braces and a
+// value, optionally followed by a timestamp.
+func parseSample(line string) (Sample, error) {
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return Sample{}, fmt.Errorf("invalid sample %q", line)
+	}
+	sample := Sample{Name: line[:end], Labels: map[string]string{}}
+	rest := line[end:]
+	if strings.HasPrefix(rest, "{") {
+		var err error
+		if rest, err = parseLabels(rest[1:], sample.Labels); err != nil {
+			return Sample{}, fmt.Errorf("sample %s: %w", sample.Name, err)
+		}
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return Sample{}, fmt.Errorf("sample %s: invalid value %q", sample.Name, strings.TrimSpace(rest))
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Sample{}, fmt.Errorf("sample %s: invalid value %q", sample.Name, fields[0])
+	}
+	sample.Value = value
+	return sample, nil
+}
+
+// parseLabels parses the labels of s, following the opening brace, into
+// labels and returns what follows the closing brace.
+func parseLabels(s string, labels map[string]string) (string, error) {
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if rest, ok := strings.CutPrefix(s, "}"); ok {
+			return rest, nil
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || len(s) < eq+2 || s[eq+1] != '"' {
+			return "", errors.New("invalid labels")
+		}
+		name := strings.TrimSpace(s[:eq])
+		var value strings.Builder
+		i := eq + 2
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			value.WriteByte(s[i])
+		}
+		if i == len(s) {
+			return "", fmt.Errorf("unterminated value of label %s", name)
+		}
+		labels[name] = value.String()
+		s = s[i+1:]
+	}
+}
+
+// Value returns the sum of the samples named name carrying labels, whatever
+// their other labels, so Value("http_requests_total", nil) counts the
+// requests of every route. Samples of histograms and summaries are named with
+// their suffix, as "latency_seconds_count".
+func (m Metrics) Value(name string, labels map[string]string) float64 {
+	var sum float64
+	for _, f := range m {
+		for _, s := range f.Samples {
+			if s.Name == name && hasLabels(s.Labels, labels) {
+				sum += s.Value
+			}
+		}
+	}
+	return sum
+}
+
+func hasLabels(got, want map[string]string) bool {
+	for key, value := range want {
+		if got[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// MetricDelta returns how much the samples named name carrying labels moved
+// from before to after, two scrapes of the same handler:
+//
+//	before, _ := checkpoint.ScrapeMetrics(ctx, metrics)
+//	conf.Run(ctx)
+//	after, _ := checkpoint.ScrapeMetrics(ctx, metrics)
+//	assert.Equal(t, 1.0, checkpoint.MetricDelta(before, after, "orders_created_total", nil))
+func MetricDelta(before, after Metrics, name string, labels map[string]string) float64 {
+	return after.Value(name, labels) - before.Value(name, labels)
+}
@@ -0,0 +1,113 @@
+package checkpoint
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ScrapeMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	registry := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_requests_total",
+		Help: "Requests served by the orders handler.",
+	}, []string{"code", "method"})
+	latency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "orders_request_duration_seconds",
+		Help:    "Latency of the orders handler.",
+		Buckets: []float64{0.1, 1},
+	}, []string{"method"})
+	registry.MustRegister(requests, latency)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	orders := promhttp.InstrumentHandlerCounter(requests,
+		promhttp.InstrumentHandlerDuration(latency, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})))
+	mux.Handle("POST /orders", orders)
+
+	scrape := Get(mux, "/metrics")
+	scrape.UseExistingRoutes = true
+	create := Init(mux).WithPath("/orders")
+	create.Method = http.MethodPost
+	create.UseExistingRoutes = true
+
+	if _, err := create.Run(ctx); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	before, err := ScrapeMetrics(ctx, scrape)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if _, err := create.Run(ctx); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	after, err := ScrapeMetrics(ctx, scrape)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	labels := map[string]string{"code": "201", "method": "post"}
+	assert.Equal(t, 1.0, MetricDelta(before, after, "orders_requests_total", labels))
+	assert.Equal(t, 2.0, after.Value("orders_requests_total", labels))
+	assert.Equal(t, 2.0, after.Value("orders_requests_total", nil))
+	assert.Equal(t, 0.0, after.Value("orders_requests_total", map[string]string{"code": "500"}))
+
+	family := after["orders_requests_total"]
+	if assert.NotNil(t, family) {
+		assert.Equal(t, "counter", family.Type)
+		assert.Equal(t, "Requests served by the orders handler.", family.Help)
+		assert.Equal(t, []Sample{{Name: "orders_requests_total", Labels: labels, Value: 2}}, family.Samples)
+	}
+
+	histogram := after["orders_request_duration_seconds"]
+	if assert.NotNil(t, histogram) {
+		assert.Equal(t, "histogram", histogram.Type)
+		assert.Len(t, histogram.Samples, 5) // three buckets, the sum and the count
+	}
+	assert.Equal(t, 1.0, MetricDelta(before, after, "orders_request_duration_seconds_count", map[string]string{"method": "post"}))
+	assert.Equal(t, 2.0, after.Value("orders_request_duration_seconds_bucket", map[string]string{"le": "+Inf"}))
+}
+
+func Test_ParseMetrics(t *testing.T) {
+	metrics, err := ParseMetrics([]byte(`# HELP rpc_duration_seconds A summary of "RPC" latency.\nin seconds
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 4773
+rpc_duration_seconds_sum 1.7560473e+07
+rpc_duration_seconds_count 2693 1395066363000
+msdos_file_access_time_seconds{path="C:\\DIR\\FILE.TXT",error="Cannot find file:\n\"FILE.TXT\""} 1.458255915e9
+temperature NaN
+headroom -Inf
+`))
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	summary := metrics["rpc_duration_seconds"]
+	if assert.NotNil(t, summary) {
+		assert.Equal(t, "summary", summary.Type)
+		assert.Equal(t, "A summary of \"RPC\" latency.\nin seconds", summary.Help)
+		assert.Len(t, summary.Samples, 3)
+	}
+	assert.Equal(t, 2693.0, metrics.Value("rpc_duration_seconds_count", nil))
+
+	untyped := metrics["msdos_file_access_time_seconds"]
+	if assert.NotNil(t, untyped) {
+		assert.Equal(t, "untyped", untyped.Type)
+		assert.Equal(t, map[string]string{"path": `C:\DIR\FILE.TXT`, "error": "Cannot find file:\n\"FILE.TXT\""}, untyped.Samples[0].Labels)
+	}
+	assert.True(t, math.IsNaN(metrics.Value("temperature", nil)))
+	assert.True(t, math.IsInf(metrics.Value("headroom", nil), -1))
+
+	for i, text := range []string{"requests_total", `requests_total{code="200} 1`, "requests_total one", `requests_total{code} 1`} {
+		_, err := ParseMetrics([]byte(text))
+		assert.Error(t, err, "failure in the test case: %d", i)
+	}
+}
@@ -0,0 +1,101 @@
+package checkpoint
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+)
+
+// Multipart builds a multipart/form-data request body. See WithMultipart.
+type Multipart struct {
+	boundary string
+	parts    []multipartPart
+}
+
+type multipartPart struct {
+	name     string
+	value    string
+	fileName string
+	content  io.Reader // nil for plain fields
+	data     []byte    // content read into memory by buffer, sent instead
+}
+
+// WithMultipart sends a multipart/form-data body built with the returned
+// Multipart, with the Content-Type header carrying its boundary. The body is
+// streamed to the handler while Run writes it, so large files are not held in
+// memory. File contents are read by Run, hence they are only sent once,
+// except by RunUntil and RunParallel, which read them into memory first.
+func (tc *TestConfig) WithMultipart() *Multipart {
+	m := &Multipart{boundary: multipart.NewWriter(io.Discard).Boundary()}
+	tc.setBody(&requestBody{
+		source:      "WithMultipart",
+		contentType: "multipart/form-data; boundary=" + m.boundary,
+		open:        m.open,
+		buffer:      m.buffer,
+	})
+	return m
+}
+
+// AddField adds a form field
+func (m *Multipart) AddField(name, value string) *Multipart {
+	m.parts = append(m.parts, multipartPart{name: name, value: value})
+	return m
+}
+
+// AddFile adds a file read from content. A field can hold several files.
+func (m *Multipart) AddFile(fieldName, fileName string, content io.Reader) *Multipart {
+	m.parts = append(m.parts, multipartPart{name: fieldName, fileName: fileName, content: content})
+	return m
+}
+
+// open returns a reader streaming the encoded parts.
+func (m *Multipart) open(*TestConfig) (io.Reader, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(m.write(pw))
+	}()
+	return pr, nil
+}
+
+// buffer reads the file contents into memory, so that the body can be sent
+// more than once.
+func (m *Multipart) buffer() error {
+	for i, p := range m.parts {
+		if p.content == nil || p.data != nil {
+			continue
+		}
+		data, err := io.ReadAll(p.content)
+		if err != nil {
+			return err
+		}
+		m.parts[i].data = data
+	}
+	return nil
+}
+
+func (m *Multipart) write(w io.Writer) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(m.boundary); err != nil {
+		return err
+	}
+	for _, p := range m.parts {
+		if p.content == nil {
+			if err := mw.WriteField(p.name, p.value); err != nil {
+				return err
+			}
+			continue
+		}
+		fw, err := mw.CreateFormFile(p.name, p.fileName)
+		if err != nil {
+			return err
+		}
+		content := p.content
+		if p.data != nil {
+			content = bytes.NewReader(p.data)
+		}
+		if _, err := io.Copy(fw, content); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
@@ -0,0 +1,82 @@
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// NDJSON decodes the response body as newline-delimited JSON (JSON Lines)
+// into v, a pointer to a slice, one element per line. Blank lines, including
+// the one following a trailing newline, are skipped. Errors name the line, as
+// "NDJSON line 12: ...".
+func (r *Result) NDJSON(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("NDJSON decodes into a pointer to a slice, not %T", v)
+	}
+	slice := reflect.MakeSlice(rv.Elem().Type(), 0, 0)
+	err := eachNDJSONLine(r.Body, func(line []byte) error {
+		elem := reflect.New(slice.Type().Elem())
+		if err := json.Unmarshal(line, elem.Interface()); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, elem.Elem())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	rv.Elem().Set(slice)
+	return nil
+}
+
+// DecodeNDJSON decodes the newline-delimited JSON body of r into a T per
+// line, as Result.NDJSON does.
+func DecodeNDJSON[T any](r *Result) ([]T, error) {
+	var records []T
+	err := EachNDJSON(r, func(record T) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// EachNDJSON decodes the newline-delimited JSON body of r a line at a time,
+// calling fn with each record, so a large stream is checked without holding
+// every record. It stops at the first line failing to decode, or the first
+// error of fn, and returns it with the line number.
+func EachNDJSON[T any](r *Result, fn func(T) error) error {
+	return eachNDJSONLine(r.Body, func(line []byte) error {
+		var record T
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		return fn(record)
+	})
+}
+
+// eachNDJSONLine calls fn with each line of body that is not blank, without
+// its line ending.
+func eachNDJSONLine(body []byte, fn func(line []byte) error) error {
+	for n := 1; len(body) > 0; n++ {
+		line := body
+		if i := bytes.IndexByte(body, '\n'); i >= 0 {
+			line, body = body[:i], body[i+1:]
+		} else {
+			body = nil
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return fmt.Errorf("NDJSON line %d: %w", n, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type logRecord struct {
+	Seq   int    `json:"seq"`
+	Level string `json:"level"`
+}
+
+// ndjsonConfig streams n records, the record numbered broken being malformed
+// when broken is positive.
+func ndjsonConfig(n, broken int) *TestConfig {
+	conf := Get(http.NewServeMux(), "/logs")
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for i := 1; i <= n; i++ {
+			if i == broken {
+				_, _ = fmt.Fprintf(w, "{\"seq\":%d,\"level\":\n", i)
+				continue
+			}
+			_, _ = fmt.Fprintf(w, "{\"seq\":%d,\"level\":\"info\"}\n", i)
+			if i%1000 == 0 && i < n {
+				// Blank lines and CRLF line endings are tolerated.
+				_, _ = fmt.Fprint(w, "\r\n\n")
+				i++
+				_, _ = fmt.Fprintf(w, "{\"seq\":%d,\"level\":\"info\"}\r\n", i)
+			}
+		}
+	}
+	return conf
+}
+
+func Test_ResultNDJSON(t *testing.T) {
+	ctx := context.Background()
+
+	result, err := ndjsonConfig(10000, 0).Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	var records []logRecord
+	if err := result.NDJSON(&records); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if assert.Len(t, records, 10000) {
+		assert.Equal(t, logRecord{Seq: 1, Level: "info"}, records[0])
+		assert.Equal(t, logRecord{Seq: 10000, Level: "info"}, records[9999])
+	}
+
+	typed, err := DecodeNDJSON[logRecord](result)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, records, typed)
+
+	var maps []map[string]any
+	assert.NoError(t, result.NDJSON(&maps))
+	assert.Len(t, maps, 10000)
+
+	assert.EqualError(t, result.NDJSON(records), "NDJSON decodes into a pointer to a slice, not []checkpoint.logRecord")
+}
+
+func Test_ResultNDJSONErrors(t *testing.T) {
+	ctx := context.Background()
+
+	// Record 7777 is malformed, 14 blank lines coming before it.
+	result, err := ndjsonConfig(7777, 7777).Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	var records []logRecord
+	err = result.NDJSON(&records)
+	assert.ErrorContains(t, err, "NDJSON line 7791: unexpected end of JSON input")
+	_, err = DecodeNDJSON[logRecord](result)
+	assert.ErrorContains(t, err, "NDJSON line 7791: ")
+
+	// The callback form decodes a record at a time and stops at the first
+	// error.
+	var seen int
+	err = EachNDJSON(result, func(r logRecord) error {
+		seen++
+		return nil
+	})
+	assert.ErrorContains(t, err, "NDJSON line 7791: ")
+	assert.Equal(t, 7776, seen)
+
+	errStop := errors.New("stop")
+	err = EachNDJSON(result, func(r logRecord) error {
+		if r.Seq == 3 {
+			return errStop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.EqualError(t, err, "NDJSON line 3: stop")
+}
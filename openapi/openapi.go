@@ -0,0 +1,258 @@
+// Package openapi checks the requests and responses of checkpoint runs
+// against an OpenAPI 3 document:
+//
+//	doc, err := openapi.Load("openapi.yaml")
+//	...
+//	conf.WithContract(doc)
+//	result, err := conf.Run(ctx)
+//
+// The operation is located by the request method and the route pattern of the
+// config. Parameters and bodies of requests, and statuses, headers and bodies
+// of responses are validated. It is a package of its own so the OpenAPI
+// dependency is only pulled in by the tests that use it.
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/rkuprov/checkpoint"
+)
+
+// ErrUnknownOperation is reported when the document does not describe the
+// method and route pattern of a request.
+var ErrUnknownOperation = errors.New("operation not described by the OpenAPI document")
+
+// ViolationError lists how a request or a response breaks the document, in
+// sorted order.
+type ViolationError struct {
+	Operation  string // the method and OpenAPI path, such as "GET /users/{id}"
+	Response   bool   // whether the response broke the document, rather than the request
+	Violations []string
+}
+
+func (e *ViolationError) Error() string {
+	part := "request"
+	if e.Response {
+		part = "response"
+	}
+	return fmt.Sprintf("%s %s does not match the OpenAPI document:\n%s",
+		e.Operation, part, strings.Join(e.Violations, "\n"))
+}
+
+// Document is an OpenAPI document usable as a checkpoint.Contract.
+type Document struct {
+	doc *openapi3.T
+}
+
+var _ checkpoint.Contract = (*Document)(nil)
+
+// Load reads and validates the OpenAPI document at path, in JSON or YAML.
+func Load(path string) (*Document, error) {
+	doc, err := openapi3.NewLoader().LoadFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", path, err)
+	}
+	return newDocument(doc)
+}
+
+// Parse reads and validates an OpenAPI document in JSON or YAML.
+func Parse(data []byte) (*Document, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(data)
+	if err != nil {
+		return nil, fmt.Errorf("loading the OpenAPI document: %w", err)
+	}
+	return newDocument(doc)
+}
+
+func newDocument(doc *openapi3.T) (*Document, error) {
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	return &Document{doc: doc}, nil
+}
+
+// CheckRequest validates the parameters and the body of req against the
+// operation for its method and pattern. An operation the document does not
+// describe is reported with ErrUnknownOperation.
+func (d *Document) CheckRequest(req *http.Request, pattern string) error {
+	input, err := d.input(req, pattern)
+	if err != nil {
+		return err
+	}
+	err = openapi3filter.ValidateRequest(req.Context(), input)
+	return violationError(input, false, err)
+}
+
+// CheckResponse validates the status, headers and body of result against the
+// operation for the method and pattern of req. Undocumented statuses are
+// violations. Unknown operations are only reported by CheckRequest.
+func (d *Document) CheckResponse(req *http.Request, pattern string, result *checkpoint.Result) error {
+	input, err := d.input(req, pattern)
+	if err != nil {
+		return nil
+	}
+	options := *input.Options
+	options.IncludeResponseStatus = true
+	err = openapi3filter.ValidateResponse(req.Context(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 result.StatusCode,
+		Header:                 result.RawHeaders,
+		Body:                   io.NopCloser(bytes.NewReader(result.Body)),
+		Options:                &options,
+	})
+	return violationError(input, true, err)
+}
+
+// input finds the operation for req and pattern and the path parameters of
+// req.
+func (d *Document) input(req *http.Request, pattern string) (*openapi3filter.RequestValidationInput, error) {
+	template := openAPIPath(pattern)
+	for path, item := range d.doc.Paths.Map() {
+		if normalizePath(path) != normalizePath(template) {
+			continue
+		}
+		operation := item.GetOperation(req.Method)
+		if operation == nil {
+			break
+		}
+		return &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: pathParams(path, req.URL),
+			Route: &routers.Route{
+				Spec:      d.doc,
+				Path:      path,
+				PathItem:  item,
+				Method:    req.Method,
+				Operation: operation,
+			},
+			Options: &openapi3filter.Options{
+				MultiError:         true,
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrUnknownOperation, req.Method, template)
+}
+
+// openAPIPath turns a route pattern into an OpenAPI path: {name:regexp},
+// {name...}, :name and *name parameters become {name}, and a leading method
+// and {$} are dropped.
+func openAPIPath(pattern string) string {
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		pattern = strings.TrimSpace(pattern[i+1:])
+	}
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		switch {
+		case segment == "{$}":
+			segments[i] = ""
+		case strings.HasPrefix(segment, ":"), strings.HasPrefix(segment, "*") && len(segment) > 1:
+			segments[i] = "{" + segment[1:] + "}"
+		case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+			name, _, _ := strings.Cut(segment[1:len(segment)-1], ":")
+			segments[i] = "{" + strings.TrimSuffix(name, "...") + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// normalizePath blanks the parameter names of an OpenAPI path, so paths that
+// only differ by them compare equal.
+func normalizePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			segments[i] = "{}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// pathParams takes the values of the parameters of the OpenAPI path from u.
+// Segments are matched from the end, so a server base path or a prefix in
+// front of the request path does not shift them.
+func pathParams(path string, u *url.URL) map[string]string {
+	params := make(map[string]string)
+	names := strings.Split(strings.Trim(path, "/"), "/")
+	values := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	for i := 1; i <= len(names) && i <= len(values); i++ {
+		name := names[len(names)-i]
+		if !strings.HasPrefix(name, "{") || !strings.HasSuffix(name, "}") {
+			continue
+		}
+		value := values[len(values)-i]
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			value = unescaped
+		}
+		params[name[1:len(name)-1]] = value
+	}
+	return params
+}
+
+// violationError turns the error of a kin-openapi validation into a
+// *ViolationError.
+func violationError(input *openapi3filter.RequestValidationInput, response bool, err error) error {
+	if err == nil {
+		return nil
+	}
+	var violations []string
+	where := "request"
+	if response {
+		where = "response body"
+	}
+	collectViolations(err, where, &violations)
+	slices.Sort(violations)
+	return &ViolationError{
+		Operation:  input.Route.Method + " " + input.Route.Path,
+		Response:   response,
+		Violations: violations,
+	}
+}
+
+// collectViolations describes each error of err on a line of its own. where
+// names the part of the request or response the errors are about.
+func collectViolations(err error, where string, violations *[]string) {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		for _, err := range e {
+			collectViolations(err, where, violations)
+		}
+	case *openapi3filter.RequestError:
+		where = "request body"
+		if p := e.Parameter; p != nil {
+			where = fmt.Sprintf("%s parameter %s", p.In, p.Name)
+		}
+		if e.Err == nil {
+			*violations = append(*violations, where+": "+e.Reason)
+			return
+		}
+		collectViolations(e.Err, where, violations)
+	case *openapi3filter.ResponseError:
+		if e.Err == nil {
+			*violations = append(*violations, e.Reason)
+			return
+		}
+		if strings.HasPrefix(e.Reason, "response header") {
+			where = e.Reason
+		}
+		collectViolations(e.Err, where, violations)
+	case *openapi3.SchemaError:
+		if pointer := e.JSONPointer(); len(pointer) > 0 {
+			where += " at /" + strings.Join(pointer, "/")
+		}
+		*violations = append(*violations, where+": "+e.Reason)
+	default:
+		*violations = append(*violations, where+": "+err.Error())
+	}
+}
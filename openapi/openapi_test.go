@@ -0,0 +1,128 @@
+package openapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/rkuprov/checkpoint"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RunWithOpenAPI(t *testing.T) {
+	ctx := context.Background()
+
+	doc, err := Load("testdata/users.yaml")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	tc := []struct {
+		pattern string
+		path    string
+		body    string
+		status  int
+		wantErr string
+	}{
+		{
+			pattern: "GET /users/{id}",
+			path:    "/users/7",
+			body:    `{"id":7,"name":"ann"}`,
+			status:  http.StatusOK,
+		},
+		{
+			pattern: "GET /users/{id}",
+			path:    "/users/7",
+			body:    `{"id":7}`,
+			status:  http.StatusOK,
+			wantErr: "GET /users/{id} response does not match the OpenAPI document:\n" +
+				`response body at /name: property "name" is missing`,
+		},
+		{
+			pattern: "GET /users/{id}",
+			path:    "/users/7",
+			body:    `{"error":"boom"}`,
+			status:  http.StatusInternalServerError,
+			wantErr: "GET /users/{id} response does not match the OpenAPI document:\n" +
+				"status is not supported",
+		},
+		{
+			pattern: "GET /users/{id}",
+			path:    "/users/ann",
+			status:  http.StatusNotFound,
+			wantErr: "GET /users/{id} request does not match the OpenAPI document:\n" +
+				"path parameter id: value ann: an invalid integer: invalid syntax",
+		},
+	}
+
+	for i, c := range tc {
+		conf := checkpoint.Init(http.NewServeMux())
+		conf.URLPattern = c.pattern
+		conf.Path = c.path
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(c.status)
+			_, _ = w.Write([]byte(c.body))
+		}
+		conf.WithContract(doc)
+
+		result, err := conf.Run(ctx)
+		assert.NotNil(t, result, "failure in the test case: %d", i)
+		if c.wantErr == "" {
+			assert.NoError(t, err, "failure in the test case: %d", i)
+			continue
+		}
+		assert.EqualError(t, err, c.wantErr, "failure in the test case: %d", i)
+		var violationErr *ViolationError
+		assert.True(t, errors.As(err, &violationErr), "failure in the test case: %d", i)
+	}
+}
+
+func Test_RunWithOpenAPIRequestBody(t *testing.T) {
+	ctx := context.Background()
+
+	doc, err := Load("testdata/users.yaml")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	var received string
+	conf := checkpoint.Post(http.NewServeMux(), "/users", `{"name":1}`)
+	conf.URLPattern = "POST /users"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 64)
+		n, _ := r.Body.Read(buf)
+		received = string(buf[:n])
+		w.WriteHeader(http.StatusCreated)
+	}
+	conf.WithHeaders(checkpoint.Header("Content-Type", "application/json")).WithContract(doc)
+
+	_, err = conf.Run(ctx)
+	assert.EqualError(t, err, "POST /users request does not match the OpenAPI document:\n"+
+		`request body at /id: property "id" is missing`+"\n"+
+		"request body at /name: value must be a string")
+	assert.Equal(t, `{"name":1}`, received)
+}
+
+func Test_RunWithOpenAPIUnknownOperation(t *testing.T) {
+	ctx := context.Background()
+
+	doc, err := Load("testdata/users.yaml")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	conf := checkpoint.Init(http.NewServeMux())
+	conf.URLPattern = "DELETE /users/{userID}"
+	conf.Path = "/users/7"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+	conf.WithContract(doc)
+
+	result, err := conf.Run(ctx)
+	assert.NotNil(t, result)
+	assert.ErrorIs(t, err, ErrUnknownOperation)
+	assert.EqualError(t, err, "operation not described by the OpenAPI document: DELETE /users/{userID}")
+	var violationErr *ViolationError
+	assert.False(t, errors.As(err, &violationErr))
+}
@@ -0,0 +1,62 @@
+// Package otelcheckpoint traces checkpoint runs with OpenTelemetry:
+//
+//	conf.WithTracer(otelcheckpoint.New(otel.Tracer("tests")))
+//	result, err := conf.Run(ctx)
+//
+// Every run gets a client span named "checkpoint METHOD PATTERN", carrying the
+// status code, the body sizes and the matched route. The request context
+// carries the span, so otelhttp middlewares in the handler chain record their
+// spans as its children.
+package otelcheckpoint
+
+import (
+	"context"
+
+	"github.com/rkuprov/checkpoint"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer is a checkpoint.Tracer starting OpenTelemetry spans.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+var _ checkpoint.Tracer = (*Tracer)(nil)
+
+// New returns a Tracer starting its spans with tracer.
+func New(tracer trace.Tracer) *Tracer {
+	return &Tracer{tracer: tracer}
+}
+
+// StartRun starts the span of a run, ended with its outcome. A failed run, or
+// a response with a 5xx status, marks the span as failed.
+func (t *Tracer) StartRun(ctx context.Context, method, pattern string) (context.Context, func(*checkpoint.Result, error)) {
+	ctx, span := t.tracer.Start(ctx, "checkpoint "+method+" "+pattern,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("http.request.method", method)),
+	)
+	return ctx, func(result *checkpoint.Result, err error) {
+		defer span.End()
+		if result != nil {
+			span.SetAttributes(
+				attribute.Int("http.response.status_code", result.StatusCode),
+				attribute.Int64("http.response.body.size", result.BytesWritten),
+			)
+			if result.Pattern != "" {
+				span.SetAttributes(attribute.String("http.route", result.Pattern))
+			}
+			if req := result.Request(); req != nil && req.ContentLength >= 0 {
+				span.SetAttributes(attribute.Int64("http.request.body.size", req.ContentLength))
+			}
+			if result.StatusCode >= 500 {
+				span.SetStatus(codes.Error, "")
+			}
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+}
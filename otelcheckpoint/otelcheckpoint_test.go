@@ -0,0 +1,94 @@
+package otelcheckpoint
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/rkuprov/checkpoint"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newRecorder returns a span recorder and the tracer provider feeding it.
+func newRecorder() (*tracetest.SpanRecorder, *sdktrace.TracerProvider) {
+	recorder := tracetest.NewSpanRecorder()
+	return recorder, sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+}
+
+func Test_RunWithTracer(t *testing.T) {
+	ctx := context.Background()
+	recorder, provider := newRecorder()
+
+	conf := checkpoint.Post(http.NewServeMux(), "/items/7", `{"name":"widget"}`).
+		WithTracer(New(provider.Tracer("checkpoint"))).
+		WithMiddlewares(func(next http.Handler) http.Handler {
+			return otelhttp.NewHandler(next, "server", otelhttp.WithTracerProvider(provider))
+		})
+	conf.URLPattern = "POST /items/{id}"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":7}`))
+	}
+
+	_, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	server, run := spans[0], spans[1]
+	assert.Equal(t, "checkpoint POST /items/{id}", run.Name())
+	assert.Equal(t, trace.SpanKindClient, run.SpanKind())
+	assert.False(t, run.Parent().IsValid())
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("http.request.method", "POST"),
+		attribute.Int("http.response.status_code", http.StatusCreated),
+		attribute.Int64("http.response.body.size", 8),
+		attribute.String("http.route", "/items/{id}"),
+	}, run.Attributes())
+
+	// The otelhttp middleware span is a child of the run span.
+	assert.Equal(t, "server", server.Name())
+	assert.Equal(t, run.SpanContext().TraceID(), server.SpanContext().TraceID())
+	assert.Equal(t, run.SpanContext().SpanID(), server.Parent().SpanID())
+}
+
+func Test_RunWithTracerErrors(t *testing.T) {
+	ctx := context.Background()
+	recorder, provider := newRecorder()
+
+	conf := checkpoint.Get(http.NewServeMux(), "/broken").
+		WithTracer(New(provider.Tracer("checkpoint")))
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}
+	_, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	conf.OnAfterRun(func(*http.Request, *checkpoint.Result) error {
+		return errors.New("log closed")
+	})
+	_, err = conf.RunServer(ctx)
+	assert.Error(t, err)
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	assert.Equal(t, "checkpoint GET /broken", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Empty(t, spans[0].Events())
+	assert.Equal(t, "after-run hook 1: log closed", spans[1].Status().Description)
+	assert.Len(t, spans[1].Events(), 1)
+}
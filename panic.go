@@ -0,0 +1,78 @@
+package checkpoint
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// PanicError is returned by Run, along with the response recorded so far,
+// when the handler or a middleware panics. Stack starts at the function that
+// panicked and stops before Run. Origin is the layer of the chain that
+// panicked, when Run could tell, named as Result.WrittenBy reports it. The
+// outer middlewares the panic unwound ran their deferred calls before Run
+// recovered it.
+type PanicError struct {
+	Value  any
+	Stack  string
+	Origin *Layer
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s panicked: %v\n\n%s", e.origin(), e.Value, e.Stack)
+}
+
+// InMiddleware reports whether a middleware panicked rather than the handler.
+func (e *PanicError) InMiddleware() bool {
+	return e.Origin != nil && !e.Origin.Handler
+}
+
+// origin describes the layer that panicked: the handler, or the middleware by
+// its position followed by its name when it has one.
+func (e *PanicError) origin() string {
+	if !e.InMiddleware() {
+		return "handler"
+	}
+	position := fmt.Sprintf("middleware[%d]", e.Origin.Index)
+	if e.Origin.Name == "" || e.Origin.Name == position {
+		return position
+	}
+	return fmt.Sprintf("%s (%s)", position, e.Origin.Name)
+}
+
+// Unwrap returns the panic value when it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// panicStack renders the stack of the panicking goroutine, from the function
+// that panicked down to serveHTTP, excluded. It is called by the deferred
+// function recovering the panic.
+func panicStack() string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	inPanic := false
+	started := false
+	for {
+		frame, more := frames.Next()
+		switch {
+		case frame.Function == "runtime.gopanic":
+			inPanic = true
+		case !inPanic:
+		case strings.HasPrefix(frame.Function, "github.com/rkuprov/checkpoint.(*TestConfig).serveHTTP"):
+			return b.String()
+		case !started && strings.HasPrefix(frame.Function, "runtime."):
+			// Runtime frames raising the panic, as for a nil map write.
+		default:
+			started = true
+			fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			return b.String()
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func panickingHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write([]byte("started"))
+	var m map[string]int
+	m["boom"]++
+}
+
+func panickingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(io.ErrUnexpectedEOF)
+	})
+}
+
+func Test_RunRecoversPanics(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/items"
+	conf.Path = "/items"
+	conf.RouteFunc = panickingHandler
+
+	result, err := conf.Run(ctx)
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected a *PanicError, got %v", err)
+	}
+	assert.Equal(t, "assignment to entry in nil map", panicErr.Unwrap().Error())
+	assert.True(t, strings.HasPrefix(panicErr.Stack, "github.com/rkuprov/checkpoint.panickingHandler\n"), panicErr.Stack)
+	assert.NotContains(t, panicErr.Stack, "serveHTTP")
+	assert.NotContains(t, panicErr.Stack, "runtime.")
+	assert.Contains(t, err.Error(), "handler panicked: assignment to entry in nil map\n\ngithub.com/rkuprov/checkpoint.panickingHandler\n")
+	assert.Equal(t, http.StatusAccepted, result.StatusCode)
+	assert.Equal(t, "started", result.Body.String())
+
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+	conf.WithMiddlewares(panickingMiddleware)
+	_, err = conf.Run(ctx)
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected a *PanicError, got %v", err)
+	}
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.True(t, strings.HasPrefix(panicErr.Stack, "github.com/rkuprov/checkpoint.panickingMiddleware.func1\n"), panicErr.Stack)
+
+	// With a timeout the panic is recovered in the handler goroutine.
+	conf.Timeout = time.Second
+	_, err = conf.Run(ctx)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func Test_RunPropagatePanics(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/items"
+	conf.Path = "/items"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}
+	conf.PropagatePanics = true
+
+	assert.PanicsWithValue(t, "boom", func() {
+		_, _ = conf.Run(ctx)
+	})
+}
+
+func Test_RunPanicOrigin(t *testing.T) {
+	ctx := context.Background()
+
+	// deferring is a middleware recording, in a deferred call, that the
+	// panic unwound it.
+	var unwound []string
+	deferring := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer func() { unwound = append(unwound, name) }()
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	tests := []struct {
+		conf    *TestConfig
+		origin  Layer
+		message string
+		unwound []string
+	}{
+		{
+			// The outermost middleware.
+			conf: Get(http.NewServeMux(), "/panics").
+				WithMiddlewares(panickingMiddleware, deferring("inner")).
+				WithRouteFunc(ok),
+			origin:  Layer{Index: 0, Name: "middleware[0]"},
+			message: "middleware[0] panicked: unexpected EOF\n\n",
+		},
+		{
+			// The innermost middleware, named.
+			conf: Get(http.NewServeMux(), "/panics").
+				WithMiddlewares(deferring("outer"), deferring("middle")).
+				WithNamedMiddleware("broken", panickingMiddleware).
+				WithRouteFunc(ok),
+			origin:  Layer{Index: 2, Name: "broken"},
+			message: "middleware[2] (broken) panicked: unexpected EOF\n\n",
+			unwound: []string{"middle", "outer"},
+		},
+		{
+			// The handler.
+			conf: Get(http.NewServeMux(), "/panics").
+				WithMiddlewares(deferring("outer"), deferring("inner")).
+				WithRouteFunc(panickingHandler),
+			origin:  Layer{Index: 2, Name: "handler", Handler: true},
+			message: "handler panicked: assignment to entry in nil map\n\n",
+			unwound: []string{"inner", "outer"},
+		},
+	}
+
+	for i, tt := range tests {
+		unwound = nil
+		_, err := tt.conf.Run(ctx)
+		var panicErr *PanicError
+		if !assert.ErrorAs(t, err, &panicErr, "failure in the test case: %d", i) {
+			continue
+		}
+		assert.Equal(t, &tt.origin, panicErr.Origin, "failure in the test case: %d", i)
+		assert.Equal(t, !tt.origin.Handler, panicErr.InMiddleware(), "failure in the test case: %d", i)
+		assert.True(t, strings.HasPrefix(err.Error(), tt.message), "failure in the test case: %d", i)
+		assert.Equal(t, tt.unwound, unwound, "failure in the test case: %d", i)
+	}
+
+	// A panic recovered by a middleware is not blamed for a later one.
+	recovering := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() { _ = recover() }()
+			next.ServeHTTP(w, r)
+		})
+	}
+	panickingAfter := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			panic("after next")
+		})
+	}
+	conf := Get(http.NewServeMux(), "/panics").
+		WithMiddlewares(panickingAfter, recovering, panickingMiddleware).
+		WithRouteFunc(ok)
+	_, err := conf.Run(ctx)
+	var panicErr *PanicError
+	if assert.ErrorAs(t, err, &panicErr) {
+		assert.Equal(t, &Layer{Index: 0, Name: "middleware[0]"}, panicErr.Origin)
+		assert.Equal(t, "after next", panicErr.Value)
+	}
+}
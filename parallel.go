@@ -0,0 +1,83 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// RunParallel runs n copies of tc concurrently against the same router, to
+// check under -race that handlers sharing state are safe for concurrent use.
+// Each copy runs on its own clone of tc with its own recorder and body. The
+// routes are registered once before the copies start, so the router is only
+// read while they run. It returns the results in the order the copies were
+// started and the error of the first copy that failed, if any. As with
+// RunUntil, a body set by WithBodyReader cannot be sent more than once, and
+// Body and the files of a multipart body are read once and buffered.
+func (tc *TestConfig) RunParallel(ctx context.Context, n int) ([]*Result, error) {
+	if n < 1 {
+		return nil, errors.New("RunParallel needs at least one request")
+	}
+	if err := tc.Validate(); err != nil {
+		return nil, err
+	}
+	if tc.BaseURL == "" && !reflect.TypeOf(tc.Router).Comparable() {
+		return nil, fmt.Errorf("RunParallel cannot register the routes of %T once, the router must be comparable", tc.Router)
+	}
+	if tc.body != nil && tc.body.source == "WithBodyReader" {
+		return nil, errors.New("RunParallel cannot send a body set by WithBodyReader more than once, use WithBodyBytes")
+	}
+	if tc.body != nil && tc.body.buffer != nil {
+		if err := tc.body.buffer(); err != nil {
+			return nil, fmt.Errorf("reading the request body: %w", err)
+		}
+	}
+	var body []byte
+	if tc.Body != nil {
+		var err error
+		if body, err = io.ReadAll(tc.Body); err != nil {
+			return nil, fmt.Errorf("reading the request body: %w", err)
+		}
+		tc.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	// Register the routes up front: routers other than ServeMux do not allow
+	// adding routes while they serve requests.
+	if tc.BaseURL == "" {
+		path, err := tc.requestPath()
+		if err != nil {
+			return nil, err
+		}
+		routeMethod, urlPattern := tc.routePattern(path)
+		if _, _, err := tc.handler(routeMethod, urlPattern, useRouterMiddlewares(tc.Router)); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]*Result, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		conf := tc.Clone()
+		if body != nil {
+			conf.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = conf.Run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return results, fmt.Errorf("request %d: %w", i+1, err)
+		}
+	}
+	return results, nil
+}
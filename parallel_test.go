@@ -0,0 +1,123 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// counter is a handler guarding its count with a mutex.
+type counter struct {
+	mu    sync.Mutex
+	count int
+}
+
+func (c *counter) increment(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	c.count++
+	n := c.count
+	c.mu.Unlock()
+	_, _ = fmt.Fprintf(w, "%s %d", b, n)
+}
+
+func Test_RunParallel(t *testing.T) {
+	ctx := context.Background()
+
+	tt := []struct {
+		name   string
+		router Router
+	}{
+		{name: "ServeMux", router: http.NewServeMux()},
+		{name: "chi", router: chi.NewRouter()},
+	}
+	for i, tc := range tt {
+		c := &counter{}
+		conf := Post(tc.router, "/counter", "hit")
+		conf.RouteFunc = c.increment
+
+		results, err := conf.RunParallel(ctx, 100)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, 100, c.count, "failure in the test case: %d", i)
+		assert.Len(t, results, 100, "failure in the test case: %d", i)
+		seen := make(map[int]bool)
+		for _, result := range results {
+			assert.Equal(t, http.StatusOK, result.StatusCode, "failure in the test case: %d", i)
+			var n int
+			_, _ = fmt.Sscanf(result.Body.String(), "hit %d", &n)
+			seen[n] = true
+		}
+		assert.Len(t, seen, 100, "failure in the test case: %d", i)
+		assert.Empty(t, conf.routes, "failure in the test case: %d", i)
+	}
+}
+
+func Test_RunParallelErrors(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Get(http.NewServeMux(), "/items/1")
+	conf.URLPattern = "/items/{id}"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		if id, _ := strconv.Atoi(r.PathValue("id")); id == 1 {
+			panic("boom")
+		}
+	}
+	results, err := conf.RunParallel(ctx, 3)
+	var panicErr *PanicError
+	assert.ErrorAs(t, err, &panicErr)
+	assert.ErrorContains(t, err, "request 1: handler panicked: boom")
+	assert.Len(t, results, 3)
+
+	_, err = conf.RunParallel(ctx, 0)
+	assert.EqualError(t, err, "RunParallel needs at least one request")
+
+	conf = Init(http.NewServeMux()).WithPath("/items").WithBodyReader(http.NoBody)
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+	_, err = conf.RunParallel(ctx, 2)
+	assert.EqualError(t, err, "RunParallel cannot send a body set by WithBodyReader more than once, use WithBodyBytes")
+}
+
+func Test_RunParallelMultipart(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var uploads []string
+	conf := Init(http.NewServeMux()).WithPath("/uploads")
+	conf.Method = http.MethodPost
+	conf.WithMultipart().AddFile("file", "report.csv", strings.NewReader(strings.Repeat("a,b\n", 1<<10)))
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		b, _ := io.ReadAll(f)
+		mu.Lock()
+		uploads = append(uploads, string(b))
+		mu.Unlock()
+	}
+
+	// Every copy sends the whole file, without racing on its reader.
+	results, err := conf.RunParallel(ctx, 10)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	for _, result := range results {
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+	}
+	if assert.Len(t, uploads, 10) {
+		for _, upload := range uploads {
+			assert.Equal(t, strings.Repeat("a,b\n", 1<<10), upload)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// exchange is a run recorded by RecordTo, as stored in its file.
+type exchange struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Host         string      `json:"host,omitempty"`
+	TLS          bool        `json:"tls,omitempty"`
+	Headers      http.Header `json:"headers,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+type recordedResponse struct {
+	Status       int         `json:"status"`
+	Headers      http.Header `json:"headers,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+// recordings counts the files written by RecordTo under each name, so runs
+// of the same request get files of their own.
+var recordings = struct {
+	sync.Mutex
+	names map[string]int
+}{names: make(map[string]int)}
+
+// RecordTo writes every run of tc to a file of dir, holding the request with
+// its method, URL, headers and body, and the response with its status,
+// headers and body. Files are named after the method and path of the request,
+// as GET_users_7.json, numbered from the second run of the same request in a
+// test binary, so rerunning the suite rewrites the same files. Replay runs the
+// recorded requests again to check the responses did not drift. Streamed
+// bodies, which cannot be read again, are recorded empty.
+func (tc *TestConfig) RecordTo(dir string) *TestConfig {
+	return tc.OnAfterRun(func(req *http.Request, result *Result) error {
+		return recordExchange(dir, req, result)
+	})
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+func recordExchange(dir string, req *http.Request, result *Result) error {
+	uri := req.URL.RequestURI()
+	name := req.Method + "_" + strings.Trim(unsafeNameChars.ReplaceAllString(req.URL.Path, "_"), "_")
+	recordings.Lock()
+	key := filepath.Join(dir, name)
+	recordings.names[key]++
+	if n := recordings.names[key]; n > 1 {
+		name += "_" + strconv.Itoa(n)
+	}
+	recordings.Unlock()
+
+	x := exchange{
+		Request: recordedRequest{
+			Method:  req.Method,
+			URL:     uri,
+			Host:    req.Host,
+			TLS:     req.TLS != nil,
+			Headers: req.Header,
+		},
+		Response: recordedResponse{
+			Status:  result.StatusCode,
+			Headers: result.RawHeaders,
+		},
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			b, _ := io.ReadAll(body)
+			body.Close()
+			x.Request.Body, x.Request.BodyEncoding = harText(b)
+		}
+	}
+	x.Response.Body, x.Response.BodyEncoding = harText(result.Body)
+
+	b, err := json.MarshalIndent(x, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeGolden(filepath.Join(dir, name+".json"), append(b, '\n')); err != nil {
+		return fmt.Errorf("recording the run: %w", err)
+	}
+	return nil
+}
+
+// ReplayOption tunes Replay.
+type ReplayOption func(*replayConfig)
+
+type replayConfig struct {
+	ignored map[string]bool
+}
+
+// IgnoreHeaders leaves the response headers keys out of the comparison, on
+// top of Date, for headers changing from one run to the next.
+func IgnoreHeaders(keys ...string) ReplayOption {
+	return func(c *replayConfig) {
+		for _, key := range keys {
+			c.ignored[http.CanonicalHeaderKey(key)] = true
+		}
+	}
+}
+
+// Replay sends every request recorded by RecordTo in dir to the routes
+// registered on router, as UseExistingRoutes does, and reports a unified diff
+// of the response against the recorded one when the status, the headers or
+// the body differ. JSON bodies are compared as Result.MatchGolden does. With
+// a *testing.T each file is replayed in a subtest named after it.
+func Replay(t TB, dir string, router any, opts ...ReplayOption) {
+	t.Helper()
+	conf := replayConfig{ignored: map[string]bool{"Date": true}}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Errorf("listing the recorded runs: %v", err)
+		return
+	}
+	if len(paths) == 0 {
+		t.Errorf("no recorded runs in %s", dir)
+		return
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		if tt, ok := t.(*testing.T); ok {
+			tt.Run(name, func(st *testing.T) {
+				replayExchange(st, path, router, &conf)
+			})
+			continue
+		}
+		replayExchange(&caseT{TB: t, name: name}, path, router, &conf)
+	}
+}
+
+// replayExchange sends the request recorded at path and compares the
+// response with the recorded one.
+func replayExchange(t TB, path string, router any, conf *replayConfig) {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("reading the recorded run: %v", err)
+		return
+	}
+	var x exchange
+	if err := json.Unmarshal(content, &x); err != nil {
+		t.Errorf("decoding the recorded run %s: %v", path, err)
+		return
+	}
+	requestBody, err := decodeRecordedBody(x.Request.Body, x.Request.BodyEncoding)
+	if err != nil {
+		t.Errorf("decoding the recorded request body: %v", err)
+		return
+	}
+	responseBody, err := decodeRecordedBody(x.Response.Body, x.Response.BodyEncoding)
+	if err != nil {
+		t.Errorf("decoding the recorded response body: %v", err)
+		return
+	}
+
+	tc := Init(router)
+	tc.UseExistingRoutes = true
+	tc.Method = x.Request.Method
+	tc.Path = x.Request.URL
+	tc.Host = x.Request.Host
+	for key, values := range x.Request.Headers {
+		for _, value := range values {
+			tc.WithAddedHeaders(Header(key, value))
+		}
+	}
+	if x.Request.TLS {
+		tc.WithTLS()
+	}
+	if len(requestBody) > 0 {
+		tc.WithBodyBytes(requestBody)
+	}
+	result, err := tc.Run(context.Background())
+	if err != nil {
+		t.Errorf("replaying %s %s: %v", x.Request.Method, x.Request.URL, err)
+		return
+	}
+
+	want := conf.render(x.Response.Status, x.Response.Headers, responseBody)
+	got := conf.render(result.StatusCode, result.RawHeaders, result.Body)
+	if want == got {
+		return
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(want),
+		B:        difflib.SplitLines(got),
+		FromFile: path,
+		ToFile:   "response",
+		Context:  3,
+	})
+	t.Errorf("response to %s %s drifted from the recording:\n%s", x.Request.Method, x.Request.URL, diff)
+}
+
+// render returns the response as compared by Replay: the status, the headers
+// not ignored, and the normalized body.
+func (c *replayConfig) render(status int, header http.Header, body []byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s\n", status, http.StatusText(status))
+	for _, key := range slices.Sorted(maps.Keys(header)) {
+		if c.ignored[http.CanonicalHeaderKey(key)] {
+			continue
+		}
+		for _, value := range header[key] {
+			fmt.Fprintf(&b, "%s: %s\n", key, value)
+		}
+	}
+	b.WriteString("\n")
+	snapshot := (&Result{RawHeaders: header, Body: body}).snapshot(nil)
+	if !bytes.HasSuffix(snapshot, []byte("\n")) {
+		snapshot = append(snapshot, '\n')
+	}
+	b.Write(snapshot)
+	return b.String()
+}
+
+func decodeRecordedBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return nil, fmt.Errorf("unknown body encoding %q", encoding)
+}
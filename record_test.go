@@ -0,0 +1,119 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// itemsRouter returns a router serving items, named name, with their price
+// as the X-Price header.
+func itemsRouter(name, price string) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Price", price)
+		w.Header().Set("X-Request-Id", r.Header.Get("X-Request-Id"))
+		_, _ = w.Write([]byte(`{"id":"` + r.PathValue("id") + `","name":"` + name + `"}`))
+	})
+	mux.HandleFunc("POST /items", func(w http.ResponseWriter, r *http.Request) {
+		var item map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&item)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"8","name":"` + item["name"] + `"}`))
+	})
+	return mux
+}
+
+func Test_RecordToReplay(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "recordings")
+
+	mux := itemsRouter("widget", "5")
+	get := Get(mux, "/items/7?full=1").
+		WithHeaders(Header("X-Request-Id", "req-1")).
+		RecordTo(dir)
+	get.UseExistingRoutes = true
+	post := Init(mux).WithPath("/items").WithJSONBody(map[string]string{"name": "gadget"}).RecordTo(dir)
+	post.Method = http.MethodPost
+	post.UseExistingRoutes = true
+	for _, conf := range []*TestConfig{get, post, post} {
+		if _, err := conf.Run(ctx); err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.Equal(t, []string{"GET_items_7.json", "POST_items.json", "POST_items_2.json"}, names)
+
+	content, err := os.ReadFile(filepath.Join(dir, "POST_items.json"))
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.JSONEq(t, `{
+		"request": {
+			"method": "POST",
+			"url": "/items",
+			"headers": {"Content-Type": ["application/json"]},
+			"body": "{\"name\":\"gadget\"}"
+		},
+		"response": {
+			"status": 201,
+			"headers": {"Content-Type": ["application/json"]},
+			"body": "{\"id\":\"8\",\"name\":\"gadget\"}"
+		}
+	}`, string(content))
+
+	// The same router answers as recorded.
+	fake := &fakeT{}
+	Replay(fake, dir, mux)
+	assert.Empty(t, fake.errors)
+
+	// A changed handler is reported with a diff of its response.
+	fake = &fakeT{}
+	Replay(fake, dir, itemsRouter("sprocket", "6"))
+	if !assert.Len(t, fake.errors, 1) {
+		return
+	}
+	report := fake.errors[0]
+	assert.True(t, strings.HasPrefix(report, "case GET_items_7: response to GET /items/7?full=1 drifted from the recording:\n"), report)
+	assert.Contains(t, report, "-X-Price: 5\n+X-Price: 6\n")
+	assert.Contains(t, report, `-  "name": "widget"`+"\n"+`+  "name": "sprocket"`)
+	assert.Contains(t, report, "X-Request-Id: req-1\n")
+
+	// Ignored headers are not compared.
+	fake = &fakeT{}
+	Replay(fake, dir, itemsRouter("widget", "6"), IgnoreHeaders("x-price"))
+	assert.Empty(t, fake.errors)
+
+	// Replay runs a subtest per recording with a *testing.T.
+	Replay(t, dir, mux)
+}
+
+func Test_ReplayErrors(t *testing.T) {
+	dir := t.TempDir()
+	fake := &fakeT{}
+	Replay(fake, dir, http.NewServeMux())
+	assert.Equal(t, []string{"no recorded runs in " + dir}, fake.errors)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0o644))
+	fake = &fakeT{}
+	Replay(fake, dir, http.NewServeMux())
+	if assert.Len(t, fake.errors, 1) {
+		assert.Contains(t, fake.errors[0], "case broken: decoding the recorded run")
+	}
+}
@@ -0,0 +1,64 @@
+package checkpoint
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Redirect is a redirect response followed by Run.
+type Redirect struct {
+	StatusCode int
+	Location   *url.URL
+}
+
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// redirectRequest builds the request that follows a redirect of req to
+// location. Like http.Client, it switches to GET without a body, except for
+// 307 and 308 which keep the method and body; the body must then be
+// replayable through req.GetBody.
+func redirectRequest(req *http.Request, code int, location *url.URL) (*http.Request, error) {
+	method := req.Method
+	var body io.ReadCloser = http.NoBody
+	if code == http.StatusTemporaryRedirect || code == http.StatusPermanentRedirect {
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, fmt.Errorf("cannot replay the request body for a %d redirect to %s", code, location)
+			}
+			var err error
+			if body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	} else if method != http.MethodGet && method != http.MethodHead {
+		method = http.MethodGet
+	}
+
+	next, err := http.NewRequestWithContext(req.Context(), method, location.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	next.RequestURI = next.URL.RequestURI()
+	next.Header = req.Header.Clone()
+	if body != http.NoBody {
+		// As http.Client does, the replayed body keeps its length.
+		next.ContentLength = req.ContentLength
+		next.GetBody = req.GetBody
+	} else {
+		next.Header.Del("Content-Type")
+		next.Header.Del("Content-Length")
+	}
+	if location.Host == "" {
+		next.Host = req.Host
+	}
+	return next, nil
+}
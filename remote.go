@@ -0,0 +1,49 @@
+package checkpoint
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// InitRemote creates a TestConfig running black-box tests against the service
+// already running at baseURL, as one started by docker-compose or a staging
+// deployment. Run sends a real HTTP request built from Method, Path, the
+// headers and the body to baseURL joined with Path, and builds the Result from
+// the live response. FollowRedirects and Timeout apply as they do in process,
+// and WithRemoteTLS sets the TLS configuration of the client.
+func InitRemote(baseURL string, opts ...Option) *TestConfig {
+	tc := &TestConfig{BaseURL: baseURL}
+	for _, opt := range opts {
+		opt(tc)
+	}
+	return tc
+}
+
+// WithRemoteTLS sets the TLS configuration of the client sending requests to
+// BaseURL, as to trust a private CA or to skip the verification of a
+// self-signed certificate with InsecureSkipVerify.
+func (tc *TestConfig) WithRemoteTLS(config *tls.Config) *TestConfig {
+	tc.remoteTLS = config
+	return tc
+}
+
+// runRemote sends the request of tc to BaseURL.
+func (tc *TestConfig) runRemote(ctx context.Context, start time.Time, method, path, urlPattern string) (*Result, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Responses are reported as sent, compressed or not.
+	transport.DisableCompression = true
+	if tc.remoteTLS != nil {
+		transport.TLSClientConfig = tc.remoteTLS.Clone()
+	}
+	defer transport.CloseIdleConnections()
+
+	result, err := tc.send(ctx, start, &http.Client{Transport: transport}, tc.BaseURL, method, path, urlPattern, nil)
+	if errors.Is(err, context.DeadlineExceeded) && tc.Timeout > 0 {
+		err = fmt.Errorf("%w after %s", ErrTimeout, tc.Timeout)
+	}
+	return result, err
+}
@@ -0,0 +1,128 @@
+package checkpoint
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newExternalService starts a server standing for a service already running
+// outside the test.
+func newExternalService(t *testing.T, secure bool) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/items", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Token", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(b)
+	})
+	mux.HandleFunc("GET /api/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/api/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("GET /api/new", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("new"))
+	})
+	mux.HandleFunc("GET /api/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	srv := httptest.NewUnstartedServer(mux)
+	// Rejected certificates are expected.
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	if secure {
+		srv.StartTLS()
+	} else {
+		srv.Start()
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func Test_InitRemote(t *testing.T) {
+	ctx := context.Background()
+	srv := newExternalService(t, false)
+
+	conf := InitRemote(srv.URL+"/api", WithDefaultHeaders(Header("Authorization", "Bearer abc"))).
+		WithPath("/items").
+		WithJSONBody(map[string]string{"name": "widget"})
+	conf.Method = http.MethodPost
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusCreated, result.StatusCode)
+	assert.JSONEq(t, `{"name":"widget"}`, result.Body.String())
+	assert.Equal(t, "Bearer abc", result.Headers["X-Token"])
+	assert.NotEmpty(t, result.Headers["Date"])
+
+	conf = InitRemote(srv.URL).WithPath("/api/old")
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusMovedPermanently, result.StatusCode)
+
+	conf.FollowRedirects = 1
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "new", result.Body.String())
+	assert.Len(t, result.Redirects, 1)
+
+	conf = InitRemote(srv.URL).WithPath("/api/slow")
+	conf.Timeout = 20 * time.Millisecond
+	_, err = conf.Run(ctx)
+	assert.ErrorIs(t, err, ErrTimeout)
+}
+
+func Test_InitRemoteTLS(t *testing.T) {
+	ctx := context.Background()
+	srv := newExternalService(t, true)
+
+	conf := InitRemote(srv.URL).WithPath("/api/new")
+	_, err := conf.Run(ctx)
+	assert.ErrorContains(t, err, "certificate")
+
+	result, err := conf.WithRemoteTLS(&tls.Config{InsecureSkipVerify: true}).Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "new", result.Body.String())
+	assert.NotNil(t, result.TLS)
+}
+
+func Test_InitRemoteValidate(t *testing.T) {
+	ctx := context.Background()
+
+	tt := []struct {
+		conf     *TestConfig
+		expected string
+	}{
+		{conf: InitRemote("localhost:8080").WithPath("/"), expected: `base URL "localhost:8080" is not an absolute http or https URL`},
+		{conf: InitRemote("ftp://localhost").WithPath("/"), expected: `base URL "ftp://localhost" is not an absolute http or https URL`},
+		{conf: InitRemote("http://localhost:8080"), expected: "path cannot be empty"},
+	}
+	for i, tc := range tt {
+		_, err := tc.conf.Run(ctx)
+		assert.EqualError(t, err, tc.expected, "failure in the test case: %d", i)
+	}
+
+	// The handler and the pattern are not needed.
+	conf := InitRemote("http://localhost:8080").WithPath("/items/1")
+	conf.URLPattern = "/users/{id}"
+	assert.NoError(t, conf.Validate())
+
+	_, err := conf.RunServer(ctx)
+	assert.EqualError(t, err, "RunServer serves the handler itself, use Run to send the request to BaseURL")
+}
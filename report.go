@@ -0,0 +1,281 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reporter collects the runs of every config once set with SetReporter, for
+// reports covering each request rather than each Go test, such as JUnit XML
+// for CI dashboards. It is safe for concurrent use.
+type Reporter struct {
+	// Name is the name of the test suite in the reports, "checkpoint" when
+	// empty.
+	Name string
+
+	mu      sync.Mutex
+	entries []*ReportEntry
+}
+
+// ReportEntry is a run collected by a Reporter.
+type ReportEntry struct {
+	// Name is TestConfig.Name, or the method and the route pattern of the
+	// request, as "GET /items/{id}".
+	Name       string
+	StatusCode int // zero when no response was recorded
+	Started    time.Time
+	Duration   time.Duration
+	// Error is the error the run returned, and Failures the checks of the
+	// Expect chains on its Result that failed.
+	Error    string
+	Failures []string
+}
+
+// Failed reports whether the run returned an error or failed a check.
+func (e ReportEntry) Failed() bool {
+	return e.Error != "" || len(e.Failures) > 0
+}
+
+// NewReporter creates an empty Reporter.
+func NewReporter() *Reporter {
+	return &Reporter{}
+}
+
+var reporting = struct {
+	sync.RWMutex
+	reporter *Reporter
+}{}
+
+// SetReporter makes every Run, RunServer and RunWebSocket add its run to r,
+// nil stopping the collection. It is meant to be called from TestMain, which
+// writes the report once the tests ran:
+//
+//	func TestMain(m *testing.M) {
+//		reporter := checkpoint.NewReporter()
+//		checkpoint.SetReporter(reporter)
+//		code := m.Run()
+//		f, _ := os.Create("checkpoints.xml")
+//		_ = reporter.WriteJUnit(f)
+//		_ = f.Close()
+//		os.Exit(code)
+//	}
+func SetReporter(r *Reporter) {
+	reporting.Lock()
+	defer reporting.Unlock()
+	reporting.reporter = r
+}
+
+// report adds the run of tc to the reporter, if one is set, and links result
+// to its entry for the failures of its Expect chains.
+func (tc *TestConfig) report(result *Result, err error) {
+	reporting.RLock()
+	r := reporting.reporter
+	reporting.RUnlock()
+	if r == nil {
+		return
+	}
+
+	entry := &ReportEntry{Name: tc.reportName(result), Started: time.Now()}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if result != nil {
+		entry.StatusCode = result.StatusCode
+		entry.Duration = result.Duration
+		entry.Started = entry.Started.Add(-result.Duration)
+		result.reporter, result.entry = r, entry
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+// reportName returns the name of the run of tc in reports.
+func (tc *TestConfig) reportName(result *Result) string {
+	if tc.Name != "" {
+		return tc.Name
+	}
+	method, pattern := splitPattern(tc.URLPattern)
+	if tc.Method != "" {
+		method = tc.Method
+	}
+	if result != nil && result.request != nil {
+		method = result.request.Method
+		if result.Pattern != "" {
+			_, pattern = splitPattern(result.Pattern)
+		}
+	}
+	if pattern == "" {
+		pattern = tc.Path
+	}
+	if method == "" {
+		method = "GET"
+	}
+	return method + " " + pattern
+}
+
+// reportFailure adds a failed check of an Expect chain on r to its entry.
+func (r *Result) reportFailure(failure string) {
+	if r.reporter == nil {
+		return
+	}
+	r.reporter.mu.Lock()
+	defer r.reporter.mu.Unlock()
+	r.entry.Failures = append(r.entry.Failures, failure)
+}
+
+// Entries returns the runs collected so far, in the order they ended.
+func (r *Reporter) Entries() []ReportEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := make([]ReportEntry, len(r.entries))
+	for i, e := range r.entries {
+		entries[i] = *e
+		entries[i].Failures = append([]string(nil), e.Failures...)
+	}
+	return entries
+}
+
+func (r *Reporter) name() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return "checkpoint"
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr,omitempty"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the runs collected so far as a JUnit XML report with one
+// test suite, each run being a test case. A run that returned an error is
+// reported as an error, and one that failed checks as a failure listing them.
+func (r *Reporter) WriteJUnit(w io.Writer) error {
+	entries := r.Entries()
+	suite := junitSuite{Name: r.name(), Tests: len(entries)}
+	var total time.Duration
+	for _, e := range entries {
+		total += e.Duration
+		c := junitCase{Name: e.Name, ClassName: suite.Name, Time: seconds(e.Duration)}
+		switch {
+		case e.Error != "":
+			suite.Errors++
+			c.Error = &junitProblem{Message: e.Error, Type: "error", Text: e.Error}
+		case len(e.Failures) > 0:
+			suite.Failures++
+			c.Failure = &junitProblem{
+				Message: e.Failures[0],
+				Type:    "expectation",
+				Text:    fmt.Sprintf("status %d\n%s", e.StatusCode, strings.Join(e.Failures, "\n")),
+			}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	suite.Time = seconds(total)
+	if len(entries) > 0 {
+		suite.Timestamp = entries[0].Started.UTC().Format("2006-01-02T15:04:05")
+	}
+	doc := junitSuites{
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Time:     suite.Time,
+		Suites:   []junitSuite{suite},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("encoding the JUnit report: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// seconds renders d in seconds, as JUnit reports time.
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.6f", d.Seconds())
+}
+
+type jsonReport struct {
+	Name     string      `json:"name"`
+	Tests    int         `json:"tests"`
+	Failures int         `json:"failures"`
+	Runs     []jsonEntry `json:"runs"`
+}
+
+type jsonEntry struct {
+	Name       string    `json:"name"`
+	Status     string    `json:"status"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Started    time.Time `json:"started"`
+	DurationMS float64   `json:"durationMs"`
+	Error      string    `json:"error,omitempty"`
+	Failures   []string  `json:"failures,omitempty"`
+}
+
+// WriteJSON writes the runs collected so far as a JSON document, each run
+// with its status, "passed" or "failed", and its duration in milliseconds.
+func (r *Reporter) WriteJSON(w io.Writer) error {
+	entries := r.Entries()
+	report := jsonReport{Name: r.name(), Tests: len(entries), Runs: make([]jsonEntry, 0, len(entries))}
+	for _, e := range entries {
+		status := "passed"
+		if e.Failed() {
+			status = "failed"
+			report.Failures++
+		}
+		report.Runs = append(report.Runs, jsonEntry{
+			Name:       e.Name,
+			Status:     status,
+			StatusCode: e.StatusCode,
+			Started:    e.Started.UTC(),
+			DurationMS: milliseconds(e.Duration),
+			Error:      e.Error,
+			Failures:   e.Failures,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("encoding the JSON report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Reporter(t *testing.T) {
+	ctx := context.Background()
+
+	reporter := NewReporter()
+	SetReporter(reporter)
+	t.Cleanup(func() { SetReporter(nil) })
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "GET /items/{id}"
+	conf.Path = "/items/1"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "0" {
+			http.Error(w, "no such item", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("widget"))
+	}
+
+	// A passing run, one failing its checks and one failing to run.
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	result.Expect(t).Status(http.StatusOK).Done()
+
+	missing := conf.Clone()
+	missing.Path = "/items/0"
+	result, err = missing.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	fake := &fakeT{}
+	result.Expect(fake).Status(http.StatusOK).BodyContains("widget").Done()
+	assert.Len(t, fake.errors, 1)
+
+	invalid := conf.Clone()
+	invalid.Name = "invalid path"
+	invalid.Path = "/elsewhere"
+	_, err = invalid.Run(ctx)
+	assert.Error(t, err)
+
+	// Parallel runs are collected too.
+	health := Get(http.NewServeMux(), "/health")
+	health.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+	_, err = health.RunParallel(ctx, 20)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	entries := reporter.Entries()
+	if !assert.Len(t, entries, 23) {
+		return
+	}
+	assert.Equal(t, "GET /items/{id}", entries[0].Name)
+	assert.Equal(t, http.StatusOK, entries[0].StatusCode)
+	assert.False(t, entries[0].Failed())
+	assert.Positive(t, entries[0].Duration)
+	assert.Equal(t, []string{"expected status 200, got 404", `expected body to contain "widget"`}, entries[1].Failures)
+	assert.True(t, entries[1].Failed())
+	assert.Equal(t, "invalid path", entries[2].Name)
+	assert.Equal(t, "path /elsewhere does not match pattern GET /items/{id} (segment 1: elsewhere != items)", entries[2].Error)
+	assert.Equal(t, "GET /health", entries[22].Name)
+
+	var junit bytes.Buffer
+	if err := reporter.WriteJUnit(&junit); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.True(t, strings.HasPrefix(junit.String(), xml.Header), junit.String())
+	var doc struct {
+		XMLName  xml.Name `xml:"testsuites"`
+		Tests    int      `xml:"tests,attr"`
+		Failures int      `xml:"failures,attr"`
+		Errors   int      `xml:"errors,attr"`
+		Suites   []struct {
+			Name  string `xml:"name,attr"`
+			Tests int    `xml:"tests,attr"`
+			Cases []struct {
+				Name    string `xml:"name,attr"`
+				Class   string `xml:"classname,attr"`
+				Time    string `xml:"time,attr"`
+				Failure *struct {
+					Message string `xml:"message,attr"`
+					Text    string `xml:",chardata"`
+				} `xml:"failure"`
+				Error *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"error"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(junit.Bytes(), &doc); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, 23, doc.Tests)
+	assert.Equal(t, 1, doc.Failures)
+	assert.Equal(t, 1, doc.Errors)
+	if assert.Len(t, doc.Suites, 1) && assert.Len(t, doc.Suites[0].Cases, 23) {
+		assert.Equal(t, "checkpoint", doc.Suites[0].Name)
+		cases := doc.Suites[0].Cases
+		assert.Equal(t, "GET /items/{id}", cases[0].Name)
+		assert.Equal(t, "checkpoint", cases[0].Class)
+		assert.Regexp(t, `^0\.\d{6}$`, cases[0].Time)
+		assert.Nil(t, cases[0].Failure)
+		assert.Nil(t, cases[0].Error)
+		if assert.NotNil(t, cases[1].Failure) {
+			assert.Equal(t, "expected status 200, got 404", cases[1].Failure.Message)
+			assert.Equal(t, "status 404\nexpected status 200, got 404\nexpected body to contain \"widget\"", cases[1].Failure.Text)
+		}
+		if assert.NotNil(t, cases[2].Error) {
+			assert.Contains(t, cases[2].Error.Message, "does not match pattern")
+		}
+	}
+
+	reporter.Name = "api"
+	var report bytes.Buffer
+	if err := reporter.WriteJSON(&report); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	var decoded struct {
+		Name     string `json:"name"`
+		Tests    int    `json:"tests"`
+		Failures int    `json:"failures"`
+		Runs     []struct {
+			Name       string   `json:"name"`
+			Status     string   `json:"status"`
+			StatusCode int      `json:"statusCode"`
+			DurationMS float64  `json:"durationMs"`
+			Failures   []string `json:"failures"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(report.Bytes(), &decoded); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "api", decoded.Name)
+	assert.Equal(t, 23, decoded.Tests)
+	assert.Equal(t, 2, decoded.Failures)
+	assert.Equal(t, "passed", decoded.Runs[0].Status)
+	assert.Equal(t, "failed", decoded.Runs[1].Status)
+	assert.Equal(t, http.StatusNotFound, decoded.Runs[1].StatusCode)
+	assert.Len(t, decoded.Runs[1].Failures, 2)
+
+	// Runs are not collected once the reporter is unset.
+	SetReporter(nil)
+	_, err = conf.Run(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, reporter.Entries(), 23)
+}
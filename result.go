@@ -0,0 +1,190 @@
+package checkpoint
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// decodeBody undoes the content encodings of a response body, listed in the
+// order they were applied. Identity and unknown encodings are left as they are,
+// and so are empty bodies, as those of HEAD requests and 204 or 304 responses.
+func decodeBody(encodings []string, body []byte) ([]byte, error) {
+	if len(body) == 0 {
+		return body, nil
+	}
+	var applied []string
+	for _, value := range encodings {
+		for _, encoding := range strings.Split(value, ",") {
+			applied = append(applied, strings.ToLower(strings.TrimSpace(encoding)))
+		}
+	}
+	for i := len(applied) - 1; i >= 0; i-- {
+		var r io.Reader
+		var err error
+		switch applied[i] {
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(bytes.NewReader(body))
+		case "deflate":
+			// Some servers send raw deflate data instead of the zlib format.
+			if r, err = zlib.NewReader(bytes.NewReader(body)); errors.Is(err, zlib.ErrHeader) {
+				r, err = flate.NewReader(bytes.NewReader(body)), nil
+			}
+		case "br":
+			r = brotli.NewReader(bytes.NewReader(body))
+		default:
+			return body, nil
+		}
+		if err == nil {
+			body, err = io.ReadAll(r)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s response body: %w", applied[i], err)
+		}
+	}
+	return body, nil
+}
+
+// Raw returns the response as recorded, for helpers working on an
+// *http.Response. Its body can be read on every call.
+func (r *Result) Raw() *http.Response {
+	if r.response == nil {
+		return nil
+	}
+	res := *r.response
+	res.Body = io.NopCloser(bytes.NewReader(r.RawBody))
+	return &res
+}
+
+// Recorder returns the recorder the last request was served with.
+func (r *Result) Recorder() *httptest.ResponseRecorder {
+	return r.recorder
+}
+
+// HeaderValues returns every value of the response header key, as written by
+// the handler, without the joining done for Headers.
+func (r *Result) HeaderValues(key string) []string {
+	return r.RawHeaders.Values(key)
+}
+
+// IsSuccess reports whether the status is 2xx.
+func (r *Result) IsSuccess() bool {
+	return r.StatusCode >= 200 && r.StatusCode <= 299
+}
+
+// IsClientError reports whether the status is 4xx.
+func (r *Result) IsClientError() bool {
+	return r.StatusCode >= 400 && r.StatusCode <= 499
+}
+
+// IsServerError reports whether the status is 5xx.
+func (r *Result) IsServerError() bool {
+	return r.StatusCode >= 500 && r.StatusCode <= 599
+}
+
+// Within reports an error when Run took longer than d. Durations are wall-clock
+// time and vary with the machine running the tests, so budgets need generous
+// margins.
+func (r *Result) Within(d time.Duration) error {
+	if r.Duration > d {
+		var layers strings.Builder
+		for i, l := range r.Timing.Layers {
+			if i == 0 {
+				layers.WriteString("; ")
+			} else {
+				layers.WriteString(", ")
+			}
+			fmt.Fprintf(&layers, "%s %s", l.Layer, l.Duration)
+		}
+		return fmt.Errorf("run took %s, over the budget of %s (setup %s, handler %s, body read %s%s)",
+			r.Duration, d, r.Timing.Setup, r.Timing.Handler, r.Timing.BodyRead, layers.String())
+	}
+	return nil
+}
+
+// XML decodes the response body into v with encoding/xml. Besides UTF-8, the
+// body may declare ISO-8859-1 (latin1) or US-ASCII in its XML prolog. Decoding
+// errors name the element being decoded, as in "<urlset/url/priority>".
+func (r *Result) XML(v any) error {
+	d := newXMLDecoder(r.Body)
+	if err := d.Decode(v); err != nil {
+		if path := xmlPathAt(r.Body, d.InputOffset()); path != "" {
+			return fmt.Errorf("decoding XML at <%s>: %w", path, err)
+		}
+		return fmt.Errorf("decoding XML: %w", err)
+	}
+	return nil
+}
+
+func newXMLDecoder(body []byte) *xml.Decoder {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	d.CharsetReader = charsetReader
+	return d
+}
+
+// charsetReader converts the charsets XML declares besides UTF-8.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "iso8859-1", "latin1", "us-ascii", "ascii":
+		return &latin1Reader{r: input}, nil
+	}
+	return nil, fmt.Errorf("unsupported charset %s", charset)
+}
+
+// latin1Reader converts ISO-8859-1 to UTF-8. Every latin1 byte is the code
+// point of the same value.
+type latin1Reader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	if len(l.buf) == 0 {
+		in := make([]byte, max(len(p)/2, 1))
+		n, err := l.r.Read(in)
+		for _, b := range in[:n] {
+			l.buf = append(l.buf, string(rune(b))...)
+		}
+		if n == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, l.buf)
+	l.buf = l.buf[n:]
+	return n, nil
+}
+
+// xmlPathAt returns the path of the element being decoded at offset in body,
+// as in "urlset/url/loc", or "" when there is none.
+func xmlPathAt(body []byte, offset int64) string {
+	d := newXMLDecoder(body)
+	var path []string
+	for {
+		start := d.InputOffset()
+		tok, err := d.RawToken()
+		if err != nil || start >= offset {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+		case xml.EndElement:
+			// An element whose content failed to decode is closed at offset.
+			if len(path) > 0 && d.InputOffset() < offset {
+				path = path[:len(path)-1]
+			}
+		}
+	}
+	return strings.Join(path, "/")
+}
@@ -0,0 +1,315 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ResultXML(t *testing.T) {
+	ctx := context.Background()
+
+	type url struct {
+		Loc      string  `xml:"loc"`
+		Priority float64 `xml:"priority"`
+	}
+	type urlset struct {
+		XMLName xml.Name `xml:"urlset"`
+		Version string   `xml:"version,attr"`
+		URLs    []url    `xml:"url"`
+	}
+
+	tc := []struct {
+		body    string
+		want    urlset
+		wantErr string
+	}{
+		{
+			body: `<?xml version="1.0" encoding="UTF-8"?><urlset version="2"><url><loc>/a</loc><priority>0.5</priority></url>` +
+				`<url><loc>/b</loc><priority>1</priority></url></urlset>`,
+			want: urlset{XMLName: xml.Name{Local: "urlset"}, Version: "2", URLs: []url{{Loc: "/a", Priority: 0.5}, {Loc: "/b", Priority: 1}}},
+		},
+		{
+			body: "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><urlset version=\"1\"><url><loc>/caf\xe9</loc></url></urlset>",
+			want: urlset{XMLName: xml.Name{Local: "urlset"}, Version: "1", URLs: []url{{Loc: "/café"}}},
+		},
+		{
+			body:    `<urlset><url><loc>/a</loc><priority>high</priority></url></urlset>`,
+			wantErr: `decoding XML at <urlset/url/priority>: strconv.ParseFloat: parsing "high": invalid syntax`,
+		},
+		{
+			body:    `<urlset><url><loc>/a</url></urlset>`,
+			wantErr: "decoding XML at <urlset/url/loc>: XML syntax error on line 1: element <loc> closed by </url>",
+		},
+	}
+
+	for i, c := range tc {
+		conf := Init(http.NewServeMux())
+		conf.URLPattern = "/sitemap.xml"
+		conf.Path = "/sitemap.xml"
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = w.Write([]byte(c.body))
+		}
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		var got urlset
+		err = result.XML(&got)
+		if c.wantErr != "" {
+			assert.EqualError(t, err, c.wantErr, "failure in the test case: %d", i)
+			continue
+		}
+		assert.NoError(t, err, "failure in the test case: %d", i)
+		assert.Equal(t, c.want, got, "failure in the test case: %d", i)
+	}
+}
+
+func Test_ResultHeaderValues(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/items"
+	conf.Path = "/items"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", `</items?page=1>; rel="first"`)
+		w.Header().Add("Link", `</items?page=2>; rel="next"`)
+		w.Header().Add("Link", `</items?page=9>; rel="last"`)
+		w.Header().Add("Vary", "Accept")
+	}
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, []string{`</items?page=1>; rel="first"`, `</items?page=2>; rel="next"`, `</items?page=9>; rel="last"`},
+		result.HeaderValues("link"))
+	assert.Equal(t, []string{"Accept"}, result.HeaderValues("Vary"))
+	assert.Nil(t, result.HeaderValues("X-Missing"))
+}
+
+func Test_ResultTrailers(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/download"
+	conf.Path = "/download"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		_, _ = w.Write([]byte("file contents"))
+		w.Header().Set("X-Checksum", "abc")
+		w.Header().Add(http.TrailerPrefix+"X-Part", "1")
+		w.Header().Add(http.TrailerPrefix+"X-Part", "2")
+	}
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "file contents", result.Body.String())
+	assert.Equal(t, http.Header{"X-Checksum": {"abc"}, "X-Part": {"1", "2"}}, result.Trailers)
+	assert.NotContains(t, result.Headers, "X-Checksum")
+	assert.Empty(t, result.HeaderValues("X-Checksum"))
+}
+
+func Test_ResultDuration(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/slow"
+	conf.Path = "/slow"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.GreaterOrEqual(t, result.Timing.Handler, 20*time.Millisecond)
+	assert.Equal(t, result.Duration, result.Timing.Setup+result.Timing.Handler+result.Timing.BodyRead)
+	assert.NoError(t, result.Within(10*time.Second))
+	assert.ErrorContains(t, result.Within(time.Millisecond), "over the budget of 1ms")
+}
+
+func Test_ResultRaw(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/stream"
+	conf.Path = "/stream"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("chunk"))
+		w.(http.Flusher).Flush()
+	}
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.True(t, result.Recorder().Flushed)
+	assert.Equal(t, "chunk", result.Recorder().Body.String())
+
+	// Every Raw response can be read in full, by helpers such as httputil.
+	for range 2 {
+		dump, err := httputil.DumpResponse(result.Raw(), true)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Contains(t, string(dump), "HTTP/1.1 202 Accepted")
+		assert.Contains(t, string(dump), "\r\n\r\nchunk")
+	}
+	assert.Equal(t, http.StatusAccepted, result.Raw().StatusCode)
+}
+
+func Test_RunDecompressesResponse(t *testing.T) {
+	ctx := context.Background()
+	text := strings.Repeat("compressible text ", 100)
+
+	brotliBody := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		bw := brotli.NewWriter(w)
+		_, _ = bw.Write([]byte(text))
+		_ = bw.Close()
+	}
+
+	tc := []struct {
+		configure func(conf *TestConfig)
+		encoding  string
+		body      string
+		raw       bool // the body is left as written
+	}{
+		{configure: func(conf *TestConfig) {
+			conf.WithRouterMiddlewares(middleware.Compress(5)).WithHeaders(Header("Accept-Encoding", "gzip"))
+		}, encoding: "gzip", body: text},
+		{configure: func(conf *TestConfig) {
+			conf.WithRouterMiddlewares(middleware.Compress(5)).WithHeaders(Header("Accept-Encoding", "deflate"))
+		}, encoding: "deflate", body: text},
+		{configure: func(conf *TestConfig) { conf.RouteFunc = brotliBody }, encoding: "br", body: text},
+		// Responses that are not encoded pass through untouched.
+		{configure: func(conf *TestConfig) {}, body: text, raw: true},
+		{configure: func(conf *TestConfig) {
+			conf.DisableDecompression = true
+			conf.RouteFunc = brotliBody
+		}, encoding: "br", raw: true},
+	}
+
+	for i, c := range tc {
+		conf := Init(chi.NewRouter())
+		conf.URLPattern = "/export"
+		conf.Path = "/export"
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte(text))
+		}
+		c.configure(conf)
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, c.encoding, result.Headers["Content-Encoding"], "failure in the test case: %d", i)
+		if c.body != "" {
+			assert.Equal(t, c.body, result.Body.String(), "failure in the test case: %d", i)
+		}
+		if c.raw {
+			assert.Equal(t, result.RawBody, result.Body, "failure in the test case: %d", i)
+		} else {
+			assert.NotEqual(t, result.RawBody, result.Body, "failure in the test case: %d", i)
+		}
+	}
+
+	// Responses without a body, to a HEAD request or with a 304, have
+	// nothing to decode.
+	for i, method := range []string{http.MethodHead, http.MethodGet} {
+		conf := Init(http.NewServeMux())
+		conf.Method = method
+		conf.URLPattern = "/export"
+		conf.Path = "/export"
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+			if r.Method == http.MethodGet {
+				w.WriteHeader(http.StatusNotModified)
+			}
+		}
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, "gzip", result.Headers["Content-Encoding"], "failure in the test case: %d", i)
+		assert.Empty(t, result.Body, "failure in the test case: %d", i)
+	}
+}
+
+func Test_ResultStatusClass(t *testing.T) {
+	ctx := context.Background()
+
+	tc := []struct {
+		status                        int
+		success, clientErr, serverErr bool
+		class                         int
+	}{
+		{status: 199, class: 1},
+		{status: 200, success: true, class: 2},
+		{status: 299, success: true, class: 2},
+		{status: 300, class: 3},
+		{status: 399, class: 3},
+		{status: 400, clientErr: true, class: 4},
+		{status: 499, clientErr: true, class: 4},
+		{status: 500, serverErr: true, class: 5},
+		{status: 599, serverErr: true, class: 5},
+	}
+
+	for i, c := range tc {
+		conf := Init(http.NewServeMux())
+		conf.URLPattern = "/status"
+		conf.Path = "/status"
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(c.status)
+		}
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, c.success, result.IsSuccess(), "failure in the test case: %d", i)
+		assert.Equal(t, c.clientErr, result.IsClientError(), "failure in the test case: %d", i)
+		assert.Equal(t, c.serverErr, result.IsServerError(), "failure in the test case: %d", i)
+
+		fake := &fakeT{}
+		result.Expect(fake).StatusClass(c.class).StatusIn(c.status, 1000).Done()
+		assert.Empty(t, fake.errors, "failure in the test case: %d", i)
+		result.Expect(fake).StatusClass(c.class + 1).Done()
+		assert.Len(t, fake.errors, 1, "failure in the test case: %d", i)
+	}
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/items"
+	conf.Path = "/items"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database is down", http.StatusInternalServerError)
+	}
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	fake := &fakeT{}
+	result.Expect(fake).StatusClass(2).StatusIn(http.StatusOK, http.StatusNoContent).Done()
+	if assert.Len(t, fake.errors, 1) {
+		assert.Contains(t, fake.errors[0], "1. expected status 2xx, got 500 Internal Server Error: database is down\n")
+		assert.Contains(t, fake.errors[0], "2. expected status in [200 204], got 500 Internal Server Error: database is down\n")
+	}
+}
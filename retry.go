@@ -0,0 +1,107 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryOption tunes RunUntil.
+type RetryOption func(*retryConfig)
+
+type retryConfig struct {
+	attempts int
+	interval time.Duration
+	backoff  float64
+}
+
+// RetryAttempts sets the number of runs RunUntil makes before giving up, 10
+// by default.
+func RetryAttempts(n int) RetryOption {
+	return func(c *retryConfig) {
+		c.attempts = n
+	}
+}
+
+// RetryInterval sets the wait between two runs, 10ms by default.
+func RetryInterval(d time.Duration) RetryOption {
+	return func(c *retryConfig) {
+		c.interval = d
+	}
+}
+
+// RetryBackoff multiplies the wait by factor after every run, for an
+// exponential backoff. The default of 1 keeps the wait constant.
+func RetryBackoff(factor float64) RetryOption {
+	return func(c *retryConfig) {
+		c.backoff = factor
+	}
+}
+
+// RunUntil runs tc until cond holds for the Result, for handlers backed by
+// asynchronous work, and returns the last Result. When the attempts run out,
+// the error lists the status of every attempt. A Run error or the end of ctx
+// stops it early. Bodies are sent again on every attempt; a body set by
+// WithBodyReader cannot be, and Body and the files of a multipart body are
+// read once and buffered.
+func (tc *TestConfig) RunUntil(ctx context.Context, cond func(*Result) bool, opts ...RetryOption) (*Result, error) {
+	rc := retryConfig{attempts: 10, interval: 10 * time.Millisecond, backoff: 1}
+	for _, opt := range opts {
+		opt(&rc)
+	}
+	if rc.attempts < 1 {
+		return nil, errors.New("RunUntil needs at least one attempt")
+	}
+	if tc.body != nil && tc.body.source == "WithBodyReader" {
+		return nil, errors.New("RunUntil cannot send a body set by WithBodyReader again, use WithBodyBytes")
+	}
+	if tc.body != nil && tc.body.buffer != nil {
+		if err := tc.body.buffer(); err != nil {
+			return nil, fmt.Errorf("reading the request body: %w", err)
+		}
+	}
+	var body []byte
+	if tc.Body != nil {
+		var err error
+		if body, err = io.ReadAll(tc.Body); err != nil {
+			return nil, fmt.Errorf("reading the request body: %w", err)
+		}
+		tc.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	var statuses []string
+	wait := rc.interval
+	for attempt := 1; ; attempt++ {
+		conf := tc.Clone()
+		if body != nil {
+			conf.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		result, err := conf.Run(ctx)
+		if err != nil {
+			return result, err
+		}
+		if cond(result) {
+			return result, nil
+		}
+		statuses = append(statuses, strconv.Itoa(result.StatusCode))
+		if attempt == rc.attempts {
+			return result, fmt.Errorf("condition not met after %d attempts, statuses: %s",
+				attempt, strings.Join(statuses, ", "))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, fmt.Errorf("condition not met after %d attempts, statuses: %s: %w",
+				attempt, strings.Join(statuses, ", "), ctx.Err())
+		case <-timer.C:
+		}
+		wait = time.Duration(float64(wait) * rc.backoff)
+	}
+}
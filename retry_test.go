@@ -0,0 +1,83 @@
+package checkpoint
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RunUntil(t *testing.T) {
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	var bodies []string
+	conf := Post(http.NewServeMux(), "/jobs/1", "payload")
+	conf.URLPattern = "POST /jobs/{id}"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("done"))
+	}
+
+	start := time.Now()
+	result, err := conf.RunUntil(ctx, func(r *Result) bool { return r.StatusCode == http.StatusOK },
+		RetryInterval(5*time.Millisecond), RetryBackoff(2))
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "done", result.Body.String())
+	assert.Equal(t, []string{"payload", "payload", "payload"}, bodies)
+	// Waits of 5ms then 10ms.
+	assert.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond)
+
+	calls.Store(0)
+	result, err = conf.RunUntil(ctx, func(r *Result) bool { return r.StatusCode == http.StatusOK },
+		RetryAttempts(2), RetryInterval(time.Millisecond))
+	assert.EqualError(t, err, "condition not met after 2 attempts, statuses: 503, 503")
+	assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+
+	calls.Store(0)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = conf.RunUntil(cancelled, func(r *Result) bool { return false })
+	assert.ErrorIs(t, err, context.Canceled)
+
+	conf = Init(http.NewServeMux()).WithPath("/jobs").WithBodyReader(http.NoBody)
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+	_, err = conf.RunUntil(ctx, func(r *Result) bool { return true })
+	assert.EqualError(t, err, "RunUntil cannot send a body set by WithBodyReader again, use WithBodyBytes")
+
+	// The files of a multipart body are sent again on every attempt.
+	var uploads []string
+	conf = Init(http.NewServeMux()).WithPath("/uploads")
+	conf.Method = http.MethodPost
+	conf.WithMultipart().AddField("kind", "report").AddFile("file", "report.csv", strings.NewReader("a,b\n1,2\n"))
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		b, _ := io.ReadAll(f)
+		uploads = append(uploads, r.FormValue("kind")+": "+string(b))
+		if len(uploads) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}
+	result, err = conf.RunUntil(ctx, func(r *Result) bool { return r.StatusCode == http.StatusOK }, RetryInterval(time.Millisecond))
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, []string{"report: a,b\n1,2\n", "report: a,b\n1,2\n"}, uploads)
+}
@@ -1,30 +1,503 @@
 package checkpoint
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"reflect"
+	"strings"
+	"sync"
 
+	"github.com/dimfeld/httptreemux/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/go-chi/chi/v5"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gorilla/mux"
+	"github.com/julienschmidt/httprouter"
+	"github.com/labstack/echo/v4"
+	"github.com/valyala/fasthttp"
 )
 
 type Router interface {
 	ServeHTTP(http.ResponseWriter, *http.Request)
 	Handle(string, http.Handler)
 }
+
+// MethodRouter is implemented by routers that register handlers per HTTP method.
+// When TestConfig.Method is set, Run registers the route through HandleMethod.
+// The stdlib ServeMux and chi are supported without implementing it; any other
+// router falls back to Handle and serves the route for every method.
+type MethodRouter interface {
+	Router
+	HandleMethod(method, pattern string, handler http.Handler)
+}
+
+// HostRouter is implemented by routers that can scope a route to a host
+// pattern. Run registers through HandleHost when TestConfig.Host is set; the
+// stdlib ServeMux is supported for hosts without placeholders.
+type HostRouter interface {
+	Router
+	HandleHost(method, host, pattern string, handler http.Handler)
+}
+
+// routeMethods lists the methods a route is registered for on routers that
+// cannot register a handler for any method at once.
+var routeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// registrations records the routes Run has registered on each router, so that
+// a pattern is registered only once per router however many times Run is called.
+var registrations = struct {
+	sync.Mutex
+	routes      map[routeKey]bool
+	middlewares map[any]bool // by routerID
+}{routes: make(map[routeKey]bool), middlewares: make(map[any]bool)}
+
+// route describes where a handler is registered. An empty method registers
+// the pattern for any method and an empty host for any host.
+type route struct {
+	method  string
+	host    string
+	pattern string
+}
+
+type routeKey struct {
+	router any // see routerID
+	route
+}
+
+// routerID returns what identifies r in registrations, and whether it can.
+// A RouterAdapter stands for the router it wraps, as Init wraps the router
+// anew for every config.
+func routerID(r Router) (any, bool) {
+	if a, ok := r.(*RouterAdapter); ok && a != nil && a.Mux != nil {
+		return a.Mux, reflect.TypeOf(a.Mux).Comparable()
+	}
+	return r, reflect.TypeOf(r).Comparable()
+}
+
+type routeHandlerKey struct{}
+
+// register registers a dispatching handler for rt on r unless it was
+// registered before. The dispatching handler serves the handler carried by the
+// request context (see withRouteHandlers), so every Run reaches its own handler
+// and middlewares.
+func register(r Router, rt route, handler http.Handler) {
+	if id, ok := routerID(r); ok {
+		key := routeKey{router: id, route: rt}
+		registrations.Lock()
+		defer registrations.Unlock()
+		if registrations.routes[key] {
+			return
+		}
+		registrations.routes[key] = true
+	}
+
+	dispatch := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		handlers, _ := req.Context().Value(routeHandlerKey{}).(map[route]http.Handler)
+		if h, ok := handlers[rt]; ok {
+			h.ServeHTTP(w, req)
+			return
+		}
+		handler.ServeHTTP(w, req)
+	})
+	handle(r, rt, dispatch)
+}
+
+// handle registers handler on r for rt as precisely as r allows. Routers that
+// cannot scope routes by host or method serve the route for any of them.
+func handle(r Router, rt route, handler http.Handler) {
+	if rt.host != "" {
+		switch m := r.(type) {
+		case HostRouter:
+			m.HandleHost(rt.method, rt.host, rt.pattern, handler)
+			return
+		case *http.ServeMux:
+			// ServeMux matches the host of a request without its port.
+			host := rt.host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			rt.pattern = host + rt.pattern
+		}
+	}
+	if rt.method == "" {
+		r.Handle(rt.pattern, handler)
+		return
+	}
+
+	switch m := r.(type) {
+	case MethodRouter:
+		m.HandleMethod(rt.method, rt.pattern, handler)
+	case *http.ServeMux:
+		m.Handle(rt.method+" "+rt.pattern, handler)
+	case chi.Router:
+		m.Method(rt.method, rt.pattern, handler)
+	default:
+		r.Handle(rt.pattern, handler)
+	}
+}
+
+type routerMiddlewaresKey struct{}
+
+// useRouterMiddlewares attaches a dispatching middleware to r the first time r
+// is seen, and reports whether r carries it. The dispatching middleware runs
+// the router middlewares carried by the request context (see
+// withRouterMiddlewares). chi only accepts middleware before its first route,
+// so a chi router that already has routes cannot carry it.
+func useRouterMiddlewares(r Router) bool {
+	id, ok := routerID(r)
+	if !ok {
+		return false
+	}
+	registrations.Lock()
+	defer registrations.Unlock()
+	if attached, seen := registrations.middlewares[id]; seen {
+		return attached
+	}
+
+	dispatch := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			middlewares, _ := req.Context().Value(routerMiddlewaresKey{}).([]func(http.Handler) http.Handler)
+			chain(next, middlewares).ServeHTTP(w, req)
+		})
+	}
+	attached := true
+	switch m := r.(type) {
+	case chi.Router:
+		if attached = len(m.Routes()) == 0; attached {
+			m.Use(dispatch)
+		}
+	case *RouterAdapter:
+		attached = m.use(dispatch)
+	default:
+		attached = false
+	}
+	registrations.middlewares[id] = attached
+	return attached
+}
+
+// withRouterMiddlewares returns a copy of req whose context carries the
+// middlewares the router's dispatching middleware should run.
+func withRouterMiddlewares(req *http.Request, middlewares []func(http.Handler) http.Handler) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), routerMiddlewaresKey{}, middlewares))
+}
+
+// splitPattern splits a ServeMux style "METHOD /path" pattern into its method
+// and path. The method is empty when the pattern does not start with one.
+func splitPattern(pattern string) (method, path string) {
+	method, path, found := strings.Cut(pattern, " ")
+	if !found || method == "" || strings.Contains(method, "/") {
+		return "", pattern
+	}
+	return method, strings.TrimLeft(path, " ")
+}
+
+// mount returns the chi router mounted on r at prefix, so routes can be
+// registered on it and served through r as the application does. When nothing
+// is mounted at prefix yet, a new chi router is mounted there.
+func mount(r Router, prefix string) (Router, error) {
+	parent, ok := r.(chi.Router)
+	if !ok {
+		return nil, fmt.Errorf("%w: mounting requires a chi router, got %T", ErrUnsupportedRouter, r)
+	}
+
+	registrations.Lock()
+	defer registrations.Unlock()
+	mountPattern := strings.TrimSuffix(prefix, "/") + "/*"
+	for _, route := range parent.Routes() {
+		if route.Pattern != mountPattern {
+			continue
+		}
+		if sub, ok := route.SubRoutes.(chi.Router); ok {
+			return sub, nil
+		}
+		return nil, fmt.Errorf("the handler mounted at %s is not a chi router", prefix)
+	}
+	sub := chi.NewRouter()
+	parent.Mount(prefix, sub)
+	return sub, nil
+}
+
+// withRouteHandlers returns a copy of req whose context carries the handlers
+// the routes registered by register should serve. Routes missing from handlers
+// serve the handler they were registered with.
+func withRouteHandlers(req *http.Request, handlers map[route]http.Handler) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), routeHandlerKey{}, handlers))
+}
+
+// ErrUnsupportedRouter is returned when a RouterAdapter wraps a type it cannot adapt.
+var ErrUnsupportedRouter = errors.New("unsupported router type")
+
 type RouterAdapter struct {
 	Mux interface{}
 }
 
+// NewRouterAdapter wraps m in a RouterAdapter. It returns an error wrapping
+// ErrUnsupportedRouter when m is not one of the supported routers.
+func NewRouterAdapter(m any) (*RouterAdapter, error) {
+	g := &RouterAdapter{Mux: m}
+	if err := g.validate(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// validate reports whether the wrapped Mux is a supported router.
+func (g *RouterAdapter) validate() error {
+	switch g.Mux.(type) {
+	case *mux.Router, *gin.Engine, *echo.Echo, *httprouter.Router, *fiber.App, *httptreemux.ContextMux:
+		return nil
+	}
+	return fmt.Errorf("%w: %T", ErrUnsupportedRouter, g.Mux)
+}
+
 func (g *RouterAdapter) Handle(pattern string, handler http.Handler) {
 	switch m := g.Mux.(type) {
 	case *mux.Router:
 		m.Handle(pattern, handler)
+	case *gin.Engine:
+		m.Any(colonPattern(pattern), ginHandler(handler))
+	case *echo.Echo:
+		m.Any(colonPattern(pattern), echoHandler(handler))
+	case *httprouter.Router:
+		for _, method := range routeMethods {
+			m.Handler(method, colonPattern(pattern), handler)
+		}
+	case *fiber.App:
+		m.All(colonPattern(pattern), fiberHandler(handler))
+	case *httptreemux.ContextMux:
+		for _, method := range routeMethods {
+			m.ContextGroup.Handler(method, colonPattern(pattern), handler)
+		}
 	}
 }
+
+func (g *RouterAdapter) HandleMethod(method, pattern string, handler http.Handler) {
+	switch m := g.Mux.(type) {
+	case *mux.Router:
+		m.Handle(pattern, handler).Methods(method)
+	case *gin.Engine:
+		m.Handle(method, colonPattern(pattern), ginHandler(handler))
+	case *echo.Echo:
+		m.Add(method, colonPattern(pattern), echoHandler(handler))
+	case *httprouter.Router:
+		m.Handler(method, colonPattern(pattern), handler)
+	case *fiber.App:
+		m.Add(method, colonPattern(pattern), fiberHandler(handler))
+	case *httptreemux.ContextMux:
+		m.ContextGroup.Handler(method, colonPattern(pattern), handler)
+	}
+}
+func (g *RouterAdapter) HandleHost(method, host, pattern string, handler http.Handler) {
+	switch m := g.Mux.(type) {
+	case *mux.Router:
+		route := m.Host(host).Path(pattern)
+		if method != "" {
+			route.Methods(method)
+		}
+		route.Handler(handler)
+	default:
+		handle(g, route{method: method, pattern: pattern}, handler)
+	}
+}
+
+// use attaches middleware to the wrapped router and reports whether the
+// router supports router-level middleware.
+func (g *RouterAdapter) use(middleware func(http.Handler) http.Handler) bool {
+	switch m := g.Mux.(type) {
+	case *mux.Router:
+		m.Use(middleware)
+	case *gin.Engine:
+		m.Use(ginMiddleware(middleware))
+	case *echo.Echo:
+		m.Use(echo.WrapMiddleware(middleware))
+	default:
+		return false
+	}
+	return true
+}
+
 func (g *RouterAdapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch m := g.Mux.(type) {
 	case *mux.Router:
 		m.ServeHTTP(w, r)
+	case *gin.Engine:
+		m.ServeHTTP(w, r)
+	case *echo.Echo:
+		m.ServeHTTP(w, r)
+	case *httprouter.Router:
+		m.ServeHTTP(w, r)
+	case *fiber.App:
+		serveFiber(m, w, r)
+	case *httptreemux.ContextMux:
+		m.ServeHTTP(w, r)
 	default:
 		http.Error(w, "Unsupported Router type", http.StatusInternalServerError)
 	}
 }
+
+type ginContextKey struct{}
+
+// ginHandler wraps handler so that the *gin.Context of the matched route
+// travels with the request and can be recovered by GinHandler.
+func ginHandler(handler http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), ginContextKey{}, c)
+		handler.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+	}
+}
+
+// ginMiddleware converts a net/http middleware into a gin middleware. The gin
+// chain is aborted when the middleware does not call the next handler.
+func ginMiddleware(middleware func(http.Handler) http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		called := false
+		middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			c.Request = r
+			c.Next()
+		})).ServeHTTP(c.Writer, c.Request)
+		if !called {
+			c.Abort()
+		}
+	}
+}
+
+// GinContext returns the *gin.Context of the route that matched r, or nil when
+// the request was not routed by a *gin.Engine wrapped in a RouterAdapter.
+func GinContext(r *http.Request) *gin.Context {
+	c, _ := r.Context().Value(ginContextKey{}).(*gin.Context)
+	return c
+}
+
+// GinHandler converts a gin.HandlerFunc into a RouteFunc. The handler receives
+// the gin context of the matched route, so c.Param works as it does in production.
+func GinHandler(h gin.HandlerFunc) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := GinContext(r)
+		if c == nil {
+			http.Error(w, "gin context not found: the router is not a *gin.Engine", http.StatusInternalServerError)
+			return
+		}
+		c.Request = r
+		h(c)
+	}
+}
+
+type echoContextKey struct{}
+
+// echoHandler wraps handler with echo.WrapHandler, making the echo.Context of
+// the matched route available through EchoContext.
+func echoHandler(handler http.Handler) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := context.WithValue(c.Request().Context(), echoContextKey{}, c)
+		c.SetRequest(c.Request().WithContext(ctx))
+		return echo.WrapHandler(handler)(c)
+	}
+}
+
+// EchoContext returns the echo.Context of the route that matched r, or nil when
+// the request was not routed by an *echo.Echo wrapped in a RouterAdapter.
+func EchoContext(r *http.Request) echo.Context {
+	c, _ := r.Context().Value(echoContextKey{}).(echo.Context)
+	return c
+}
+
+type fiberContextKey struct{}
+
+type fiberRequestContextKey struct{}
+
+// serveFiber serves r through app. The request is converted to fasthttp the
+// way adaptor.FiberApp does, except that the context of r is kept as a user
+// value so that fiberHandler can hand it on to the checkpoint handler.
+func serveFiber(app *fiber.App, w http.ResponseWriter, r *http.Request) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	if r.Body != nil {
+		n, err := io.Copy(req.BodyWriter(), r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		req.Header.SetContentLength(int(n))
+	}
+	req.Header.SetMethod(r.Method)
+	req.SetRequestURI(r.URL.RequestURI())
+	req.SetHost(r.Host)
+	for key, values := range r.Header {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+	remoteAddr, _ := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+
+	var fctx fasthttp.RequestCtx
+	if remoteAddr != nil {
+		fctx.Init(req, remoteAddr, nil)
+	} else {
+		fctx.Init(req, nil, nil)
+	}
+	fctx.SetUserValue(fiberRequestContextKey{}, r.Context())
+	app.Handler()(&fctx)
+
+	fctx.Response.Header.VisitAll(func(k, v []byte) {
+		w.Header().Add(string(k), string(v))
+	})
+	w.WriteHeader(fctx.Response.StatusCode())
+	_, _ = w.Write(fctx.Response.Body())
+}
+
+// fiberHandler wraps handler with adaptor.HTTPHandler. The handler receives a
+// request carrying the context Run built it with, plus the *fiber.Ctx of the
+// matched route for FiberContext.
+func fiberHandler(handler http.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		parent, ok := c.Context().UserValue(fiberRequestContextKey{}).(context.Context)
+		return adaptor.HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.Context(r.Context())
+			if ok {
+				ctx = parent
+			}
+			handler.ServeHTTP(w, r.WithContext(context.WithValue(ctx, fiberContextKey{}, c)))
+		}))(c)
+	}
+}
+
+// FiberContext returns the *fiber.Ctx of the route that matched r, or nil when
+// the request was not routed by a *fiber.App wrapped in a RouterAdapter. It is
+// only valid while the handler runs.
+func FiberContext(r *http.Request) *fiber.Ctx {
+	c, _ := r.Context().Value(fiberContextKey{}).(*fiber.Ctx)
+	return c
+}
+
+// colonPattern converts {name} style placeholders into the :name syntax used
+// by gin, echo, fiber and similar routers. A trailing {name...} wildcard becomes *name.
+// Patterns already written in :name syntax are passed through unchanged.
+func colonPattern(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, s := range segments {
+		if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+			continue
+		}
+		name := s[1 : len(s)-1]
+		if strings.HasSuffix(name, "...") {
+			segments[i] = "*" + strings.TrimSuffix(name, "...")
+			continue
+		}
+		segments[i] = ":" + name
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,51 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// StatusError is returned by RunAs when the response status is not 2xx.
+type StatusError struct {
+	StatusCode int
+	Result     *Result
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected status " + describeStatus(e.Result)
+}
+
+// describeStatus renders the status of r with its text and the start of the
+// body, so the error message of a surprise 500 shows right away.
+func describeStatus(r *Result) string {
+	msg := fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode))
+	if body := strings.TrimSpace(r.Body.String()); body != "" {
+		const limit = 200
+		if len(body) > limit {
+			body = body[:limit] + "..."
+		}
+		msg += ": " + body
+	}
+	return msg
+}
+
+// RunAs runs tc and decodes the JSON response body into a T. A response with
+// a status other than 2xx is not decoded and is reported as a *StatusError.
+// The Result is returned whenever the request was served, for further checks.
+func RunAs[T any](ctx context.Context, tc *TestConfig) (T, *Result, error) {
+	var v T
+	result, err := tc.Run(ctx)
+	if err != nil {
+		return v, nil, err
+	}
+	if !result.IsSuccess() {
+		return v, result, &StatusError{StatusCode: result.StatusCode, Result: result}
+	}
+	if err := json.Unmarshal(result.Body, &v); err != nil {
+		return v, result, fmt.Errorf("decoding the JSON body into %T: %w", v, err)
+	}
+	return v, result, nil
+}
@@ -0,0 +1,53 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RunAs(t *testing.T) {
+	ctx := context.Background()
+	respond := func(status int, body string) *TestConfig {
+		conf := Init(http.NewServeMux())
+		conf.URLPattern = "/items"
+		conf.Path = "/items"
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}
+		return conf
+	}
+
+	type item struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	got, result, err := RunAs[item](ctx, respond(http.StatusOK, `{"id":1,"name":"widget"}`))
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, item{ID: 1, Name: "widget"}, got)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+
+	list, _, err := RunAs[[]map[string]any](ctx, respond(http.StatusCreated, `[{"id":1},{"id":2,"tags":["a"]}]`))
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, []map[string]any{{"id": 1.0}, {"id": 2.0, "tags": []any{"a"}}}, list)
+
+	// Error statuses are not decoded.
+	_, result, err = RunAs[item](ctx, respond(http.StatusNotFound, `{"error":"no such item"}`))
+	var statusErr *StatusError
+	if assert.True(t, errors.As(err, &statusErr)) {
+		assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+		assert.Same(t, result, statusErr.Result)
+	}
+	assert.EqualError(t, err, `unexpected status 404 Not Found: {"error":"no such item"}`)
+
+	_, _, err = RunAs[item](ctx, respond(http.StatusOK, `not json`))
+	assert.ErrorContains(t, err, "decoding the JSON body into checkpoint.item: invalid character")
+}
@@ -0,0 +1,205 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Extractor captures a value from the Result of a scenario step.
+type Extractor func(r *Result) (string, error)
+
+// FromJSONPath extracts the value at path in the JSON body, see
+// Result.JSONPath. Strings are taken as they are and other values as JSON.
+func FromJSONPath(path string) Extractor {
+	return func(r *Result) (string, error) {
+		v, err := r.JSONPath(path)
+		if err != nil {
+			return "", err
+		}
+		switch v := v.(type) {
+		case string:
+			return v, nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+}
+
+// FromHeader extracts the value of a response header.
+func FromHeader(key string) Extractor {
+	return func(r *Result) (string, error) {
+		values := r.HeaderValues(key)
+		if len(values) == 0 {
+			return "", fmt.Errorf("header %s is missing", key)
+		}
+		return values[0], nil
+	}
+}
+
+// FromCookie extracts the value of a cookie set by the response.
+func FromCookie(name string) Extractor {
+	return func(r *Result) (string, error) {
+		for _, c := range r.Cookies() {
+			if c.Name == name {
+				return c.Value, nil
+			}
+		}
+		return "", fmt.Errorf("cookie %s is not set", name)
+	}
+}
+
+// Step is one request of a Scenario. The values captured by Extract are
+// available to the steps that follow.
+type Step struct {
+	Name    string
+	Config  *TestConfig
+	Extract map[string]Extractor
+}
+
+// Scenario runs requests in order, feeding values captured from one response
+// into the requests that follow, as in logging in and then using the token.
+// Header values, Path, query and form values and the body of a step may
+// reference captured values as template placeholders such as
+// "Bearer {{.token}}".
+type Scenario struct {
+	Steps []Step
+	// Session, when set, carries the cookies set by each step to the steps
+	// that follow.
+	Session *Session
+}
+
+// NewScenario returns a Scenario running steps.
+func NewScenario(steps ...Step) *Scenario {
+	return &Scenario{Steps: steps}
+}
+
+// Step adds a step running tc and capturing the values of extract.
+func (s *Scenario) Step(name string, tc *TestConfig, extract map[string]Extractor) *Scenario {
+	s.Steps = append(s.Steps, Step{Name: name, Config: tc, Extract: extract})
+	return s
+}
+
+// Run runs the steps in order, each on a clone of its config, and returns the
+// results of the steps run and the captured values. It stops at the first
+// step failing to run or to capture a value, naming it in the error. Bodies
+// set as readers are consumed, so such a scenario runs once.
+func (s *Scenario) Run(ctx context.Context) ([]*Result, map[string]string, error) {
+	vars := make(map[string]string)
+	var results []*Result
+	for i, step := range s.Steps {
+		name := fmt.Sprintf("step %d", i+1)
+		if step.Name != "" {
+			name += " (" + step.Name + ")"
+		}
+		if step.Config == nil {
+			return results, vars, fmt.Errorf("%s: config cannot be nil", name)
+		}
+		conf, err := step.Config.expand(vars)
+		if err != nil {
+			return results, vars, fmt.Errorf("%s: %w", name, err)
+		}
+		if s.Session != nil {
+			conf.WithSession(s.Session)
+		}
+		result, err := conf.Run(ctx)
+		if result != nil {
+			results = append(results, result)
+		}
+		if err != nil {
+			return results, vars, fmt.Errorf("%s: %w", name, err)
+		}
+		for _, key := range slices.Sorted(maps.Keys(step.Extract)) {
+			value, err := step.Extract[key](result)
+			if err != nil {
+				return results, vars, fmt.Errorf("%s: extracting %s: %w", name, key, err)
+			}
+			vars[key] = value
+		}
+	}
+	return results, vars, nil
+}
+
+// expand returns a clone of tc with the placeholders of its headers, path,
+// query, form and body replaced by vars.
+func (tc *TestConfig) expand(vars map[string]string) (*TestConfig, error) {
+	c := tc.Clone()
+	var err error
+	expand := func(s string) string {
+		if err != nil || !strings.Contains(s, "{{") {
+			return s
+		}
+		var expanded string
+		expanded, err = expandTemplate(s, vars)
+		return expanded
+	}
+
+	c.Path = expand(c.Path)
+	for key, value := range c.Headers {
+		c.Headers[key] = expand(value)
+	}
+	for _, values := range []map[string][]string{c.added, c.query, c.form} {
+		for _, vs := range values {
+			for i := range vs {
+				vs[i] = expand(vs[i])
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c.body != nil && c.body.source != "WithForm" && c.body.source != "WithMultipart":
+		r, err := c.body.open(c)
+		if err != nil {
+			return nil, err
+		}
+		if rc, ok := r.(io.Closer); ok {
+			defer rc.Close()
+		}
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		expanded := []byte(expand(string(b)))
+		body := *c.body
+		body.open = func(*TestConfig) (io.Reader, error) {
+			return bytes.NewReader(expanded), nil
+		}
+		c.body = &body
+	case c.Body != nil:
+		b, err := io.ReadAll(c.Body)
+		if err != nil {
+			return nil, err
+		}
+		c.SetBodyString(expand(string(b)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// expandTemplate executes s as a text/template on vars, a map. A placeholder
+// without a value is an error.
+func expandTemplate(s string, vars any) (string, error) {
+	t, err := template.New("").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
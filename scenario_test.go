@@ -0,0 +1,106 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loginRouter() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		var creds struct{ User, Password string }
+		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil || creds.Password != "secret" {
+			http.Error(w, "bad credentials", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "req-1")
+		_, _ = w.Write([]byte(`{"token":"tok-` + creds.User + `","user":{"id":42}}`))
+	})
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok-ann" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("user " + r.PathValue("id") + " via " + r.Header.Get("X-Trace")))
+	})
+	return mux
+}
+
+func Test_ScenarioRun(t *testing.T) {
+	ctx := context.Background()
+	mux := loginRouter()
+
+	login := Init(mux).WithMethod(http.MethodPost).WithPath("/login")
+	login.UseExistingRoutes = true
+	login.WithJSONBody(map[string]string{"user": "ann", "password": "secret"})
+
+	me := Init(mux).WithPath("/users/{{.id}}").WithBearerToken("{{.token}}").
+		WithHeaders(Header("X-Trace", "{{.request}}"))
+	me.UseExistingRoutes = true
+
+	results, vars, err := NewScenario().
+		Step("login", login, map[string]Extractor{
+			"token":   FromJSONPath("$.token"),
+			"id":      FromJSONPath("$.user.id"),
+			"request": FromHeader("X-Request-Id"),
+		}).
+		Step("me", me, nil).
+		Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, map[string]string{"token": "tok-ann", "id": "42", "request": "req-1"}, vars)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, http.StatusOK, results[1].StatusCode)
+		assert.Equal(t, "user 42 via req-1", results[1].Body.String())
+	}
+	// The configs of the steps are left as they were.
+	assert.Equal(t, "/users/{{.id}}", me.Path)
+}
+
+func Test_ScenarioRunFailures(t *testing.T) {
+	ctx := context.Background()
+	mux := loginRouter()
+
+	login := Post(mux, "/login", `{"User":"ann","Password":"wrong"}`)
+	login.UseExistingRoutes = true
+	me := Get(mux, "/users/1").WithBearerToken("{{.token}}")
+	me.UseExistingRoutes = true
+
+	tc := []struct {
+		steps   []Step
+		results int
+		wantErr string
+	}{
+		{
+			steps: []Step{
+				{Name: "login", Config: login, Extract: map[string]Extractor{"token": FromJSONPath("$.token")}},
+				{Name: "me", Config: me},
+			},
+			results: 1,
+			wantErr: "step 1 (login): extracting token: body is not JSON: invalid character 'b' looking for beginning of value",
+		},
+		{
+			steps:   []Step{{Config: me}},
+			wantErr: `step 1: template: :1:9: executing "" at <.token>: map has no entry for key "token"`,
+		},
+		{
+			steps: []Step{
+				{Config: Get(mux, "/users/1").WithHeaders(Header("X-Request-Id", "x"))},
+				{Name: "cookie", Config: Get(mux, "/users/1"), Extract: map[string]Extractor{"session": FromCookie("session")}},
+			},
+			wantErr: "step 1: handler cannot be nil",
+		},
+	}
+
+	for i, c := range tc {
+		results, _, err := NewScenario(c.steps...).Run(ctx)
+		assert.EqualError(t, err, c.wantErr, "failure in the test case: %d", i)
+		assert.Len(t, results, c.results, "failure in the test case: %d", i)
+	}
+}
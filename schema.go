@@ -0,0 +1,118 @@
+package checkpoint
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"mime"
+	"slices"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// SchemaViolation is one constraint of a JSON Schema that the response body
+// violates. Pointer is the JSON pointer of the offending value, "" for the
+// whole body.
+type SchemaViolation struct {
+	Pointer string
+	Message string
+}
+
+// SchemaError is returned by Run, along with the Result, when the response
+// body does not conform to the schema set with WithResponseSchema. It lists
+// every violation rather than the first one, ordered by pointer.
+type SchemaError struct {
+	StatusCode int
+	Violations []SchemaViolation
+}
+
+func (e *SchemaError) Error() string {
+	lines := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		pointer := v.Pointer
+		if pointer == "" {
+			pointer = "/"
+		}
+		lines[i] = pointer + ": " + v.Message
+	}
+	return fmt.Sprintf("response body with status %d does not match the schema:\n%s",
+		e.StatusCode, strings.Join(lines, "\n"))
+}
+
+// WithResponseSchema makes Run validate JSON response bodies against schema,
+// a JSON Schema document. Responses whose Content-Type is not JSON are not
+// validated, nor are the statuses given to WithSchemaSkippedStatus. A schema
+// that does not compile is reported by Run.
+func (tc *TestConfig) WithResponseSchema(schema []byte) *TestConfig {
+	tc.schema, tc.schemaErr = nil, nil
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("schema.json", bytes.NewReader(schema)); err != nil {
+		tc.schemaErr = fmt.Errorf("invalid response schema: %w", err)
+		return tc
+	}
+	compiled, err := compiler.Compile("schema.json")
+	if err != nil {
+		tc.schemaErr = fmt.Errorf("invalid response schema: %w", err)
+		return tc
+	}
+	tc.schema = compiled
+	return tc
+}
+
+// WithSchemaSkippedStatus leaves responses with the given statuses out of the
+// schema validation, as error bodies often have a shape of their own.
+func (tc *TestConfig) WithSchemaSkippedStatus(statuses ...int) *TestConfig {
+	tc.schemaSkip = append(tc.schemaSkip, statuses...)
+	return tc
+}
+
+// validateSchema checks the body of r against the response schema, if any.
+func (tc *TestConfig) validateSchema(r *Result) error {
+	if tc.schema == nil || slices.Contains(tc.schemaSkip, r.StatusCode) {
+		return nil
+	}
+	if !isJSON(r.RawHeaders.Get("Content-Type")) {
+		return nil
+	}
+	body, err := decodeJSON(r.Body)
+	if err != nil {
+		return &SchemaError{
+			StatusCode: r.StatusCode,
+			Violations: []SchemaViolation{{Message: fmt.Sprintf("body is not JSON: %v", err)}},
+		}
+	}
+	err = tc.schema.Validate(body)
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+	schemaErr := &SchemaError{StatusCode: r.StatusCode}
+	collectViolations(validationErr, &schemaErr.Violations)
+	slices.SortFunc(schemaErr.Violations, func(a, b SchemaViolation) int {
+		return cmp.Or(strings.Compare(a.Pointer, b.Pointer), strings.Compare(a.Message, b.Message))
+	})
+	return schemaErr
+}
+
+// collectViolations appends the leaves of the validation error tree, which
+// name the violated constraints; the inner nodes only group them.
+func collectViolations(err *jsonschema.ValidationError, violations *[]SchemaViolation) {
+	if len(err.Causes) == 0 {
+		*violations = append(*violations, SchemaViolation{Pointer: err.InstanceLocation, Message: err.Message})
+		return
+	}
+	for _, cause := range err.Causes {
+		collectViolations(cause, violations)
+	}
+}
+
+// isJSON reports whether contentType is application/json or a +json type.
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
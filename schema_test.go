@@ -0,0 +1,117 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const userSchema = `{
+	"type": "object",
+	"required": ["id", "name", "email"],
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"name": {"type": "string", "minLength": 1},
+		"email": {"type": "string"},
+		"roles": {"type": "array", "items": {"enum": ["admin", "user"]}}
+	}
+}`
+
+func Test_RunResponseSchema(t *testing.T) {
+	ctx := context.Background()
+
+	tc := []struct {
+		status      int
+		contentType string
+		body        string
+		skip        []int
+		wantErr     string
+		violations  []SchemaViolation
+	}{
+		{
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body:        `{"id":1,"name":"ann","email":"ann@example.com","roles":["admin"]}`,
+		},
+		{
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"id":0,"name":"","roles":["root"]}`,
+			wantErr: "response body with status 200 does not match the schema:\n" +
+				"/: missing properties: 'email'\n" +
+				"/id: must be >= 1 but found 0\n" +
+				"/name: length must be >= 1, but got 0\n" +
+				`/roles/0: value must be one of "admin", "user"`,
+			violations: []SchemaViolation{
+				{Pointer: "", Message: "missing properties: 'email'"},
+				{Pointer: "/id", Message: "must be >= 1 but found 0"},
+				{Pointer: "/name", Message: "length must be >= 1, but got 0"},
+				{Pointer: "/roles/0", Message: `value must be one of "admin", "user"`},
+			},
+		},
+		{
+			status:      http.StatusNotFound,
+			contentType: "application/problem+json",
+			body:        `{"title":"not found"}`,
+			skip:        []int{http.StatusNotFound},
+		},
+		{
+			status:      http.StatusNotFound,
+			contentType: "application/problem+json",
+			body:        `{"title":"not found"}`,
+			wantErr: "response body with status 404 does not match the schema:\n" +
+				"/: missing properties: 'id', 'name', 'email'",
+		},
+		{
+			status:      http.StatusOK,
+			contentType: "text/plain",
+			body:        `not json`,
+		},
+		{
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"id":`,
+			wantErr:     "response body with status 200 does not match the schema:\n/: body is not JSON: unexpected EOF",
+		},
+	}
+
+	for i, c := range tc {
+		conf := Init(http.NewServeMux())
+		conf.URLPattern = "/users/{id}"
+		conf.Path = "/users/1"
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", c.contentType)
+			w.WriteHeader(c.status)
+			_, _ = w.Write([]byte(c.body))
+		}
+		conf.WithResponseSchema([]byte(userSchema)).WithSchemaSkippedStatus(c.skip...)
+
+		result, err := conf.Run(ctx)
+		assert.NotNil(t, result, "failure in the test case: %d", i)
+		if c.wantErr == "" {
+			assert.NoError(t, err, "failure in the test case: %d", i)
+			continue
+		}
+		assert.EqualError(t, err, c.wantErr, "failure in the test case: %d", i)
+		var schemaErr *SchemaError
+		if assert.True(t, errors.As(err, &schemaErr), "failure in the test case: %d", i) && c.violations != nil {
+			assert.Equal(t, c.violations, schemaErr.Violations, "failure in the test case: %d", i)
+		}
+	}
+}
+
+func Test_RunResponseSchemaInvalid(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/users"
+	conf.Path = "/users"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+	conf.WithResponseSchema([]byte(`{"type": 1}`))
+
+	_, err := conf.Run(ctx)
+	assert.ErrorContains(t, err, "invalid response schema")
+}
@@ -0,0 +1,281 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ServerOption tunes RunServer.
+type ServerOption func(*serverConfig)
+
+type serverConfig struct {
+	http2     bool
+	configure []func(*http.Client)
+}
+
+// ConfigureClient lets configure change the client RunServer sends the
+// request with, as to set a Timeout or a CheckRedirect policy. It is called
+// after the client was set up for the server and FollowRedirects.
+func ConfigureClient(configure func(*http.Client)) ServerOption {
+	return func(c *serverConfig) {
+		c.configure = append(c.configure, configure)
+	}
+}
+
+// ServeHTTP2 makes RunServer serve over TLS with HTTP/2 enabled, so the
+// request is sent over HTTP/2, as RunServerTLS does.
+func ServeHTTP2() ServerOption {
+	return func(c *serverConfig) {
+		c.http2 = true
+	}
+}
+
+// RunServer runs the test as Run does, but through a real HTTP server started
+// with httptest.NewServer and a real http.Client, so the response goes
+// through the whole HTTP plumbing: the server sets Date, chunks bodies of
+// unknown length, and http.ResponseController and client timeouts behave as
+// in production. The server is closed before RunServer returns. The Result has
+// no Recorder, and Timeout and PropagatePanics do not apply: a panicking
+// handler breaks the connection as it would in production.
+func (tc *TestConfig) RunServer(ctx context.Context, opts ...ServerOption) (*Result, error) {
+	ctx, end := tc.startTrace(ctx)
+	result, err := tc.runServer(ctx, opts)
+	end(result, err)
+	return result, err
+}
+
+func (tc *TestConfig) runServer(ctx context.Context, opts []ServerOption) (*Result, error) {
+	start := time.Now()
+	var sc serverConfig
+	for _, opt := range opts {
+		opt(&sc)
+	}
+	serve, method, path, urlPattern, err := tc.serverHandler("RunServer")
+	if err != nil {
+		return nil, err
+	}
+
+	srv := httptest.NewUnstartedServer(serve)
+	if sc.http2 {
+		srv.EnableHTTP2 = true
+		srv.StartTLS()
+	} else {
+		srv.Start()
+	}
+	defer srv.Close()
+
+	client := srv.Client()
+	// Responses are reported as written, compressed or not.
+	if t, ok := client.Transport.(*http.Transport); ok {
+		t.DisableCompression = true
+	}
+	return tc.afterRun(tc.send(ctx, start, client, srv.URL, method, path, urlPattern, sc.configure))
+}
+
+// serverHandler validates tc and returns the handler a test server serves it
+// with, along with the method, path and route pattern of the request. caller
+// names the method serving it in errors.
+func (tc *TestConfig) serverHandler(caller string) (serve http.Handler, method, path, urlPattern string, err error) {
+	if err := tc.Validate(); err != nil {
+		return nil, "", "", "", err
+	}
+	if tc.BaseURL != "" {
+		return nil, "", "", "", fmt.Errorf("%s serves the handler itself, use Run to send the request to BaseURL", caller)
+	}
+	if path, err = tc.requestPath(); err != nil {
+		return nil, "", "", "", err
+	}
+	routeMethod, urlPattern := tc.routePattern(path)
+	method = tc.requestMethod(routeMethod)
+	attached := useRouterMiddlewares(tc.Router)
+	if serve, urlPattern, err = tc.handler(routeMethod, urlPattern, attached); err != nil {
+		return nil, "", "", "", err
+	}
+	if attached {
+		inner := serve
+		serve = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			inner.ServeHTTP(w, withRouterMiddlewares(r, tc.RouterMiddlewares))
+		})
+	}
+	return serve, method, path, urlPattern, nil
+}
+
+// send sends the request of tc with client to the server at baseURL and
+// builds the Result from its response. The redirect policy of client follows
+// FollowRedirects, then configure is called on it.
+func (tc *TestConfig) send(ctx context.Context, start time.Time, client *http.Client, baseURL, method, path, urlPattern string, configure []func(*http.Client)) (*Result, error) {
+	body, contentType, err := tc.openBody()
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := body.(io.Closer); ok && tc.body != nil {
+		defer c.Close()
+	}
+	req, err := tc.newRequest(ctx, method, path, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	if err := tc.beforeRun(req); err != nil {
+		return nil, err
+	}
+	requestDump := dumpRequest(req)
+	matched := recordCoverage(tc.Router, req)
+	contractPattern := strings.TrimSuffix(tc.MountAt, "/") + urlPattern
+	if tc.UseExistingRoutes && matched != "" {
+		contractPattern = matched
+	}
+	var contractErr error
+	if tc.contract != nil {
+		contractErr = tc.contract.CheckRequest(req, contractPattern)
+	}
+	// Send the request to the server, keeping Host as the handler sees it.
+	if err := rebaseRequest(req, baseURL); err != nil {
+		return nil, err
+	}
+
+	var redirects []Redirect
+	client.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		if tc.FollowRedirects <= 0 {
+			return http.ErrUseLastResponse
+		}
+		if len(via) > tc.FollowRedirects {
+			return fmt.Errorf("stopped after %d redirects", tc.FollowRedirects)
+		}
+		redirects = append(redirects, Redirect{StatusCode: next.Response.StatusCode, Location: next.URL})
+		return nil
+	}
+	for _, configure := range configure {
+		configure(client)
+	}
+
+	serveStart := time.Now()
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	readStart := time.Now()
+
+	rawBody, written, truncated, err := readBody(response.Body, tc.sink, tc.MaxBodyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("reading the response body: %w", err)
+	}
+	bodyBytes := rawBody
+	if !tc.DisableDecompression && !truncated {
+		if bodyBytes, err = decodeBody(response.Header.Values("Content-Encoding"), rawBody); err != nil {
+			return nil, err
+		}
+	}
+	if tc.session != nil {
+		tc.session.store(req, response.Header)
+	}
+
+	responseHeaders := make(map[string]string)
+	for key, values := range response.Header {
+		if len(values) > 0 {
+			responseHeaders[key] = strings.Join(values, ", ")
+		}
+	}
+	var location *url.URL
+	if loc := response.Header.Get("Location"); loc != "" {
+		location, _ = response.Request.URL.Parse(loc)
+	}
+	end := time.Now()
+
+	result := &Result{
+		Headers:      responseHeaders,
+		RawHeaders:   response.Header,
+		Trailers:     response.Trailer,
+		StatusCode:   response.StatusCode,
+		Body:         bodyBytes,
+		RawBody:      rawBody,
+		BytesWritten: written,
+		Truncated:    truncated,
+		Location:     location,
+		Redirects:    redirects,
+		Pattern:      matched,
+		Proto:        response.Proto,
+		TLS:          response.TLS,
+		Duration:     end.Sub(start),
+		Timing: Timing{
+			Setup:    serveStart.Sub(start),
+			Handler:  readStart.Sub(serveStart),
+			BodyRead: end.Sub(readStart),
+		},
+		response:    response,
+		requestDump: requestDump,
+		request:     req,
+	}
+	if tc.contract != nil {
+		contractErr = errors.Join(contractErr, tc.contract.CheckResponse(req, contractPattern, result))
+	}
+	if err := errors.Join(contractErr, tc.validateSchema(result)); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// RunServerTLS runs the test as RunServer does, over TLS and HTTP/2, for
+// handlers depending on r.TLS or r.ProtoMajor. The client trusts the
+// certificate of the test server. The Result tells the negotiated protocol in
+// Proto and the connection state in TLS.
+func (tc *TestConfig) RunServerTLS(ctx context.Context, opts ...ServerOption) (*Result, error) {
+	return tc.RunServer(ctx, append([]ServerOption{ServeHTTP2()}, opts...)...)
+}
+
+// readBody reads r, copying it to sink when set, and keeps at most limit bytes
+// of it when limit is positive. It returns the size of the whole body.
+func readBody(r io.Reader, sink io.Writer, limit int64) ([]byte, int64, bool, error) {
+	var buf bytes.Buffer
+	p := make([]byte, 32*1024)
+	var written int64
+	for {
+		n, err := r.Read(p)
+		if n > 0 {
+			written += int64(n)
+			if sink != nil {
+				if _, err := sink.Write(p[:n]); err != nil {
+					return nil, 0, false, fmt.Errorf("writing to the sink: %w", err)
+				}
+			}
+			keep := p[:n]
+			if limit > 0 {
+				keep = keep[:min(int64(n), limit-int64(buf.Len()))]
+			}
+			buf.Write(keep)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, false, err
+		}
+	}
+	return buf.Bytes(), written, limit > 0 && written > limit, nil
+}
+
+// rebaseRequest points req at the server at baseURL, prefixing its path with
+// the path of baseURL. The Host of req is kept.
+func rebaseRequest(req *http.Request, baseURL string) error {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+	req.URL.Scheme, req.URL.Host = base.Scheme, base.Host
+	if prefix := strings.TrimSuffix(base.Path, "/"); prefix != "" {
+		req.URL.Path = prefix + req.URL.Path
+		if req.URL.RawPath != "" {
+			req.URL.RawPath = strings.TrimSuffix(base.EscapedPath(), "/") + req.URL.RawPath
+		}
+	}
+	req.RequestURI = ""
+	return nil
+}
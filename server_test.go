@@ -0,0 +1,150 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RunServer(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Post(http.NewServeMux(), "/server/items", `{"name":"widget"}`).
+		WithHeaders(Header("X-Request-Id", "42"))
+	conf.URLPattern = "POST /server/items"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", r.Header.Get("X-Request-Id"))
+		w.WriteHeader(http.StatusCreated)
+		// Flushing before the end makes the server chunk the body.
+		_, _ = fmt.Fprint(w, `{"id":1,`)
+		_ = http.NewResponseController(w).Flush()
+		_, _ = fmt.Fprint(w, `"proto":"`+r.Proto+`"}`)
+	}
+
+	result, err := conf.RunServer(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusCreated, result.StatusCode)
+	assert.JSONEq(t, `{"id":1,"proto":"HTTP/1.1"}`, result.Body.String())
+	assert.Equal(t, "42", result.Headers["X-Request-Id"])
+	assert.NotEmpty(t, result.Headers["Date"])
+	assert.Equal(t, []string{"chunked"}, result.Raw().TransferEncoding)
+	assert.Nil(t, result.Recorder())
+
+	// The recorder sets neither Date nor Transfer-Encoding.
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Empty(t, result.Headers["Date"])
+	assert.Empty(t, result.Raw().TransferEncoding)
+
+	result, err = conf.RunServer(ctx, ServeHTTP2())
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.JSONEq(t, `{"id":1,"proto":"HTTP/2.0"}`, result.Body.String())
+}
+
+func Test_RunServerClient(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Get(http.NewServeMux(), "/server/slow")
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}
+	_, err := conf.RunServer(ctx, ConfigureClient(func(c *http.Client) {
+		c.Timeout = 20 * time.Millisecond
+	}))
+	assert.ErrorContains(t, err, "Client.Timeout exceeded")
+
+	mux := http.NewServeMux()
+	conf = Get(mux, "/server/old").
+		WithExtraRoute("/server/new", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("moved"))
+		})
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/server/new", http.StatusFound)
+	}
+	result, err := conf.RunServer(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusFound, result.StatusCode)
+	assert.Equal(t, "/server/new", result.Location.Path)
+
+	conf.FollowRedirects = 1
+	result, err = conf.RunServer(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "moved", result.Body.String())
+	assert.Len(t, result.Redirects, 1)
+	assert.Equal(t, http.StatusFound, result.Redirects[0].StatusCode)
+
+	result, err = conf.RunServer(ctx, ConfigureClient(func(c *http.Client) {
+		c.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	}))
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusFound, result.StatusCode)
+
+	conf = Get(http.NewServeMux(), "/server/large")
+	conf.MaxBodyBytes = 4
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("a", 10)))
+	}
+	result, err = conf.RunServer(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "aaaa", result.Body.String())
+	assert.True(t, result.Truncated)
+	assert.Equal(t, int64(10), result.BytesWritten)
+}
+
+func Test_RunServerTLS(t *testing.T) {
+	ctx := context.Background()
+
+	var protoMajor int
+	var sawTLS bool
+	conf := Get(http.NewServeMux(), "/server/tls")
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		protoMajor, sawTLS = r.ProtoMajor, r.TLS != nil
+		if r.ProtoMajor == 2 {
+			w.Header().Set("Alt-Svc", "clear")
+		}
+	}
+
+	result, err := conf.RunServerTLS(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, 2, protoMajor)
+	assert.True(t, sawTLS)
+	assert.Equal(t, "HTTP/2.0", result.Proto)
+	assert.Equal(t, "clear", result.Headers["Alt-Svc"])
+	if assert.NotNil(t, result.TLS) {
+		assert.Equal(t, "h2", result.TLS.NegotiatedProtocol)
+	}
+
+	result, err = conf.RunServer(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, 1, protoMajor)
+	assert.False(t, sawTLS)
+	assert.Equal(t, "HTTP/1.1", result.Proto)
+	assert.Nil(t, result.TLS)
+}
@@ -0,0 +1,66 @@
+package checkpoint
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+)
+
+// sessionHost is the host cookies are stored under when the request has none.
+const sessionHost = "checkpoint.test"
+
+// Session keeps the cookies set by responses and sends them with the requests
+// that follow, as an http.Client with a cookie jar does. Path, Domain,
+// Secure, Expires and Max-Age are respected. A Session is used by the configs
+// given to WithSession and by the steps of a Scenario with Session set, and
+// is safe for concurrent use.
+type Session struct {
+	Jar http.CookieJar
+}
+
+// NewSession returns a Session with an empty cookie jar.
+func NewSession() *Session {
+	jar, _ := cookiejar.New(nil)
+	return &Session{Jar: jar}
+}
+
+// WithSession makes Run send the cookies of s and store in s the cookies set
+// by the response, including the responses to followed redirects.
+func (tc *TestConfig) WithSession(s *Session) *TestConfig {
+	tc.session = s
+	return tc
+}
+
+// Cookies returns the cookies s would send with a request to path, for a
+// config without Host.
+func (s *Session) Cookies(path string) []*http.Cookie {
+	return s.Jar.Cookies(&url.URL{Scheme: "https", Host: sessionHost, Path: path})
+}
+
+// attach adds the cookies of s for req to req.
+func (s *Session) attach(req *http.Request) {
+	for _, c := range s.Jar.Cookies(sessionURL(req)) {
+		req.AddCookie(c)
+	}
+}
+
+// store keeps the cookies set by a response to req.
+func (s *Session) store(req *http.Request, header http.Header) {
+	cookies := (&http.Response{Header: header}).Cookies()
+	if len(cookies) > 0 {
+		s.Jar.SetCookies(sessionURL(req), cookies)
+	}
+}
+
+// sessionURL returns the URL the cookies of req are stored under: its path on
+// its host, or on sessionHost, over https when it uses TLS.
+func sessionURL(req *http.Request) *url.URL {
+	u := &url.URL{Scheme: "http", Host: req.Host, Path: req.URL.Path}
+	if u.Host == "" {
+		u.Host = sessionHost
+	}
+	if req.TLS != nil {
+		u.Scheme = "https"
+	}
+	return u
+}
@@ -0,0 +1,102 @@
+package checkpoint
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sessionRouter() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/", HttpOnly: true})
+		http.SetCookie(w, &http.Cookie{Name: "admin", Value: "yes", Path: "/admin"})
+		http.SetCookie(w, &http.Cookie{Name: "tls", Value: "only", Path: "/", Secure: true})
+	})
+	mux.HandleFunc("GET /account", func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("session")
+		if err != nil || c.Value != "s1" {
+			http.Error(w, "not logged in", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(r.Header.Get("Cookie")))
+	})
+	mux.HandleFunc("POST /logout", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Path: "/", MaxAge: -1})
+	})
+	mux.HandleFunc("GET /go", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+		http.Redirect(w, r, "/account", http.StatusFound)
+	})
+	return mux
+}
+
+func Test_RunWithSession(t *testing.T) {
+	ctx := context.Background()
+	mux := sessionRouter()
+	session := NewSession()
+
+	run := func(method, path string) *Result {
+		t.Helper()
+		conf := Init(mux).WithMethod(method).WithPath(path).WithSession(session)
+		conf.UseExistingRoutes = true
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		return result
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, run(http.MethodGet, "/account").StatusCode)
+
+	run(http.MethodPost, "/login")
+	result := run(http.MethodGet, "/account")
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	// Cookies for other paths and secure cookies stay out of plain requests.
+	assert.Equal(t, "session=s1", result.Body.String())
+	assert.Len(t, session.Cookies("/admin/users"), 3)
+
+	run(http.MethodPost, "/logout")
+	assert.Equal(t, http.StatusUnauthorized, run(http.MethodGet, "/account").StatusCode)
+	assert.Len(t, session.Cookies("/"), 1)
+}
+
+func Test_RunWithSessionRedirect(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Get(sessionRouter(), "/go").WithSession(NewSession())
+	conf.UseExistingRoutes = true
+	conf.FollowRedirects = 1
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "session=s1", result.Body.String())
+}
+
+func Test_ScenarioSession(t *testing.T) {
+	ctx := context.Background()
+	mux := sessionRouter()
+
+	step := func(method, path string) *TestConfig {
+		conf := Init(mux).WithMethod(method).WithPath(path)
+		conf.UseExistingRoutes = true
+		return conf
+	}
+	scenario := NewScenario().
+		Step("login", step(http.MethodPost, "/login"), nil).
+		Step("account", step(http.MethodGet, "/account"), nil).
+		Step("logout", step(http.MethodPost, "/logout"), nil).
+		Step("rejected", step(http.MethodGet, "/account"), nil)
+	scenario.Session = NewSession()
+
+	results, _, err := scenario.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, results[1].StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, results[3].StatusCode)
+}
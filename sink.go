@@ -0,0 +1,83 @@
+package checkpoint
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+)
+
+// WithResponseWriterSink copies the response body to w as the handler writes
+// it. Combined with MaxBodyBytes, large responses can be checked without
+// holding them in memory. Bodies of followed redirects are copied too.
+func (tc *TestConfig) WithResponseWriterSink(w io.Writer) *TestConfig {
+	tc.sink = w
+	return tc
+}
+
+// limitedRecorder records a response like its ResponseRecorder, but copies
+// the body to a sink and keeps at most max bytes of it.
+type limitedRecorder struct {
+	*httptest.ResponseRecorder
+	sink        io.Writer
+	max         int64
+	written     int64
+	truncated   bool
+	wroteHeader bool
+	err         error // the first error writing to the sink
+}
+
+// recorder returns the writer the handler writes to: rr itself, or rr behind
+// a limitedRecorder when a sink or MaxBodyBytes is set.
+func (tc *TestConfig) recorder(rr *httptest.ResponseRecorder) http.ResponseWriter {
+	if tc.sink == nil && tc.MaxBodyBytes <= 0 {
+		return rr
+	}
+	return &limitedRecorder{ResponseRecorder: rr, sink: tc.sink, max: tc.MaxBodyBytes}
+}
+
+func (l *limitedRecorder) Write(p []byte) (int, error) {
+	if !l.wroteHeader {
+		// Sniff the content type as the recorder does on its first write.
+		if l.Header().Get("Content-Type") == "" && l.Header().Get("Transfer-Encoding") == "" {
+			l.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		l.WriteHeader(http.StatusOK)
+	}
+	l.written += int64(len(p))
+	if l.sink != nil && l.err == nil {
+		if _, err := l.sink.Write(p); err != nil {
+			l.err = err
+		}
+	}
+	keep := p
+	if l.max > 0 {
+		room := max(l.max-int64(l.Body.Len()), 0)
+		if int64(len(keep)) > room {
+			keep = keep[:room]
+			l.truncated = true
+		}
+	}
+	l.Body.Write(keep)
+	return len(p), nil
+}
+
+func (l *limitedRecorder) WriteHeader(code int) {
+	if code >= 200 {
+		l.wroteHeader = true
+	}
+	l.ResponseRecorder.WriteHeader(code)
+}
+
+func (l *limitedRecorder) Flush() {
+	l.wroteHeader = true
+	l.ResponseRecorder.Flush()
+}
+
+func (l *limitedRecorder) WriteString(s string) (int, error) {
+	return l.Write([]byte(s))
+}
+
+// Unwrap lets http.ResponseController reach the recorder.
+func (l *limitedRecorder) Unwrap() http.ResponseWriter {
+	return l.ResponseRecorder
+}
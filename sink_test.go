@@ -0,0 +1,120 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+func Test_RunWithResponseWriterSink(t *testing.T) {
+	ctx := context.Background()
+	const size = 50 << 20
+
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/export"
+	conf.Path = "/export"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		chunk := bytes.Repeat([]byte("a"), 32<<10)
+		for written := 0; written < size; written += len(chunk) {
+			_, _ = w.Write(chunk)
+		}
+	}
+	sink := &countingWriter{}
+	conf.WithResponseWriterSink(sink)
+	conf.MaxBodyBytes = 1 << 20
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	runtime.ReadMemStats(&after)
+
+	assert.Equal(t, int64(size), sink.n)
+	assert.Equal(t, int64(size), result.BytesWritten)
+	assert.True(t, result.Truncated)
+	assert.Len(t, result.Body, 1<<20)
+	assert.Equal(t, "text/plain; charset=utf-8", result.Headers["Content-Type"])
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(16<<20), "the body was buffered")
+
+	// A body under the cap is kept whole.
+	conf = Init(http.NewServeMux())
+	conf.URLPattern = "/small"
+	conf.Path = "/small"
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("small"))
+	}
+	conf.MaxBodyBytes = 1 << 20
+
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, http.StatusAccepted, result.StatusCode)
+	assert.Equal(t, "small", result.Body.String())
+	assert.Equal(t, int64(5), result.BytesWritten)
+	assert.False(t, result.Truncated)
+}
+
+func Test_RunMaxBodyBytes(t *testing.T) {
+	ctx := context.Background()
+	const size = 100 << 20
+
+	conf := Get(http.NewServeMux(), "/export")
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		chunk := bytes.Repeat([]byte("a"), 64<<10)
+		for written := 0; written < size; written += len(chunk) {
+			_, _ = w.Write(chunk)
+		}
+	}
+	conf.MaxBodyBytes = 1 << 20
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, int64(size), result.BytesWritten)
+	assert.True(t, result.Truncated)
+	assert.Len(t, result.Body, 1<<20)
+	assert.Len(t, result.RawBody, 1<<20)
+
+	// A handler writing forever is cut by the timeout, and the partial result
+	// reports the truncation as well.
+	conf = Get(http.NewServeMux(), "/forever")
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		chunk := bytes.Repeat([]byte("a"), 64<<10)
+		for {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}
+	conf.MaxBodyBytes = 1 << 20
+	conf.Timeout = 50 * time.Millisecond
+
+	result, err = conf.Run(ctx)
+	assert.ErrorIs(t, err, ErrTimeout)
+	if assert.NotNil(t, result) {
+		assert.True(t, result.Truncated)
+		assert.Len(t, result.Body, 1<<20)
+		assert.Greater(t, result.BytesWritten, int64(1<<20))
+	}
+}
@@ -0,0 +1,422 @@
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is a case described in a spec file, see LoadSpecs.
+type Spec struct {
+	Name string
+	// Handler names the handler of the route in the registry given to
+	// RunSpecs. Without it the request is served by the routes already
+	// registered on the router.
+	Handler string
+	Method  string
+	Path    string
+	Pattern string
+	Headers map[string]string
+	// Body is the request body, and BodyFile the path of a file sent
+	// instead, relative to the spec file.
+	Body     string
+	BodyFile string
+	// WantStatus, WantHeaders and WantBody are the expected response, each
+	// unchecked when empty. WantBody is compared as JSON when the response is
+	// JSON, and read from the expect.bodyFile of the spec when it has one.
+	WantStatus  int
+	WantHeaders map[string]string
+	WantBody    string
+	// Source is the file and line the spec starts at, as "specs/items.yaml:12".
+	Source string
+}
+
+// specFields lists the fields of a spec, and of its expect field, with the
+// YAML kind they take; anyNode accepts a scalar or, for bodies, JSON written
+// as YAML.
+var specFields = map[string]specKind{
+	"name":     stringNode,
+	"handler":  stringNode,
+	"method":   stringNode,
+	"path":     stringNode,
+	"pattern":  stringNode,
+	"headers":  mapNode,
+	"body":     anyNode,
+	"bodyFile": stringNode,
+	"expect":   expectNode,
+}
+
+var expectFields = map[string]specKind{
+	"status":   intNode,
+	"headers":  mapNode,
+	"body":     anyNode,
+	"bodyFile": stringNode,
+}
+
+type specKind int
+
+const (
+	stringNode specKind = iota
+	intNode
+	mapNode
+	anyNode
+	expectNode
+)
+
+// LoadSpecs reads the cases described in the YAML or JSON file at path, or in
+// the .yaml, .yml and .json files of the directory at path, in name order.
+// A file holds a list of cases such as:
+//
+//	# specs/items.yaml
+//	- name: get item
+//	  handler: getItem
+//	  pattern: GET /items/{id}
+//	  path: /items/1
+//	  headers: {Accept: application/json}
+//	  expect:
+//	    status: 200
+//	    headers: {Content-Type: application/json}
+//	    body: {"id": 1}
+//
+// Every file is checked before any case runs: errors list each problem with
+// its file and line, as "specs/items.yaml:4: unknown field \"stauts\"".
+func LoadSpecs(path string) ([]Spec, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading specs: %w", err)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading specs: %w", err)
+		}
+		files = nil
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+	}
+
+	var specs []Spec
+	var errs []error
+	names := make(map[string]string)
+	for _, file := range files {
+		loaded, err := loadSpecFile(file)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, s := range loaded {
+			if first, ok := names[s.Name]; ok {
+				errs = append(errs, fmt.Errorf("%s: duplicate case name %q, first used at %s", s.Source, s.Name, first))
+				continue
+			}
+			names[s.Name] = s.Source
+			specs = append(specs, s)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return specs, nil
+}
+
+// loadSpecFile reads and checks the specs of one file.
+func loadSpecFile(file string) ([]Spec, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("loading specs: %w", err)
+	}
+	// YAML reports JSON syntax errors at the start of the enclosing value.
+	var syntaxErr *json.SyntaxError
+	if filepath.Ext(file) == ".json" && errors.As(json.Unmarshal(data, new(any)), &syntaxErr) {
+		line := 1 + bytes.Count(data[:syntaxErr.Offset], []byte("\n"))
+		return nil, fmt.Errorf("%s:%d: %w", file, line, syntaxErr)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		// Move the line of "yaml: line 3: ..." next to the file name.
+		if line, msg, ok := strings.Cut(strings.TrimPrefix(err.Error(), "yaml: line "), ": "); ok {
+			if _, convErr := strconv.Atoi(line); convErr == nil {
+				return nil, fmt.Errorf("%s:%s: %s", file, line, msg)
+			}
+		}
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("%s: no cases", file)
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("%s:%d: expected a list of cases", file, root.Line)
+	}
+
+	l := &specLoader{file: file, dir: filepath.Dir(file)}
+	var specs []Spec
+	for _, node := range root.Content {
+		if s, ok := l.spec(node); ok {
+			specs = append(specs, s)
+		}
+	}
+	if len(l.errs) > 0 {
+		return specs, errors.Join(l.errs...)
+	}
+	return specs, nil
+}
+
+// specLoader builds the specs of a file, collecting the problems found.
+type specLoader struct {
+	file string
+	dir  string
+	errs []error
+}
+
+func (l *specLoader) errorf(node *yaml.Node, format string, args ...any) {
+	l.errs = append(l.errs, fmt.Errorf("%s:%d: %s", l.file, node.Line, fmt.Sprintf(format, args...)))
+}
+
+// spec builds the spec described by node, reporting false when it is invalid.
+func (l *specLoader) spec(node *yaml.Node) (Spec, bool) {
+	s := Spec{Source: fmt.Sprintf("%s:%d", l.file, node.Line)}
+	before := len(l.errs)
+	var body, bodyFile, expect *yaml.Node
+	l.fields(node, specFields, func(key string, value *yaml.Node) {
+		switch key {
+		case "name":
+			s.Name = value.Value
+		case "handler":
+			s.Handler = value.Value
+		case "method":
+			s.Method = value.Value
+			if s.Method != strings.ToUpper(s.Method) || strings.ContainsAny(s.Method, " \t/") {
+				l.errorf(value, "invalid method %q", s.Method)
+			}
+		case "path":
+			s.Path = value.Value
+			if !strings.HasPrefix(s.Path, "/") {
+				l.errorf(value, "path %q does not start with /", s.Path)
+			}
+		case "pattern":
+			s.Pattern = value.Value
+		case "headers":
+			s.Headers = l.headers(value)
+		case "body":
+			body, s.Body = value, l.body(value)
+		case "bodyFile":
+			bodyFile, s.BodyFile = value, l.relativeFile(value)
+		case "expect":
+			expect = value
+		}
+	})
+	if node.Kind == yaml.MappingNode {
+		if s.Name == "" {
+			l.errorf(node, "missing field \"name\"")
+		}
+		if s.Path == "" {
+			l.errorf(node, "missing field \"path\"")
+		}
+	}
+	if body != nil && bodyFile != nil {
+		l.errorf(bodyFile, "body and bodyFile both set the request body")
+	}
+	if expect != nil {
+		l.expect(expect, &s)
+	}
+	return s, len(l.errs) == before
+}
+
+// expect fills the expected response of s from node.
+func (l *specLoader) expect(node *yaml.Node, s *Spec) {
+	var body, bodyFile *yaml.Node
+	l.fields(node, expectFields, func(key string, value *yaml.Node) {
+		switch key {
+		case "status":
+			s.WantStatus, _ = strconv.Atoi(value.Value)
+			if s.WantStatus < 100 || s.WantStatus > 599 {
+				l.errorf(value, "invalid status %d", s.WantStatus)
+			}
+		case "headers":
+			s.WantHeaders = l.headers(value)
+		case "body":
+			body, s.WantBody = value, l.body(value)
+		case "bodyFile":
+			bodyFile = value
+			if path := l.relativeFile(value); path != "" {
+				b, err := os.ReadFile(path)
+				if err != nil {
+					l.errorf(value, "reading the expected body: %v", err)
+				}
+				s.WantBody = string(b)
+			}
+		}
+	})
+	if body != nil && bodyFile != nil {
+		l.errorf(bodyFile, "body and bodyFile both set the expected body")
+	}
+}
+
+// fields calls set with each field of the mapping node after checking it is
+// known and of the kind listed in fields.
+func (l *specLoader) fields(node *yaml.Node, fields map[string]specKind, set func(key string, value *yaml.Node)) {
+	if node.Kind != yaml.MappingNode {
+		l.errorf(node, "expected a mapping, got %s", describeNode(node))
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		kind, ok := fields[key.Value]
+		if !ok {
+			known := slices.Sorted(maps.Keys(fields))
+			l.errorf(key, "unknown field %q, the fields are: %s", key.Value, strings.Join(known, ", "))
+			continue
+		}
+		if value.Tag == "!!null" {
+			continue
+		}
+		switch {
+		case kind == stringNode && value.Kind != yaml.ScalarNode,
+			kind == intNode && value.Tag != "!!int",
+			kind == mapNode && value.Kind != yaml.MappingNode,
+			kind == expectNode && value.Kind != yaml.MappingNode:
+			l.errorf(value, "field %q: expected %s, got %s", key.Value, kindNames[kind], describeNode(value))
+			continue
+		}
+		set(key.Value, value)
+	}
+}
+
+var kindNames = map[specKind]string{
+	stringNode: "a string",
+	intNode:    "an integer",
+	mapNode:    "a mapping",
+	expectNode: "a mapping",
+}
+
+// describeNode names the kind of node for error messages.
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	return strconv.Quote(node.Value)
+}
+
+// headers returns the headers of a mapping of scalars.
+func (l *specLoader) headers(node *yaml.Node) map[string]string {
+	headers := make(map[string]string)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if value.Kind != yaml.ScalarNode {
+			l.errorf(value, "header %q: expected a string, got %s", key.Value, describeNode(value))
+			continue
+		}
+		headers[key.Value] = value.Value
+	}
+	return headers
+}
+
+// body returns a body given as a scalar as is, and one given as a mapping or
+// a list encoded as JSON.
+func (l *specLoader) body(node *yaml.Node) string {
+	if node.Kind == yaml.ScalarNode {
+		return node.Value
+	}
+	var v any
+	if err := node.Decode(&v); err != nil {
+		l.errorf(node, "decoding the body: %v", err)
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		l.errorf(node, "encoding the body as JSON: %v", err)
+		return ""
+	}
+	return string(b)
+}
+
+// relativeFile returns the path of a file named relative to the spec file, after
+// checking it exists.
+func (l *specLoader) relativeFile(node *yaml.Node) string {
+	path := node.Value
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(l.dir, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		l.errorf(node, "%v", err)
+		return ""
+	}
+	return path
+}
+
+// RunSpecs runs specs, as loaded by LoadSpecs, against router the way RunAll
+// runs cases: as subtests named after them with a *testing.T. The handler of
+// each spec is looked up by name in handlers; a spec naming an unknown
+// handler fails without running.
+func RunSpecs(t TB, router any, handlers map[string]http.HandlerFunc, specs []Spec) {
+	t.Helper()
+	cases := make([]Case, 0, len(specs))
+	for _, s := range specs {
+		handler, ok := handlers[s.Handler]
+		if s.Handler != "" && !ok {
+			t.Errorf("%s: case %s: unknown handler %q", s.Source, s.Name, s.Handler)
+			continue
+		}
+		cases = append(cases, s.toCase(handler))
+	}
+	Init(router).RunAll(t, cases)
+}
+
+// toCase returns the Case running s with handler, nil to use the routes
+// registered on the router.
+func (s Spec) toCase(handler http.HandlerFunc) Case {
+	c := Case{
+		Name:       s.Name,
+		Method:     s.Method,
+		Path:       s.Path,
+		URLPattern: s.Pattern,
+		Headers:    s.Headers,
+		Body:       s.Body,
+		WantStatus: s.WantStatus,
+		WantBody:   s.WantBody,
+		Setup: func(tc *TestConfig) {
+			if tc.URLPattern == "" {
+				tc.URLPattern = s.Path
+			}
+			if handler != nil {
+				tc.RouteFunc = handler
+			} else {
+				tc.UseExistingRoutes = true
+			}
+			if s.BodyFile != "" {
+				tc.WithBodyFromFile(s.BodyFile)
+			}
+		},
+	}
+	if len(s.WantHeaders) > 0 {
+		c.BodyAssert = func(t TB, r *Result) {
+			t.Helper()
+			e := r.Expect(t)
+			for _, key := range slices.Sorted(maps.Keys(s.WantHeaders)) {
+				e.Header(key, s.WantHeaders[key])
+			}
+			e.Done()
+		}
+	}
+	return c
+}
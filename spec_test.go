@@ -0,0 +1,117 @@
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// specHandlers is the registry the spec files in testdata refer to.
+var specHandlers = map[string]http.HandlerFunc{
+	"getItem": func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "0" {
+			http.Error(w, "no such item", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": r.PathValue("id"), "name": "widget"})
+	},
+	"createItem": func(w http.ResponseWriter, r *http.Request) {
+		var item map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		item["id"] = "2"
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/items/2")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(item)
+	},
+	"upload": func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(body)) != r.ContentLength {
+			http.Error(w, "incomplete upload", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, "%x", sha256.Sum256(body))
+	},
+}
+
+// specRouter has the routes the specs without a handler are served by.
+func specRouter() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	return mux
+}
+
+func Test_RunSpecs(t *testing.T) {
+	specs, err := LoadSpecs("testdata/specs")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	names := make([]string, len(specs))
+	for i, s := range specs {
+		names[i] = s.Name
+	}
+	assert.Equal(t, []string{"health", "get item", "missing item", "create item", "upload"}, names)
+	assert.Equal(t, "testdata/specs/items.yaml:2", specs[1].Source)
+	assert.Equal(t, `{"id":"2","name":"gadget"}`, specs[3].WantBody)
+
+	RunSpecs(t, specRouter(), specHandlers, specs)
+}
+
+func Test_RunSpecsReportsFailures(t *testing.T) {
+	specs, err := LoadSpecs("testdata/specs_failing.yaml")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	fake := &fakeT{}
+	RunSpecs(fake, specRouter(), specHandlers, specs)
+	if assert.Len(t, fake.errors, 3) {
+		assert.Equal(t, `testdata/specs_failing.yaml:17: case unknown handler: unknown handler "deleteItem"`, fake.errors[0])
+		assert.True(t, strings.HasPrefix(fake.errors[1], "case wrong status: 1 expectation failed for GET /items/0:\n"+
+			"1. expected status 200, got 404\n"), fake.errors[1])
+		assert.True(t, strings.HasPrefix(fake.errors[2], "case wrong header: 1 expectation failed for GET /items/1:\n"+
+			"1. expected header Content-Type: text/plain, got application/json\n"), fake.errors[2])
+	}
+}
+
+func Test_LoadSpecsErrors(t *testing.T) {
+	_, err := LoadSpecs("testdata/specs_invalid")
+	if !assert.Error(t, err) {
+		return
+	}
+	assert.Equal(t, strings.Join([]string{
+		"testdata/specs_invalid/broken.json:3: invalid character ']' after object key:value pair",
+		`testdata/specs_invalid/items.yaml:5: unknown field "stauts", the fields are: body, bodyFile, headers, status`,
+		`testdata/specs_invalid/items.yaml:8: path "items/1" does not start with /`,
+		`testdata/specs_invalid/items.yaml:9: invalid method "get"`,
+		`testdata/specs_invalid/items.yaml:10: field "headers": expected a mapping, got a list`,
+		`testdata/specs_invalid/items.yaml:12: field "status": expected an integer, got "200"`,
+		"testdata/specs_invalid/items.yaml:17: stat testdata/specs_invalid/bodies/missing.json: no such file or directory",
+		`testdata/specs_invalid/items.yaml:14: missing field "name"`,
+		"testdata/specs_invalid/items.yaml:17: body and bodyFile both set the request body",
+		"testdata/specs_invalid/tabs.yaml:4: found character that cannot start any token",
+	}, "\n"), err.Error())
+
+	_, err = LoadSpecs("testdata/missing")
+	assert.EqualError(t, err, "loading specs: stat testdata/missing: no such file or directory")
+
+	// A single file can be loaded as well.
+	specs, err := LoadSpecs("testdata/specs/items.yaml")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Len(t, specs, 4)
+}
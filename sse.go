@@ -0,0 +1,190 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSEEvent is an event of a Server-Sent Events stream, see Result.SSEEvents.
+type SSEEvent struct {
+	Name  string        // the event field, "message" when the event has none
+	Data  string        // the data fields, joined with newlines
+	ID    string        // the last event ID, carried over from earlier events
+	Retry time.Duration // the reconnection time set by the event, zero if none
+}
+
+// SSEEvents parses the body as a Server-Sent Events stream, following the
+// event stream format of the HTML specification: comments are skipped, data
+// fields are joined with newlines, and an event is dispatched on a blank line
+// when it has data. An event the body ends in the middle of is left out, as a
+// browser would. It fails when the Content-Type is set to another media type
+// than text/event-stream.
+func (r *Result) SSEEvents() ([]SSEEvent, error) {
+	if ct := r.RawHeaders.Get("Content-Type"); ct != "" {
+		if mediaType, _, err := mime.ParseMediaType(ct); err != nil || mediaType != "text/event-stream" {
+			return nil, fmt.Errorf("not an event stream: Content-Type %q", ct)
+		}
+	}
+
+	var events []SSEEvent
+	var (
+		name, id string
+		data     strings.Builder
+		hasData  bool
+		retry    time.Duration
+	)
+	body := bytes.TrimPrefix(r.Body, []byte("\xEF\xBB\xBF"))
+	for _, line := range splitSSELines(string(body)) {
+		if line == "" {
+			if hasData {
+				event := SSEEvent{Name: name, Data: data.String(), ID: id, Retry: retry}
+				if event.Name == "" {
+					event.Name = "message"
+				}
+				events = append(events, event)
+			}
+			name, retry, hasData = "", 0, false
+			data.Reset()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			name = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				id = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return events, nil
+}
+
+// splitSSELines splits s at the line endings of an event stream: CRLF, LF
+// and CR. The part following the last line ending, an unterminated line, is
+// left out.
+func splitSSELines(s string) []string {
+	var lines []string
+	for {
+		i := strings.IndexAny(s, "\r\n")
+		if i < 0 {
+			return lines
+		}
+		lines = append(lines, s[:i])
+		if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+			i++
+		}
+		s = s[i+1:]
+	}
+}
+
+// StopAfterEvents ends the request once the handler wrote n Server-Sent
+// Events, for handlers streaming until the client goes away: the context of
+// the request is cancelled and later writes fail, so the body holds the n
+// events. The handler must return once its request context is done, as it
+// does when a real client disconnects; set Timeout to bound a handler that
+// does not. Only in-process runs stop early.
+func (tc *TestConfig) StopAfterEvents(n int) *TestConfig {
+	tc.stopEvents = n
+	return tc
+}
+
+// limitEvents returns w behind an eventLimiter calling stop when StopAfterEvents
+// is set, w otherwise.
+func (tc *TestConfig) limitEvents(w http.ResponseWriter, stop context.CancelFunc) http.ResponseWriter {
+	if tc.stopEvents <= 0 {
+		return w
+	}
+	return &eventLimiter{ResponseWriter: w, remaining: tc.stopEvents, stop: stop}
+}
+
+// errEventsReached is returned by the writes following the last event
+// expected by StopAfterEvents.
+var errEventsReached = errors.New("checkpoint: stopped after the expected events")
+
+// eventLimiter counts the events written through it and stops the request
+// once the expected number is reached.
+type eventLimiter struct {
+	http.ResponseWriter
+	remaining int
+	stop      context.CancelFunc
+	hasData   bool // the event being written has a data field
+	line      []byte
+	cr        bool // the last byte was a CR
+}
+
+func (e *eventLimiter) Write(p []byte) (int, error) {
+	if e.remaining == 0 {
+		return 0, errEventsReached
+	}
+	for i, c := range p {
+		if c == '\n' && e.cr {
+			e.cr = false
+			continue
+		}
+		e.cr = c == '\r'
+		if c != '\r' && c != '\n' {
+			e.line = append(e.line, c)
+			continue
+		}
+		if e.endLine() {
+			// Keep what follows the last event out of the body.
+			if _, err := e.ResponseWriter.Write(p[:i+1]); err != nil {
+				return 0, err
+			}
+			e.stop()
+			return len(p), nil
+		}
+	}
+	return e.ResponseWriter.Write(p)
+}
+
+// endLine handles the end of a line, reporting whether it dispatched the
+// last expected event.
+func (e *eventLimiter) endLine() bool {
+	line := string(e.line)
+	e.line = e.line[:0]
+	switch {
+	case line == "":
+		if e.hasData {
+			e.hasData = false
+			e.remaining--
+			return e.remaining == 0
+		}
+	case line == "data" || strings.HasPrefix(line, "data:"):
+		e.hasData = true
+	}
+	return false
+}
+
+func (e *eventLimiter) Flush() {
+	if f, ok := e.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the recorder.
+func (e *eventLimiter) Unwrap() http.ResponseWriter {
+	return e.ResponseWriter
+}
@@ -0,0 +1,112 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ResultSSEEvents(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Get(http.NewServeMux(), "/events")
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		frames := []string{
+			": connected\n\n",
+			"event: progress\nid: 1\ndata: 10%\n\n",
+			"retry: 3000\ndata: line one\r\ndata: line two\r\ndata:\r\n\r\n",
+			"event: done\nid: 3\ndata:{\"ok\":true}\n\n",
+			"data: cut short",
+		}
+		for _, frame := range frames {
+			_, _ = w.Write([]byte(frame))
+			w.(http.Flusher).Flush()
+		}
+	}
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	events, err := result.SSEEvents()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, []SSEEvent{
+		{Name: "progress", Data: "10%", ID: "1"},
+		{Name: "message", Data: "line one\nline two\n", ID: "1", Retry: 3 * time.Second},
+		{Name: "done", Data: `{"ok":true}`, ID: "3"},
+	}, events)
+	assert.Len(t, result.Chunks, 5)
+
+	// Other media types are not parsed.
+	result.RawHeaders.Set("Content-Type", "application/json")
+	_, err = result.SSEEvents()
+	assert.EqualError(t, err, `not an event stream: Content-Type "application/json"`)
+}
+
+func Test_StopAfterEvents(t *testing.T) {
+	ctx := context.Background()
+
+	// The handler streams until its client goes away.
+	var writeErr error
+	conf := Get(http.NewServeMux(), "/events").StopAfterEvents(3)
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for i := 1; ; i++ {
+			select {
+			case <-r.Context().Done():
+				_, writeErr = fmt.Fprintf(w, "data: gone\n\n")
+				return
+			case <-ticker.C:
+				// Two events a write: what follows the third event is left out.
+				_, _ = fmt.Fprintf(w, "id: %d\ndata: tick\n\n: keepalive\ndata: extra\n\n", i)
+				w.(http.Flusher).Flush()
+			}
+		}
+	}
+	conf.Timeout = 5 * time.Second
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	events, err := result.SSEEvents()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, []SSEEvent{
+		{Name: "message", Data: "tick", ID: "1"},
+		{Name: "message", Data: "extra", ID: "1"},
+		{Name: "message", Data: "tick", ID: "2"},
+	}, events)
+	assert.Equal(t, "id: 1\ndata: tick\n\n: keepalive\ndata: extra\n\nid: 2\ndata: tick\n\n", result.Body.String())
+	assert.ErrorIs(t, writeErr, errEventsReached)
+}
+
+func Test_RunStopAfterEventsTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	// The events of a stream timing out before the expected number are kept
+	// on the partial result.
+	conf := Get(http.NewServeMux(), "/events").StopAfterEvents(3)
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}
+	conf.Timeout = 20 * time.Millisecond
+
+	result, err := conf.Run(ctx)
+	assert.ErrorIs(t, err, ErrTimeout)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, [][]byte{[]byte("data: first\n\n")}, result.Chunks)
+	}
+}
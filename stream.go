@@ -0,0 +1,71 @@
+package checkpoint
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// chunkRecorder records where the handler flushed the body it wrote to its
+// writer, the recorder or a limitedRecorder around it, and when.
+type chunkRecorder struct {
+	http.ResponseWriter
+	rr        *httptest.ResponseRecorder
+	start     time.Time
+	flushed   bool
+	ends      []int           // the length of the recorded body at each flush
+	times     []time.Duration // the time of each flush since start
+	lastWrite time.Duration   // the time of the last write since start
+}
+
+func newChunkRecorder(w http.ResponseWriter, rr *httptest.ResponseRecorder) *chunkRecorder {
+	return &chunkRecorder{ResponseWriter: w, rr: rr, start: time.Now()}
+}
+
+func (c *chunkRecorder) Write(p []byte) (int, error) {
+	c.lastWrite = time.Since(c.start)
+	return c.ResponseWriter.Write(p)
+}
+
+// Flush flushes the writer and ends a chunk, unless nothing was written since
+// the last one, as when the handler flushes the header alone.
+func (c *chunkRecorder) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+	c.flushed = true
+	end := c.rr.Body.Len()
+	if end == 0 || len(c.ends) > 0 && c.ends[len(c.ends)-1] == end {
+		return
+	}
+	c.ends = append(c.ends, end)
+	c.times = append(c.times, time.Since(c.start))
+}
+
+// Unwrap lets http.ResponseController reach the recorder.
+func (c *chunkRecorder) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// record sets the Chunks of r, split from body, the recorded body, along with
+// ChunkTimes and Flushed. What was written after the last flush is the last
+// chunk, timed at its last write.
+func (c *chunkRecorder) record(r *Result, body []byte) {
+	r.Flushed = c.flushed
+	if !c.flushed {
+		return
+	}
+	start := 0
+	for i, end := range c.ends {
+		if end > len(body) {
+			break
+		}
+		r.Chunks = append(r.Chunks, body[start:end])
+		r.ChunkTimes = append(r.ChunkTimes, c.times[i])
+		start = end
+	}
+	if start < len(body) {
+		r.Chunks = append(r.Chunks, body[start:])
+		r.ChunkTimes = append(r.ChunkTimes, c.lastWrite)
+	}
+}
@@ -0,0 +1,99 @@
+package checkpoint
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// progressHandler streams three progress updates, flushing each.
+func progressHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.(http.Flusher).Flush() // the header alone
+	for _, update := range []string{"10%\n", "50%\n", "100%\n"} {
+		_, _ = w.Write([]byte(update))
+		w.(http.Flusher).Flush()
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func Test_ResultChunks(t *testing.T) {
+	ctx := context.Background()
+
+	// Middlewares, diagnostics and the timeout guard keep the Flusher.
+	conf := Get(http.NewServeMux(), "/progress").
+		WithMiddlewares(passThrough).
+		WithNamedMiddleware("buffering", func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(&bufferingWriter{ResponseWriter: w}, r)
+			})
+		})
+	conf.RouteFunc = progressHandler
+	conf.ProfileMiddlewares = true
+	conf.Timeout = time.Second
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.True(t, result.Flushed)
+	assert.Equal(t, "10%\n50%\n100%\n", result.Body.String())
+	if assert.Len(t, result.Chunks, 3) {
+		assert.Equal(t, "10%\n", string(result.Chunks[0]))
+		assert.Equal(t, "50%\n", string(result.Chunks[1]))
+		assert.Equal(t, "100%\n", string(result.Chunks[2]))
+	}
+	if assert.Len(t, result.ChunkTimes, 3) {
+		assert.Less(t, result.ChunkTimes[0], result.ChunkTimes[1])
+		assert.Less(t, result.ChunkTimes[1], result.ChunkTimes[2])
+	}
+
+	// What follows the last flush is the last chunk, and flushes through
+	// http.ResponseController count too.
+	conf = Get(http.NewServeMux(), "/progress")
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("head"))
+		_ = http.NewResponseController(w).Flush()
+		_, _ = w.Write([]byte("tail"))
+	}
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.True(t, result.Flushed)
+	assert.Equal(t, [][]byte{[]byte("head"), []byte("tail")}, result.Chunks)
+
+	// A handler that does not flush has no chunks.
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("whole"))
+	}
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.False(t, result.Flushed)
+	assert.Nil(t, result.Chunks)
+}
+
+func Test_ResultChunksTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	// The chunks flushed before a timeout are kept on the partial result.
+	conf := Get(http.NewServeMux(), "/progress")
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("started\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}
+	conf.Timeout = 20 * time.Millisecond
+
+	result, err := conf.Run(ctx)
+	assert.ErrorIs(t, err, ErrTimeout)
+	if assert.NotNil(t, result) {
+		assert.True(t, result.Flushed)
+		assert.Equal(t, [][]byte{[]byte("started\n")}, result.Chunks)
+	}
+}
@@ -0,0 +1,118 @@
+package checkpoint
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// Suite creates the configs of a group of tests sharing a router, default
+// headers and middlewares, and hooks around every run. It is safe for
+// concurrent use, so parallel subtests can create their configs from the
+// same suite, each config getting its own copy of the defaults.
+type Suite struct {
+	router any
+
+	mu          sync.Mutex
+	headers     []HeaderFunc
+	middlewares []func(http.Handler) http.Handler
+	before      []BeforeRunHook
+	after       []AfterRunHook
+	teardown    []func() error
+	closed      bool
+}
+
+// NewSuite creates a Suite whose configs run against r.
+func NewSuite(r any) *Suite {
+	return &Suite{router: r}
+}
+
+// SetDefaultHeaders sets the request headers of the configs created by New
+// afterwards, replacing the previous ones.
+func (s *Suite) SetDefaultHeaders(headers ...HeaderFunc) *Suite {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.headers = slices.Clone(headers)
+	return s
+}
+
+// SetDefaultMiddlewares sets the middlewares the configs created by New
+// afterwards start with, replacing the previous ones. They come inside the
+// ones set by the package-level SetDefaultMiddlewares, and are removed as
+// well by WithoutDefaultMiddlewares.
+func (s *Suite) SetDefaultMiddlewares(middlewares ...func(http.Handler) http.Handler) *Suite {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middlewares = slices.Clone(middlewares)
+	return s
+}
+
+// BeforeEach adds a hook called before the request of every config created by
+// New afterwards is served, ahead of the hooks added to the config.
+func (s *Suite) BeforeEach(hook BeforeRunHook) *Suite {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.before = append(s.before, hook)
+	return s
+}
+
+// AfterEach adds a hook called after the request of every config created by
+// New afterwards was served, ahead of the hooks added to the config.
+func (s *Suite) AfterEach(hook AfterRunHook) *Suite {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.after = append(s.after, hook)
+	return s
+}
+
+// OnClose adds a function called by Close, as to drop a fixture database.
+func (s *Suite) OnClose(teardown func() error) *Suite {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.teardown = append(s.teardown, teardown)
+	return s
+}
+
+// New creates a config for the router of the suite with its defaults and
+// hooks, then applies opts. Changing the config does not affect the suite.
+func (s *Suite) New(opts ...Option) *TestConfig {
+	s.mu.Lock()
+	headers := slices.Clone(s.headers)
+	middlewares := slices.Clone(s.middlewares)
+	before := slices.Clone(s.before)
+	after := slices.Clone(s.after)
+	s.mu.Unlock()
+
+	opts = append([]Option{
+		WithDefaultMiddlewares(middlewares...),
+		WithDefaultHeaders(headers...),
+	}, opts...)
+	tc := Init(s.router, opts...)
+	for _, hook := range before {
+		tc.OnBeforeRun(hook)
+	}
+	for _, hook := range after {
+		tc.OnAfterRun(hook)
+	}
+	return tc
+}
+
+// Close calls the functions added with OnClose, the last added first, and
+// returns their errors joined. Later calls do nothing.
+func (s *Suite) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	teardown := s.teardown
+	s.mu.Unlock()
+
+	var errs []error
+	for _, fn := range slices.Backward(teardown) {
+		errs = append(errs, fn())
+	}
+	return errors.Join(errs...)
+}
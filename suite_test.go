@@ -0,0 +1,118 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Suite(t *testing.T) {
+	ctx := context.Background()
+
+	var resets atomic.Int32
+	var mu sync.Mutex
+	var served []string
+	suite := NewSuite(http.NewServeMux()).
+		SetDefaultHeaders(Header("X-Tenant", "acme"), Header("Accept", "application/json")).
+		SetDefaultMiddlewares(passThrough).
+		BeforeEach(func(req *http.Request) error {
+			resets.Add(1)
+			return nil
+		}).
+		AfterEach(func(req *http.Request, result *Result) error {
+			mu.Lock()
+			defer mu.Unlock()
+			served = append(served, fmt.Sprintf("%s %d", req.URL.Path, result.StatusCode))
+			return nil
+		})
+	echoTenant := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%s %s %s", r.Header.Get("X-Tenant"), r.Header.Get("Accept"), r.Header.Get("X-Request"))
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		conf := suite.New()
+		conf.URLPattern = "GET /tenant"
+		conf.Path = "/tenant"
+		conf.RouteFunc = echoTenant
+
+		result, err := conf.Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, "acme application/json ", result.Body.String())
+		assert.Equal(t, "yes", result.Headers["X-Seen"])
+
+		// Configs opt out of the middlewares and override the headers.
+		result, err = conf.WithoutDefaultMiddlewares().WithHeaders(Header("x-tenant", "globex")).Run(ctx)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		assert.Equal(t, "globex application/json ", result.Body.String())
+		assert.Empty(t, result.Headers["X-Seen"])
+	})
+
+	t.Run("parallel", func(t *testing.T) {
+		for i := range 8 {
+			t.Run(fmt.Sprint(i), func(t *testing.T) {
+				t.Parallel()
+				conf := suite.New(WithDefaultMethod(http.MethodGet))
+				conf.URLPattern = "/items/{id}"
+				conf.Path = fmt.Sprintf("/items/%d", i)
+				conf.RouteFunc = echoTenant
+				conf.WithHeaders(Header("X-Request", fmt.Sprint(i)))
+
+				result, err := conf.Run(ctx)
+				if err != nil {
+					t.Fatalf("Check failed: %v", err)
+				}
+				assert.Equal(t, fmt.Sprintf("acme application/json %d", i), result.Body.String())
+			})
+		}
+	})
+
+	assert.Equal(t, int32(10), resets.Load())
+	assert.Len(t, served, 10)
+	assert.Contains(t, served, "/tenant 200")
+	assert.Contains(t, served, "/items/7 200")
+
+	// Hooks added later apply to the configs created afterwards.
+	conf := suite.New()
+	suite.BeforeEach(func(*http.Request) error {
+		return errors.New("late hook")
+	})
+	conf.URLPattern = "/late"
+	conf.Path = "/late"
+	conf.RouteFunc = echoTenant
+	_, err := conf.Run(ctx)
+	assert.NoError(t, err)
+	late := suite.New()
+	late.URLPattern = "/later"
+	late.Path = "/later"
+	late.RouteFunc = echoTenant
+	_, err = late.Run(ctx)
+	assert.EqualError(t, err, "before-run hook 2: late hook")
+}
+
+func Test_SuiteClose(t *testing.T) {
+	var calls []string
+	suite := NewSuite(http.NewServeMux()).
+		OnClose(func() error {
+			calls = append(calls, "database")
+			return errors.New("database still in use")
+		}).
+		OnClose(func() error {
+			calls = append(calls, "server")
+			return nil
+		})
+
+	assert.EqualError(t, suite.Close(), "database still in use")
+	assert.Equal(t, []string{"server", "database"}, calls)
+	assert.NoError(t, suite.Close())
+	assert.Len(t, calls, 2)
+}
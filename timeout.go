@@ -0,0 +1,231 @@
+package checkpoint
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// ErrTimeout is returned by Run when the handler did not finish within
+// Timeout. The Result returned with it holds the response recorded so far.
+var ErrTimeout = errors.New("handler timed out")
+
+// serveHTTP serves req with h. Panics of h are recovered into a *PanicError
+// unless PropagatePanics is set. When Timeout is set, h runs in a goroutine
+// that is abandoned once ctx ends, and the response it recorded so far is
+// returned along with ErrTimeout, or the error of ctx if it was cancelled.
+// With AllowHijack, h runs in a goroutine as well, left running once it
+// hijacked the connection.
+func (tc *TestConfig) serveHTTP(ctx context.Context, h http.Handler, w http.ResponseWriter, rr *httptest.ResponseRecorder, req *http.Request) (*Result, error) {
+	if tc.Timeout <= 0 && tc.PropagatePanics && !tc.AllowHijack {
+		h.ServeHTTP(w, req)
+		return nil, nil
+	}
+
+	g := &guardedWriter{w: w}
+	var panicErr *PanicError
+	serve := func() {
+		if !tc.PropagatePanics {
+			defer func() {
+				if v := recover(); v != nil {
+					panicErr = &PanicError{Value: v, Stack: panicStack()}
+					if t, ok := req.Context().Value(layerTrackerKey{}).(*layerTracker); ok {
+						panicErr.Origin = t.origin()
+					}
+				}
+			}()
+		}
+		h.ServeHTTP(exposeInterfaces(g), req)
+	}
+	if tc.Timeout <= 0 && !tc.AllowHijack {
+		serve()
+		return g.result(rr, panicErr)
+	}
+
+	var hijacked chan struct{}
+	if h, ok := w.(*hijackWriter); ok {
+		hijacked = h.hijacked
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serve()
+	}()
+	select {
+	case <-done:
+	case <-hijacked:
+		return nil, nil
+	case <-ctx.Done():
+	}
+	// A handler that finished once ctx ended, as one returning when its
+	// request context is done, timed out all the same unless it panicked.
+	select {
+	case <-done:
+		if panicErr != nil || ctx.Err() == nil {
+			return g.result(rr, panicErr)
+		}
+	default:
+	}
+
+	partial := g.abandon(rr)
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("%w after %s", ErrTimeout, tc.Timeout)
+	}
+	return partial, err
+}
+
+// guardedWriter serializes the writes of a handler that may be abandoned, so
+// the response recorded so far can be read while it still runs. Writes made
+// after abandon are dropped.
+type guardedWriter struct {
+	mu        sync.Mutex
+	w         http.ResponseWriter
+	sent      http.Header // the header as written, nil until then
+	abandoned bool
+}
+
+func (g *guardedWriter) Header() http.Header {
+	return g.w.Header()
+}
+
+func (g *guardedWriter) WriteHeader(code int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.abandoned {
+		return
+	}
+	g.w.WriteHeader(code)
+	g.snapshot()
+}
+
+func (g *guardedWriter) Write(p []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.abandoned {
+		return 0, http.ErrHandlerTimeout
+	}
+	n, err := g.w.Write(p)
+	g.snapshot()
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the recorder.
+func (g *guardedWriter) Unwrap() http.ResponseWriter {
+	return g.w
+}
+
+func (g *guardedWriter) Flush() {
+	_ = g.FlushError()
+}
+
+func (g *guardedWriter) FlushError() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.abandoned {
+		return http.ErrHandlerTimeout
+	}
+	if err := http.NewResponseController(g.w).Flush(); err != nil {
+		return err
+	}
+	g.snapshot()
+	return nil
+}
+
+func (g *guardedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.abandoned {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	return hijack(g.w)
+}
+
+func (g *guardedWriter) Push(target string, opts *http.PushOptions) error {
+	return push(g.w, target, opts)
+}
+
+func (g *guardedWriter) ReadFrom(r io.Reader) (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.abandoned {
+		return 0, http.ErrHandlerTimeout
+	}
+	n, err := readFrom(g.w, r)
+	g.snapshot()
+	return n, err
+}
+
+// snapshot keeps the header as it was written, including the Content-Type
+// sniffed by the recorder. It is called with mu held.
+func (g *guardedWriter) snapshot() {
+	if g.sent == nil {
+		g.sent = g.w.Header().Clone()
+	}
+}
+
+// result returns the response recorded so far along with panicErr, if the
+// handler panicked.
+func (g *guardedWriter) result(rr *httptest.ResponseRecorder, panicErr *PanicError) (*Result, error) {
+	if panicErr == nil {
+		return nil, nil
+	}
+	return g.abandon(rr), panicErr
+}
+
+// abandon stops recording and returns the response recorded by rr so far. Its
+// StatusCode is zero when the handler wrote nothing.
+func (g *guardedWriter) abandon(rr *httptest.ResponseRecorder) *Result {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.abandoned = true
+
+	result := &Result{
+		Headers:    make(map[string]string),
+		RawHeaders: make(http.Header),
+		Proto:      "HTTP/1.1",
+	}
+	if g.sent == nil {
+		return result
+	}
+	result.StatusCode = rr.Code
+	result.RawHeaders = g.sent
+	for key, values := range g.sent {
+		result.Headers[key] = strings.Join(values, ", ")
+	}
+	result.Body = bytes.Clone(rr.Body.Bytes())
+	result.RawBody = result.Body
+	result.BytesWritten = int64(len(result.RawBody))
+	for w := g.w; w != nil; {
+		switch w := w.(type) {
+		case *chunkRecorder:
+			w.record(result, result.RawBody)
+		case *controllerRecorder:
+			w.record(result)
+		case *limitedRecorder:
+			result.BytesWritten, result.Truncated = w.written, w.truncated
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	result.response = &http.Response{
+		Status:     fmt.Sprintf("%d %s", rr.Code, http.StatusText(rr.Code)),
+		StatusCode: rr.Code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     g.sent,
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RunTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	defer close(release)
+	conf := Init(http.NewServeMux())
+	conf.URLPattern = "/slow"
+	conf.Path = "/slow"
+	conf.Timeout = 20 * time.Millisecond
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("partial"))
+		w.Header().Set("X-Late", "ignored")
+		// Deadlocked, ignoring the request context.
+		<-release
+		_, _ = w.Write([]byte(" never seen"))
+	}
+
+	start := time.Now()
+	result, err := conf.Run(ctx)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.EqualError(t, err, "handler timed out after 20ms")
+	if assert.NotNil(t, result) {
+		assert.Equal(t, http.StatusAccepted, result.StatusCode)
+		assert.Equal(t, "partial", result.Body.String())
+		assert.Equal(t, "text/plain", result.Headers["Content-Type"])
+		assert.Empty(t, result.HeaderValues("X-Late"))
+		assert.Contains(t, result.Dump(), "HTTP/1.1 202 Accepted\r\n")
+	}
+
+	// A handler that wrote nothing gives an empty result.
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}
+	result, err = conf.Run(ctx)
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.Equal(t, 0, result.StatusCode)
+
+	// Handlers watching the context see the deadline.
+	observed := make(chan error, 1)
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		observed <- r.Context().Err()
+	}
+	_, _ = conf.Run(ctx)
+	assert.True(t, errors.Is(<-observed, context.DeadlineExceeded))
+
+	// Fast handlers are not affected.
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}
+	result, err = conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, "ok", result.Body.String())
+}
@@ -0,0 +1,36 @@
+package checkpoint
+
+import (
+	"context"
+	"strings"
+)
+
+// Tracer traces runs, with any tracing library. The otelcheckpoint package
+// implements it with OpenTelemetry.
+type Tracer interface {
+	// StartRun is called when a run starts, with the method and route
+	// pattern of its request. The request carries the returned context, so
+	// middlewares of the handler see it, and end is called with the outcome
+	// of the run once it completed.
+	StartRun(ctx context.Context, method, pattern string) (_ context.Context, end func(*Result, error))
+}
+
+// WithTracer traces every Run and RunServer of tc with t.
+func (tc *TestConfig) WithTracer(t Tracer) *TestConfig {
+	tc.tracer = t
+	return tc
+}
+
+// startTrace starts tracing a run, returning the context the request carries
+// and the function ending the trace.
+func (tc *TestConfig) startTrace(ctx context.Context) (context.Context, func(*Result, error)) {
+	if tc.tracer == nil {
+		return ctx, func(*Result, error) {}
+	}
+	path, _ := tc.requestPath()
+	routeMethod, pattern := tc.routePattern(path)
+	if tc.URLPattern != "" {
+		pattern = strings.TrimSuffix(tc.MountAt, "/") + strings.TrimSuffix(tc.PathPrefix, "/") + pattern
+	}
+	return tc.tracer.StartRun(ctx, tc.requestMethod(routeMethod), pattern)
+}
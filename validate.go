@@ -0,0 +1,310 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Validate checks the configuration without running it. Besides the required
+// fields, it checks on a best-effort basis that Path can match URLPattern, so a
+// typo in either fails with a description of the mismatch instead of a bare
+// 404. {name}, {name...} and {name:regexp} placeholders, :name and *name
+// parameters and trailing / and * wildcards are understood.
+func (tc *TestConfig) Validate() error {
+	if tc.BaseURL != "" {
+		if u, err := url.Parse(tc.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("base URL %q is not an absolute http or https URL", tc.BaseURL)
+		}
+	} else {
+		if tc.Router == nil {
+			if tc.routerErr != nil {
+				return tc.routerErr
+			}
+			return errors.New("router cannot be nil")
+		}
+		if tc.RouteFunc == nil && len(tc.routes) == 0 && len(tc.extraRoutes) == 0 && !tc.UseExistingRoutes {
+			return errors.New("handler cannot be nil")
+		}
+	}
+	if tc.Path == "" && len(tc.pathParams) == 0 {
+		return errors.New("path cannot be empty")
+	}
+	requestPath, err := tc.requestPath()
+	if err != nil {
+		return err
+	}
+	if tc.bodyErr != nil {
+		return tc.bodyErr
+	}
+	if tc.schemaErr != nil {
+		return tc.schemaErr
+	}
+	if tc.Strict {
+		if err := tc.validateMethod(); err != nil {
+			return err
+		}
+	}
+	if err := tc.validateMiddlewareFilter(); err != nil {
+		return err
+	}
+	if tc.encoding != "" && tc.encoding != "gzip" && tc.encoding != "deflate" {
+		return fmt.Errorf("unsupported content encoding %q", tc.encoding)
+	}
+	if tc.Body != nil && tc.body != nil {
+		return fmt.Errorf("the Body field and %s both set the request body", tc.body.source)
+	}
+	path := requestPath
+	if u, err := url.Parse(requestPath); err == nil {
+		path = u.EscapedPath()
+	}
+	prefix := strings.TrimSuffix(tc.PathPrefix, "/")
+	if prefix != "" {
+		rest, found := strings.CutPrefix(requestPath, prefix)
+		if !found || (rest != "" && rest[0] != '/' && rest[0] != '?') {
+			return fmt.Errorf("path %s does not start with the prefix %s", requestPath, prefix)
+		}
+		path = strings.TrimPrefix(path, prefix)
+	}
+	if a, ok := tc.Router.(*RouterAdapter); ok {
+		if err := a.validate(); err != nil {
+			return err
+		}
+	}
+
+	if tc.URLPattern == "" || tc.UseExistingRoutes || tc.BaseURL != "" {
+		return nil
+	}
+	_, pattern := splitPattern(tc.URLPattern)
+	if tc.MountAt != "" {
+		pattern = strings.TrimSuffix(tc.MountAt, "/") + pattern
+	}
+	reason := matchPattern(path, pattern)
+	if reason == "" {
+		return nil
+	}
+	// Path may be aimed at one of the extra routes instead.
+	for _, er := range tc.extraRoutes {
+		_, extra := splitPattern(er.pattern)
+		if tc.MountAt != "" {
+			extra = strings.TrimSuffix(tc.MountAt, "/") + extra
+		}
+		if matchPattern(path, extra) == "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("path %s does not match pattern %s (%s)", requestPath, tc.URLPattern, reason)
+}
+
+// validateMethod checks the method is a standard one, and that GET, HEAD and
+// TRACE requests carry no body.
+func (tc *TestConfig) validateMethod() error {
+	method := tc.Method
+	if method == "" {
+		method, _ = splitPattern(tc.URLPattern)
+	}
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodTrace:
+		if tc.Body != nil || tc.body != nil {
+			if method == "" {
+				method = http.MethodGet
+			}
+			return fmt.Errorf("%s request cannot have a body", method)
+		}
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+		http.MethodOptions, http.MethodConnect:
+	default:
+		return fmt.Errorf("unknown method %s", method)
+	}
+	return nil
+}
+
+// requestPath returns the path the request is sent to: Path, or URLPattern
+// with the parameters given to WithPathParams substituted.
+func (tc *TestConfig) requestPath() (string, error) {
+	if len(tc.pathParams) == 0 {
+		return tc.Path, nil
+	}
+	if tc.Path != "" {
+		return "", errors.New("Path and WithPathParams both set the path")
+	}
+	if tc.URLPattern == "" {
+		return "", errors.New("WithPathParams requires URLPattern")
+	}
+	_, pattern := splitPattern(tc.URLPattern)
+	path, err := expandPattern(pattern, tc.pathParams)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(tc.MountAt, "/") + strings.TrimSuffix(tc.PathPrefix, "/") + path, nil
+}
+
+// expandPattern substitutes the {name}, {name...} and {name:regexp}
+// placeholders of pattern with the escaped values of params. Every placeholder
+// needs a value and every value a placeholder.
+func expandPattern(pattern string, params map[string]string) (string, error) {
+	var path strings.Builder
+	used := make(map[string]bool)
+	rest := pattern
+	for rest != "" {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			path.WriteString(rest)
+			break
+		}
+		path.WriteString(rest[:start])
+		end := closingBrace(rest[start:])
+		if end < 0 {
+			return "", fmt.Errorf("invalid placeholder in %s", pattern)
+		}
+		placeholder := rest[start+1 : start+end]
+		rest = rest[start+end+1:]
+		if placeholder == "$" {
+			continue
+		}
+		name, _, _ := strings.Cut(placeholder, ":")
+		name, wildcard := strings.CutSuffix(name, "...")
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("missing path parameter %s for pattern %s", name, pattern)
+		}
+		used[name] = true
+		if !wildcard {
+			path.WriteString(url.PathEscape(value))
+			continue
+		}
+		segments := strings.Split(value, "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		path.WriteString(strings.Join(segments, "/"))
+	}
+
+	var unknown []string
+	for name := range params {
+		if !used[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return "", fmt.Errorf("unknown path parameters %s for pattern %s", strings.Join(unknown, ", "), pattern)
+	}
+	return path.String(), nil
+}
+
+// matchPattern reports why path cannot match pattern, or "" when it can.
+func matchPattern(path, pattern string) string {
+	pathSegments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	patternSegments := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+
+	for i, segment := range patternSegments {
+		last := i == len(patternSegments)-1
+		// A final {$} only matches the empty segment of a path ending in a
+		// slash.
+		if last && segment == "{$}" {
+			if len(pathSegments) != len(patternSegments) {
+				return fmt.Sprintf("segment count: %d != %d", len(pathSegments), len(patternSegments))
+			}
+			if pathSegments[i] != "" {
+				return fmt.Sprintf("segment %d: %s != %s", i+1, pathSegments[i], segment)
+			}
+			return ""
+		}
+		// Trailing wildcards match whatever remains of the path.
+		if last && (segment == "" || segment == "*" || strings.HasPrefix(segment, "*") ||
+			(strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "...}"))) {
+			return ""
+		}
+		if i >= len(pathSegments) {
+			return fmt.Sprintf("segment count: %d != %d", len(pathSegments), len(patternSegments))
+		}
+
+		if !strings.ContainsAny(segment, "{}") && !strings.HasPrefix(segment, ":") {
+			if pathSegments[i] != segment {
+				return fmt.Sprintf("segment %d: %s != %s", i+1, pathSegments[i], segment)
+			}
+			continue
+		}
+		re, err := segmentRegexp(segment)
+		if err != nil {
+			return fmt.Sprintf("segment %d: %v", i+1, err)
+		}
+		if re != nil && !re.MatchString(pathSegments[i]) {
+			return fmt.Sprintf("segment %d: %s does not match %s", i+1, pathSegments[i], segment)
+		}
+	}
+	if len(pathSegments) > len(patternSegments) {
+		return fmt.Sprintf("segment count: %d != %d", len(pathSegments), len(patternSegments))
+	}
+	return ""
+}
+
+// segmentRegexp builds a regexp matching a pattern segment with placeholders.
+// It returns a nil regexp when a custom placeholder regexp does not compile,
+// in which case the segment is not checked.
+func segmentRegexp(segment string) (*regexp.Regexp, error) {
+	if name, ok := strings.CutPrefix(segment, ":"); ok {
+		if name == "" {
+			return nil, errors.New("empty parameter name")
+		}
+		return regexp.MustCompile(`^.+$`), nil
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	rest := segment
+	for rest != "" {
+		start := strings.IndexAny(rest, "{}")
+		if start < 0 {
+			expr.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		if rest[start] == '}' {
+			return nil, fmt.Errorf("invalid placeholder in %s", segment)
+		}
+		expr.WriteString(regexp.QuoteMeta(rest[:start]))
+		end := closingBrace(rest[start:])
+		if end < 0 {
+			return nil, fmt.Errorf("invalid placeholder in %s", segment)
+		}
+		placeholder := rest[start+1 : start+end]
+		name, custom, hasCustom := strings.Cut(placeholder, ":")
+		if name == "" || strings.Contains(name, "{") {
+			return nil, fmt.Errorf("invalid placeholder in %s", segment)
+		}
+		if hasCustom {
+			if _, err := regexp.Compile(custom); err != nil {
+				return nil, nil
+			}
+			expr.WriteString("(?:" + custom + ")")
+		} else {
+			expr.WriteString("[^/]+")
+		}
+		rest = rest[start+end+1:]
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+// closingBrace returns the index of the brace closing the one s starts with,
+// or -1. Braces nested in a placeholder regexp, as in {id:[0-9]{3}}, are skipped.
+func closingBrace(s string) int {
+	depth := 0
+	for i, c := range s {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,119 @@
+package checkpoint
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Validate(t *testing.T) {
+	tc := []struct {
+		path    string
+		pattern string
+		wantErr string
+	}{
+		{path: "/users/123", pattern: "/users/{id}"},
+		{path: "/users/123?expand=true", pattern: "GET /users/{id}"},
+		{path: "/users/123", pattern: "/users/{id:[0-9]+}"},
+		{path: "/users/123", pattern: "/users/{id:[0-9]{3}}"},
+		{path: "/users/123", pattern: "/users/:id"},
+		{path: "/files/a/b/c", pattern: "/files/{rest...}"},
+		{path: "/files/a/b/c", pattern: "/files/*"},
+		{path: "/files/a/b/c", pattern: "/files/*rest"},
+		{path: "/files/a/b/c", pattern: "/files/"},
+		{path: "/reports/7.json", pattern: "/reports/{id}.json"},
+		{path: "/items/", pattern: "GET /items/{$}"},
+		{
+			path:    "/items/1",
+			pattern: "/items/{$}",
+			wantErr: "path /items/1 does not match pattern /items/{$} (segment 2: 1 != {$})",
+		},
+		{
+			path:    "/items",
+			pattern: "/items/{$}",
+			wantErr: "path /items does not match pattern /items/{$} (segment count: 1 != 2)",
+		},
+		{
+			path:    "/users/123",
+			pattern: "/user/{id}",
+			wantErr: "path /users/123 does not match pattern /user/{id} (segment 1: users != user)",
+		},
+		{
+			path:    "/users/123/posts",
+			pattern: "/users/{id}",
+			wantErr: "path /users/123/posts does not match pattern /users/{id} (segment count: 3 != 2)",
+		},
+		{
+			path:    "/users",
+			pattern: "/users/{id}",
+			wantErr: "path /users does not match pattern /users/{id} (segment count: 1 != 2)",
+		},
+		{
+			path:    "/users/abc",
+			pattern: "/users/{id:[0-9]+}",
+			wantErr: "path /users/abc does not match pattern /users/{id:[0-9]+} (segment 2: abc does not match {id:[0-9]+})",
+		},
+		{
+			path:    "/users/123",
+			pattern: "/users/{id",
+			wantErr: "path /users/123 does not match pattern /users/{id (segment 2: invalid placeholder in {id)",
+		},
+		{
+			path:    "/users/",
+			pattern: "/users/{id}",
+			wantErr: "path /users/ does not match pattern /users/{id} (segment 2:  does not match {id})",
+		},
+	}
+
+	for i, test := range tc {
+		conf := Init(http.NewServeMux())
+		conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+		conf.Path = test.path
+		conf.URLPattern = test.pattern
+
+		err := conf.Validate()
+		if test.wantErr == "" {
+			assert.NoError(t, err, "failure in the test case: %d", i)
+		} else {
+			assert.EqualError(t, err, test.wantErr, "failure in the test case: %d", i)
+		}
+	}
+}
+
+func Test_ValidateWithPrefixAndMount(t *testing.T) {
+	conf := Init(http.NewServeMux()).WithPathPrefix("/api")
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {}
+	conf.Path = "/api/users/1"
+	conf.URLPattern = "/users/{id}"
+	assert.NoError(t, conf.Validate())
+
+	conf.PathPrefix = ""
+	conf.MountAt = "/admin"
+	conf.Path = "/admin/users/1"
+	assert.NoError(t, conf.Validate())
+
+	conf.Path = "/users/1"
+	assert.EqualError(t, conf.Validate(), "path /users/1 does not match pattern /users/{id} (segment 1: users != admin)")
+}
+
+func Test_ExpandPattern(t *testing.T) {
+	tc := []struct {
+		pattern string
+		params  map[string]string
+		want    string
+	}{
+		{pattern: "/users/{id}", params: map[string]string{"id": "7"}, want: "/users/7"},
+		{pattern: "/users/{id:[0-9]+}", params: map[string]string{"id": "7"}, want: "/users/7"},
+		{pattern: "/files/{name}", params: map[string]string{"name": "a/b c"}, want: "/files/a%2Fb%20c"},
+		{pattern: "/files/{rest...}", params: map[string]string{"rest": "a/b c"}, want: "/files/a/b%20c"},
+		{pattern: "/reports/{id}.json", params: map[string]string{"id": "q?"}, want: "/reports/q%3F.json"},
+		{pattern: "/{$}", params: map[string]string{}, want: "/"},
+	}
+
+	for i, c := range tc {
+		got, err := expandPattern(c.pattern, c.params)
+		assert.NoError(t, err, "failure in the test case: %d", i)
+		assert.Equal(t, c.want, got, "failure in the test case: %d", i)
+	}
+}
@@ -0,0 +1,238 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultWebSocketTimeout bounds each send and receive of a WebSocket session
+// when the config has no Timeout.
+const DefaultWebSocketTimeout = 5 * time.Second
+
+// WebSocket is a WebSocket session opened by RunWebSocket. Close it once
+// done, which also stops the server serving it.
+type WebSocket struct {
+	conn    *websocket.Conn
+	srv     *httptest.Server
+	timeout time.Duration
+
+	mu          sync.Mutex
+	closed      bool
+	closeCode   int
+	closeReason string
+}
+
+// WebSocketMessage is a message received on a WebSocket session.
+type WebSocketMessage struct {
+	Binary bool
+	Data   []byte
+}
+
+// Text returns the data of the message as a string.
+func (m WebSocketMessage) Text() string {
+	return string(m.Data)
+}
+
+// RunWebSocket serves the handler with a real server, as RunServer does, and
+// dials the route with a WebSocket client, sending the headers, cookies and
+// query of tc with the handshake. The Result describes the handshake response.
+// When the handler accepts the upgrade, the session to send and receive
+// messages on is returned along with it; when it answers with another status,
+// as 403, the session is nil and the Result holds the response as Run would
+// report it, without an error. Each send and receive is bounded by Timeout,
+// or DefaultWebSocketTimeout.
+func (tc *TestConfig) RunWebSocket(ctx context.Context) (*WebSocket, *Result, error) {
+	ctx, end := tc.startTrace(ctx)
+	ws, result, err := tc.runWebSocket(ctx)
+	result, err = tc.afterRun(result, err)
+	end(result, err)
+	if err != nil && ws != nil {
+		_ = ws.Close()
+		ws = nil
+	}
+	return ws, result, err
+}
+
+func (tc *TestConfig) runWebSocket(ctx context.Context) (*WebSocket, *Result, error) {
+	start := time.Now()
+	serve, _, path, _, err := tc.serverHandler("RunWebSocket")
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := tc.newRequest(ctx, http.MethodGet, path, nil, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tc.beforeRun(req); err != nil {
+		return nil, nil, err
+	}
+	requestDump := dumpRequest(req)
+	matched := recordCoverage(tc.Router, req)
+
+	srv := httptest.NewServer(serve)
+	if err := rebaseRequest(req, srv.URL); err != nil {
+		srv.Close()
+		return nil, nil, err
+	}
+	u := *req.URL
+	u.Scheme = "ws"
+	header := req.Header.Clone()
+	// The dialer sets the handshake headers itself.
+	for _, key := range []string{"Upgrade", "Connection", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions"} {
+		header.Del(key)
+	}
+	if req.Host != u.Host {
+		header.Set("Host", req.Host)
+	}
+
+	timeout := tc.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWebSocketTimeout
+	}
+	dialer := websocket.Dialer{HandshakeTimeout: timeout}
+	serveStart := time.Now()
+	conn, response, err := dialer.DialContext(ctx, u.String(), header)
+	if response == nil {
+		srv.Close()
+		return nil, nil, fmt.Errorf("dialing the WebSocket: %w", err)
+	}
+	readStart := time.Now()
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	response.Body = io.NopCloser(bytes.NewReader(body))
+
+	headers := make(map[string]string)
+	for key, values := range response.Header {
+		headers[key] = strings.Join(values, ", ")
+	}
+	end := time.Now()
+	result := &Result{
+		Headers:      headers,
+		RawHeaders:   response.Header,
+		StatusCode:   response.StatusCode,
+		Body:         body,
+		RawBody:      body,
+		BytesWritten: int64(len(body)),
+		Pattern:      matched,
+		Proto:        response.Proto,
+		Duration:     end.Sub(start),
+		Timing: Timing{
+			Setup:    serveStart.Sub(start),
+			Handler:  readStart.Sub(serveStart),
+			BodyRead: end.Sub(readStart),
+		},
+		response:    response,
+		requestDump: requestDump,
+		request:     req,
+	}
+	if err != nil {
+		// The handler did not upgrade the connection.
+		srv.Close()
+		if errors.Is(err, websocket.ErrBadHandshake) {
+			return nil, result, nil
+		}
+		return nil, result, fmt.Errorf("dialing the WebSocket: %w", err)
+	}
+	return &WebSocket{conn: conn, srv: srv, timeout: timeout}, result, nil
+}
+
+// SendText sends a text message.
+func (ws *WebSocket) SendText(text string) error {
+	return ws.send(websocket.TextMessage, []byte(text))
+}
+
+// SendBinary sends a binary message.
+func (ws *WebSocket) SendBinary(data []byte) error {
+	return ws.send(websocket.BinaryMessage, data)
+}
+
+func (ws *WebSocket) send(messageType int, data []byte) error {
+	if err := ws.conn.SetWriteDeadline(time.Now().Add(ws.timeout)); err != nil {
+		return err
+	}
+	return ws.conn.WriteMessage(messageType, data)
+}
+
+// Receive waits for the next message, up to the timeout of the session. Once
+// the handler closed the connection it fails, and CloseCode and CloseReason
+// tell how it closed it.
+func (ws *WebSocket) Receive() (WebSocketMessage, error) {
+	if err := ws.conn.SetReadDeadline(time.Now().Add(ws.timeout)); err != nil {
+		return WebSocketMessage{}, err
+	}
+	messageType, data, err := ws.conn.ReadMessage()
+	if err != nil {
+		ws.recordClose(err)
+		return WebSocketMessage{}, fmt.Errorf("receiving a WebSocket message: %w", err)
+	}
+	return WebSocketMessage{Binary: messageType == websocket.BinaryMessage, Data: data}, nil
+}
+
+// recordClose keeps the close code and reason of err when the connection was
+// closed with a close frame.
+func (ws *WebSocket) recordClose(err error) {
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		return
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closeCode == 0 {
+		ws.closeCode, ws.closeReason = closeErr.Code, closeErr.Text
+	}
+}
+
+// CloseCode returns the status code of the close frame the handler sent, as
+// websocket.CloseNormalClosure, or zero when it sent none.
+func (ws *WebSocket) CloseCode() int {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.closeCode
+}
+
+// CloseReason returns the reason of the close frame the handler sent.
+func (ws *WebSocket) CloseReason() string {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.closeReason
+}
+
+// Close closes the session with a normal closure, waiting up to the timeout
+// of the session for the close frame the handler answers with, then stops the
+// server. Calling it again does nothing.
+func (ws *WebSocket) Close() error {
+	ws.mu.Lock()
+	if ws.closed {
+		ws.mu.Unlock()
+		return nil
+	}
+	ws.closed = true
+	ws.mu.Unlock()
+	defer ws.srv.Close()
+
+	deadline := time.Now().Add(ws.timeout)
+	message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	err := ws.conn.WriteControl(websocket.CloseMessage, message, deadline)
+	if err == nil || errors.Is(err, websocket.ErrCloseSent) {
+		// Read up to the close frame of the handler, unless it came first.
+		_ = ws.conn.SetReadDeadline(deadline)
+		for {
+			if _, _, err := ws.conn.NextReader(); err != nil {
+				ws.recordClose(err)
+				break
+			}
+		}
+		err = nil
+	}
+	return errors.Join(err, ws.conn.Close())
+}
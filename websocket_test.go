@@ -0,0 +1,118 @@
+package checkpoint
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// echoSocket upgrades requests carrying a token and echoes their messages
+// until it receives "bye", then closes the connection with code 4000.
+func echoSocket(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, http.Header{"X-Room": {r.URL.Query().Get("room")}})
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if string(data) == "bye" {
+			message := websocket.FormatCloseMessage(4000, "see you")
+			_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+			return
+		}
+		if err := conn.WriteMessage(messageType, data); err != nil {
+			return
+		}
+	}
+}
+
+func Test_RunWebSocket(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Get(http.NewServeMux(), "/socket").WithQuery("room", "lobby").WithBearerToken("token")
+	conf.RouteFunc = echoSocket
+
+	ws, result, err := conf.RunWebSocket(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	defer ws.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, result.StatusCode)
+	assert.Equal(t, "lobby", result.Headers["X-Room"])
+
+	assert.NoError(t, ws.SendText("hello"))
+	message, err := ws.Receive()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, WebSocketMessage{Data: []byte("hello")}, message)
+	assert.Equal(t, "hello", message.Text())
+
+	assert.NoError(t, ws.SendBinary([]byte{0, 1, 2}))
+	message, err = ws.Receive()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Equal(t, WebSocketMessage{Binary: true, Data: []byte{0, 1, 2}}, message)
+
+	// The close frame of the handler is captured.
+	assert.NoError(t, ws.SendText("bye"))
+	_, err = ws.Receive()
+	assert.ErrorContains(t, err, "receiving a WebSocket message: websocket: close 4000: see you")
+	assert.Equal(t, 4000, ws.CloseCode())
+	assert.Equal(t, "see you", ws.CloseReason())
+	assert.NoError(t, ws.Close())
+	assert.NoError(t, ws.Close())
+}
+
+func Test_RunWebSocketTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Get(http.NewServeMux(), "/socket").WithBearerToken("token")
+	conf.RouteFunc = echoSocket
+	conf.Timeout = 20 * time.Millisecond
+
+	ws, _, err := conf.RunWebSocket(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	// Nothing comes unless something is sent.
+	_, err = ws.Receive()
+	assert.ErrorContains(t, err, "i/o timeout")
+	assert.Zero(t, ws.CloseCode())
+	assert.NoError(t, ws.Close())
+}
+
+func Test_RunWebSocketRejected(t *testing.T) {
+	ctx := context.Background()
+
+	conf := Get(http.NewServeMux(), "/socket")
+	conf.RouteFunc = echoSocket
+
+	ws, result, err := conf.RunWebSocket(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.Nil(t, ws)
+	assert.Equal(t, http.StatusForbidden, result.StatusCode)
+	assert.Equal(t, "forbidden\n", result.Body.String())
+	result.Expect(t).Status(http.StatusForbidden).BodyContains("forbidden").Done()
+
+	// Remote configs cannot be served.
+	conf = InitRemote("http://example.com").WithPath("/socket")
+	_, _, err = conf.RunWebSocket(ctx)
+	assert.EqualError(t, err, "RunWebSocket serves the handler itself, use Run to send the request to BaseURL")
+}
@@ -0,0 +1,183 @@
+package checkpoint
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// wrappingWriter is a ResponseWriter Run wraps the writer of a layer with. It
+// implements every optional interface of a ResponseWriter, but may offer one
+// only when the writer it wraps does; see exposeInterfaces.
+type wrappingWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+	http.Pusher
+	io.ReaderFrom
+	controlled
+}
+
+// controlled is what http.ResponseController looks for: FlushError, which it
+// prefers to Flush, so a flush through a writer a middleware wrapped
+// without a Flush of its own reaches the wrapper of the layer, and Unwrap.
+type controlled interface {
+	FlushError() error
+	Unwrap() http.ResponseWriter
+}
+
+// exposeInterfaces returns w offering the optional interfaces among Flusher,
+// Hijacker, Pusher and ReaderFrom the writer it wraps offers, and no others,
+// so a middleware type-asserting them on its writer behaves as it would
+// without Run's wrappers. The methods of controlled are always kept.
+func exposeInterfaces(w wrappingWriter) http.ResponseWriter {
+	inner := w.Unwrap()
+	var mask int
+	if _, ok := inner.(http.Flusher); ok {
+		mask |= 1
+	}
+	if _, ok := inner.(http.Hijacker); ok {
+		mask |= 2
+	}
+	if _, ok := inner.(http.Pusher); ok {
+		mask |= 4
+	}
+	if _, ok := inner.(io.ReaderFrom); ok {
+		mask |= 8
+	}
+
+	switch mask {
+	case 1:
+		return struct {
+			http.ResponseWriter
+			controlled
+			http.Flusher
+		}{w, w, w}
+	case 2:
+		return struct {
+			http.ResponseWriter
+			controlled
+			http.Hijacker
+		}{w, w, w}
+	case 3:
+		return struct {
+			http.ResponseWriter
+			controlled
+			http.Flusher
+			http.Hijacker
+		}{w, w, w, w}
+	case 4:
+		return struct {
+			http.ResponseWriter
+			controlled
+			http.Pusher
+		}{w, w, w}
+	case 5:
+		return struct {
+			http.ResponseWriter
+			controlled
+			http.Flusher
+			http.Pusher
+		}{w, w, w, w}
+	case 6:
+		return struct {
+			http.ResponseWriter
+			controlled
+			http.Hijacker
+			http.Pusher
+		}{w, w, w, w}
+	case 7:
+		return struct {
+			http.ResponseWriter
+			controlled
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{w, w, w, w, w}
+	case 8:
+		return struct {
+			http.ResponseWriter
+			controlled
+			io.ReaderFrom
+		}{w, w, w}
+	case 9:
+		return struct {
+			http.ResponseWriter
+			controlled
+			http.Flusher
+			io.ReaderFrom
+		}{w, w, w, w}
+	case 10:
+		return struct {
+			http.ResponseWriter
+			controlled
+			http.Hijacker
+			io.ReaderFrom
+		}{w, w, w, w}
+	case 11:
+		return struct {
+			http.ResponseWriter
+			controlled
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{w, w, w, w, w}
+	case 12:
+		return struct {
+			http.ResponseWriter
+			controlled
+			http.Pusher
+			io.ReaderFrom
+		}{w, w, w, w}
+	case 13:
+		return struct {
+			http.ResponseWriter
+			controlled
+			http.Flusher
+			http.Pusher
+			io.ReaderFrom
+		}{w, w, w, w, w}
+	case 14:
+		return struct {
+			http.ResponseWriter
+			controlled
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{w, w, w, w, w}
+	case 15:
+		return w
+	}
+	return struct {
+		http.ResponseWriter
+		controlled
+	}{w, w}
+}
+
+// hijack hijacks the connection of w, or fails with http.ErrNotSupported.
+func hijack(w http.ResponseWriter) (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w).Hijack()
+}
+
+// push pushes target through w, or fails with http.ErrNotSupported.
+func push(w http.ResponseWriter, target string, opts *http.PushOptions) error {
+	if p, ok := w.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// readFrom copies r to w, with its ReadFrom when it has one.
+func readFrom(w http.ResponseWriter, r io.Reader) (int64, error) {
+	if rf, ok := w.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(writerOnly{w}, r)
+}
+
+// writerOnly hides the ReadFrom of a writer from io.Copy, which would call it
+// back.
+type writerOnly struct {
+	io.Writer
+}
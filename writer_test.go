@@ -0,0 +1,148 @@
+package checkpoint
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bufferingWriter is a middleware's writer holding the body until the
+// handler flushes, as compressing writers do.
+type bufferingWriter struct {
+	http.ResponseWriter
+	buf       bytes.Buffer
+	flushable bool // whether the writer it wraps was a Flusher
+}
+
+func (b *bufferingWriter) Write(p []byte) (int, error) {
+	return b.buf.Write(p)
+}
+
+func (b *bufferingWriter) Flush() {
+	_, _ = b.ResponseWriter.Write(b.buf.Bytes())
+	b.buf.Reset()
+	if f, ok := b.ResponseWriter.(http.Flusher); ok {
+		b.flushable = true
+		f.Flush()
+	}
+}
+
+func Test_WrappedWritersKeepInterfaces(t *testing.T) {
+	ctx := context.Background()
+
+	var asserted *bufferingWriter
+	buffering := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			asserted = &bufferingWriter{ResponseWriter: w}
+			next.ServeHTTP(asserted, r)
+		})
+	}
+	var controllerErr error
+	controlling := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			_, _ = w.Write([]byte(" and after"))
+			controllerErr = http.NewResponseController(w).Flush()
+		})
+	}
+
+	// Every diagnostic wrapper is on: the layers, the profile and the
+	// timeout guard.
+	conf := Get(http.NewServeMux(), "/stream").
+		WithNamedMiddleware("controlling", controlling).
+		WithNamedMiddleware("buffering", buffering)
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("chunk"))
+		w.(http.Flusher).Flush()
+	}
+	conf.ProfileMiddlewares = true
+	conf.Timeout = time.Second
+
+	result, err := conf.Run(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	assert.True(t, asserted.flushable)
+	assert.NoError(t, controllerErr)
+	assert.Equal(t, "chunk and after", result.Body.String())
+	assert.Equal(t, &Layer{Index: 2, Name: "handler", Handler: true}, result.WrittenBy)
+	assert.Len(t, result.MiddlewareTimings, 2)
+
+	// The connection Run serves with can be hijacked through the wrappers,
+	// which fails unless AllowHijack is set.
+	conf = Get(http.NewServeMux(), "/hijack").WithMiddlewares(passThrough)
+	conf.RouteFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, ok := w.(http.Hijacker)
+		assert.True(t, ok)
+		_, _, err := http.NewResponseController(w).Hijack()
+		assert.ErrorIs(t, err, ErrHijackNotAllowed)
+	}
+	_, err = conf.Run(ctx)
+	assert.ErrorIs(t, err, ErrHijackNotAllowed)
+}
+
+// fullWriter is a ResponseWriter offering every optional interface.
+type fullWriter struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+	pushed   []string
+}
+
+func (f *fullWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	f.hijacked = true
+	return nil, nil, nil
+}
+
+func (f *fullWriter) Push(target string, opts *http.PushOptions) error {
+	f.pushed = append(f.pushed, target)
+	return nil
+}
+
+func (f *fullWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(f.ResponseRecorder, r)
+}
+
+func Test_ExposeInterfaces(t *testing.T) {
+	tracker := &layerTracker{}
+	full := &fullWriter{ResponseRecorder: httptest.NewRecorder()}
+	w := exposeInterfaces(&layerWriter{ResponseWriter: full, tracker: tracker, layer: Layer{Name: "gzip"}})
+
+	_, ok := w.(http.Flusher)
+	assert.True(t, ok)
+	if h, ok := w.(http.Hijacker); assert.True(t, ok) {
+		_, _, err := h.Hijack()
+		assert.NoError(t, err)
+		assert.True(t, full.hijacked)
+	}
+	if p, ok := w.(http.Pusher); assert.True(t, ok) {
+		assert.NoError(t, p.Push("/style.css", nil))
+		assert.Equal(t, []string{"/style.css"}, full.pushed)
+	}
+	if rf, ok := w.(io.ReaderFrom); assert.True(t, ok) {
+		n, err := rf.ReadFrom(strings.NewReader("body"))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), n)
+		assert.Equal(t, "body", full.Body.String())
+	}
+	assert.Equal(t, &Layer{Name: "gzip"}, tracker.writtenBy)
+
+	// Over a plain writer the wrapper offers none of them, but still lets
+	// http.ResponseController through.
+	plain := struct{ http.ResponseWriter }{httptest.NewRecorder()}
+	w = exposeInterfaces(&layerWriter{ResponseWriter: plain, tracker: &layerTracker{}})
+	_, flusher := w.(http.Flusher)
+	_, hijacker := w.(http.Hijacker)
+	_, pusher := w.(http.Pusher)
+	_, readerFrom := w.(io.ReaderFrom)
+	assert.False(t, flusher || hijacker || pusher || readerFrom)
+	assert.ErrorIs(t, http.NewResponseController(w).Flush(), http.ErrNotSupported)
+}